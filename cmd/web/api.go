@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//apiSnippet is snippet in API responses
+type apiSnippet struct {
+	ID         int64      `json:"id"`
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	Created    time.Time  `json:"created"`
+	Expires    *time.Time `json:"expires"` // null for never expiring snippet
+	Visibility string     `json:"visibility"`
+	Format     string     `json:"format"`
+	Views      int64      `json:"views"`
+	OwnerID    int64      `json:"owner_id"`
+	ParentID   *int64     `json:"parent_id"` // null if snippet isn't fork
+}
+
+func newAPISnippet(snippet *models.Snippet) *apiSnippet {
+	res := &apiSnippet{
+		ID:         snippet.ID,
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Created:    snippet.Created.UTC(),
+		Visibility: snippet.Visibility(),
+		Format:     models.FormatText,
+		Views:      snippet.Views,
+		OwnerID:    snippet.OwnerID,
+	}
+
+	if snippet.Format != "" {
+		res.Format = snippet.Format
+	}
+
+	if !snippet.NeverExpires() {
+		expires := snippet.Expires.UTC()
+		res.Expires = &expires
+	}
+
+	if snippet.ParentID != 0 {
+		parentID := snippet.ParentID
+		res.ParentID = &parentID
+	}
+
+	return res
+}
+
+//apiSnippetList is page of snippets list with URLs of neighbour pages
+type apiSnippetList struct {
+	Snippets []*apiSnippet `json:"snippets"`
+	Total    int64         `json:"total"`
+	Next     string        `json:"next,omitempty"`
+	Prev     string        `json:"prev,omitempty"`
+}
+
+//apiSnippets return public snippets list or own snippets with owner=me, query parameters are the same as for pages
+func (s *Server) apiSnippets(w http.ResponseWriter, r *http.Request) {
+	ownerID := int64(-1)
+
+	if r.URL.Query().Get("owner") == "me" {
+		u := getAuthUserFromRequest(r)
+
+		if u == nil {
+			s.clientError(w, r, http.StatusUnauthorized, "Login is required for own snippets")
+			return
+		}
+
+		ownerID = u.ID
+		w.Header().Set("Cache-Control", privateCacheControl)
+	}
+
+	list := s.listSnippets(w, r, ownerID, 0)
+
+	if list == nil {
+		return
+	}
+
+	s.writeSnippetList(w, r, list)
+}
+
+//writeSnippetList write page of snippets list as JSON
+func (s *Server) writeSnippetList(w http.ResponseWriter, r *http.Request, list *snippetList) {
+	nav := newPageNav(r.URL, list.Page, list.Options.SortBy())
+	res := &apiSnippetList{
+		Snippets: make([]*apiSnippet, len(list.Page.Snippets)),
+		Total:    nav.Total,
+		Next:     nav.NextURL,
+		Prev:     nav.PrevURL,
+	}
+
+	for i, snippet := range list.Page.Snippets {
+		res.Snippets[i] = newAPISnippet(snippet)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.logger(r).Errorf("Error while encode snippets: %v", err)
+	}
+}
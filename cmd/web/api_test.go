@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+func TestAPISnippets(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 12, true, 1), getTestSnippetData(13, 2, false, 2)...)
+	ss[0].Unlisted = true
+	ss[3].Views = 7
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		query     string
+		login     bool
+		wantCode  int
+		wantTotal int64
+		wantFirst int64
+		wantNext  bool
+	}{
+		{"Public list", "", false, http.StatusOK, 11, 12, true},
+		{"Sort by views", "sort=views", false, http.StatusOK, 11, 4, true},
+		{"Sort by title", "sort=title&order=asc", false, http.StatusOK, 11, 10, true},
+		{"Bad sort", "sort=size", false, http.StatusBadRequest, 0, 0, false},
+		{"Visibility of public list", "visibility=private", false, http.StatusBadRequest, 0, 0, false},
+		{"Bad date", "from=yesterday", false, http.StatusBadRequest, 0, 0, false},
+		{"Own snippets without login", "owner=me", false, http.StatusUnauthorized, 0, 0, false},
+		{"Own snippets", "owner=me", true, http.StatusOK, 2, 14, false},
+		{"Own private snippets", "owner=me&visibility=private", true, http.StatusOK, 2, 14, false},
+		{"Own public snippets", "owner=me&visibility=public", true, http.StatusOK, 0, 0, false},
+	}
+
+	loggedIn := false
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.login && !loggedIn {
+				login(t, srv, "conor@mail.com", "12345678")
+				loggedIn = true
+			}
+
+			code, header, body := get(fmt.Sprintf("%s/api/snippets?%s", srv.URL, test.query), t, srv)
+
+			if code != test.wantCode {
+				t.Fatalf("Want code: %d, Get: %d %s", test.wantCode, code, body)
+			}
+
+			if header.Get("Content-Type") != "application/json" {
+				t.Fatalf("Want JSON, Get: %s", header.Get("Content-Type"))
+			}
+
+			if code != http.StatusOK {
+				return
+			}
+
+			list := &apiSnippetList{}
+			if err := json.Unmarshal(body, list); err != nil {
+				t.Fatal(err)
+			}
+
+			if list.Total != test.wantTotal || (list.Next != "") != test.wantNext || list.Prev != "" {
+				t.Fatalf("Want total %d, next %v, Get: %d %q %q", test.wantTotal, test.wantNext, list.Total, list.Next, list.Prev)
+			}
+
+			if test.wantFirst != 0 && list.Snippets[0].ID != test.wantFirst {
+				t.Fatalf("Want first snippet %d, Get: %d", test.wantFirst, list.Snippets[0].ID)
+			}
+		})
+	}
+}
+
+func TestAPISnippetsNavigation(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 12, true, 1)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	getList := func(path string) *apiSnippetList {
+		code, _, body := get(srv.URL+path, t, srv)
+		if code != http.StatusOK {
+			t.Fatalf("Want code: 200, Get: %d", code)
+		}
+
+		list := &apiSnippetList{}
+		if err := json.Unmarshal(body, list); err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+
+	first := getList("/api/snippets?sort=title&order=asc")
+	second := getList(first.Next)
+
+	// titles are ordered as strings: "10title10", "11title11", "12title12", "1title1", "2title2", ...
+	if len(second.Snippets) != 2 || second.Snippets[0].Title != "8title8" || second.Next != "" || second.Prev == "" {
+		t.Fatalf("Bad second page: %+v", second)
+	}
+
+	back := getList(second.Prev)
+
+	if len(back.Snippets) != 10 || back.Snippets[0].ID != first.Snippets[0].ID || back.Prev != "" {
+		t.Fatalf("Bad page before second: %+v", back)
+	}
+}
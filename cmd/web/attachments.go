@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/blob"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+//blobBackends are values of BLOB_BACKEND
+var blobBackends = []string{"local", "s3"}
+
+//maxSnippetAttachments limit of attachments of one snippet
+const maxSnippetAttachments = 10
+
+//blobDeleteTimeout limit removal of blobs, it isn't cancelled with request
+const blobDeleteTimeout = 30 * time.Second
+
+//uploadFormOverhead is room for multipart headers and form fields besides attachment content
+const uploadFormOverhead = 64 << 10
+
+//limitUploads limit body of multipart requests before it's parsed by CSRF protection,
+//request which is known to be too large is rejected without reading
+func (s *Server) limitUploads(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+				limit := s.attachments.maxSize + uploadFormOverhead
+
+				if r.ContentLength > limit {
+					w.Header().Set("Connection", "close")
+					s.clientError(w, r, http.StatusRequestEntityTooLarge, "Attachment is too large")
+					return
+				}
+
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+}
+
+//newBlobKey return unique key of attachment content, uploaded name isn't used in key
+func newBlobKey(snippetID int64) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("snippets/%d/%s", snippetID, hex.EncodeToString(b))
+}
+
+//attachmentName return base name of uploaded file, browsers on Windows may send full path
+func attachmentName(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, `\`, "/"))
+
+	if name == "." || name == "/" {
+		return "attachment"
+	}
+
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+
+	return name
+}
+
+//detectContentType sniff type of content, reader is rewound to start
+func detectContentType(f io.ReadSeeker) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+
+	return mediaType, err
+}
+
+//getOwnSnippet return snippet if current user is owner, otherwise error is written and nil returned,
+//private snippet of other user is not found, so its existence is not revealed
+func (s *Server) getOwnSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	snippet, err := s.snippetStore.Get(r.Context(), int64(id))
+
+	if err != nil {
+		if err == models.ErrNoRecord {
+			s.notFound(w, r)
+		} else {
+			s.serverError(w, r, err)
+		}
+		return nil
+	}
+
+	if snippet.OwnerID != getAuthUserFromRequest(r).ID {
+		if snippet.IsPublic {
+			s.forbidden(w, r)
+		} else {
+			s.notFound(w, r)
+		}
+		return nil
+	}
+
+	return snippet
+}
+
+//getSnippetAttachment return attachment from URL if it belongs to snippet, otherwise 404 is written and nil returned
+func (s *Server) getSnippetAttachment(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) *models.Attachment {
+	id, _ := strconv.Atoi(mux.Vars(r)["aid"])
+
+	attachment, err := s.attachmentStore.Get(r.Context(), int64(id))
+
+	if err == models.ErrNoRecord || (err == nil && attachment.SnippetID != snippet.ID) {
+		s.notFound(w, r)
+		return nil
+	} else if err != nil {
+		s.serverError(w, r, err)
+		return nil
+	}
+
+	return attachment
+}
+
+//removeBlobs delete content of attachments, failures are only logged, metadata is already removed.
+//Blobs are removed even if client disconnects, nothing points to them after that
+func (s *Server) removeBlobs(r *http.Request, attachments []*models.Attachment) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), blobDeleteTimeout)
+	defer cancel()
+
+	for _, a := range attachments {
+		if err := s.attachments.store.Delete(ctx, a.Key); err != nil {
+			s.logger(r).Errorf("Error while delete blob %s of attachment %d: %v", a.Key, a.ID, err)
+		}
+	}
+}
+
+//uploadAttachment save file from form as attachment of own snippet
+func (s *Server) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	snippet := s.getOwnSnippet(w, r)
+
+	if snippet == nil {
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+
+	if err != nil {
+		var maxErr *http.MaxBytesError
+
+		if errors.As(err, &maxErr) {
+			s.clientError(w, r, http.StatusRequestEntityTooLarge, "Attachment is too large")
+		} else {
+			s.clientError(w, r, http.StatusBadRequest, "Choose file to attach")
+		}
+		return
+	}
+
+	defer file.Close()
+
+	if header.Size > s.attachments.maxSize {
+		s.clientError(w, r, http.StatusRequestEntityTooLarge, "Attachment is too large")
+		return
+	}
+
+	contentType, err := detectContentType(file)
+
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	if !slices.Contains(s.attachments.types, contentType) {
+		s.clientError(w, r, http.StatusUnsupportedMediaType, fmt.Sprintf("Attachments of type %s are not allowed", contentType))
+		return
+	}
+
+	attachments, err := s.attachmentStore.List(r.Context(), snippet.ID)
+
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	if len(attachments) >= maxSnippetAttachments {
+		s.clientError(w, r, http.StatusBadRequest, fmt.Sprintf("Snippet can't have more than %d attachments", maxSnippetAttachments))
+		return
+	}
+
+	attachment := &models.Attachment{
+		SnippetID:   snippet.ID,
+		Filename:    attachmentName(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		Key:         newBlobKey(snippet.ID),
+	}
+
+	if err := s.attachments.store.Put(r.Context(), attachment.Key, file, attachment.Size, attachment.ContentType); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	if attachment.ID, err = s.attachmentStore.Insert(r.Context(), attachment); err != nil {
+		s.removeBlobs(r, []*models.Attachment{attachment})
+		s.serverError(w, r, err)
+		return
+	}
+
+	s.audit(r, &models.AuditEvent{
+		ActorID:    snippet.OwnerID,
+		Action:     models.AuditAttachmentUpload,
+		TargetType: "snippet",
+		TargetID:   snippet.ID,
+		Details:    "filename=" + attachment.Filename,
+	})
+
+	if err = s.addFlashMessage(w, r, "Attachment "+attachment.Filename+" is uploaded"); err != nil {
+		s.logger(r).Errorf("Error while add flash message: %v", err)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", snippet.ID), 303)
+}
+
+//downloadAttachment return content of attachment, it's visible to whoever can see the snippet
+func (s *Server) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	snippet := s.getVisibleSnippet(w, r)
+
+	if snippet == nil {
+		return
+	}
+
+	attachment := s.getSnippetAttachment(w, r, snippet)
+
+	if attachment == nil {
+		return
+	}
+
+	setSnippetCacheControl(w, snippet)
+	if checkNotModified(w, r, fmt.Sprintf(`"attachment-%d"`, attachment.ID), attachment.Created) {
+		return
+	}
+
+	content, err := s.attachments.store.Get(r.Context(), attachment.Key)
+
+	if err != nil {
+		if err == blob.ErrNotFound {
+			s.logger(r).Errorf("Blob %s of attachment %d is missing", attachment.Key, attachment.ID)
+			s.notFound(w, r)
+		} else {
+			s.serverError(w, r, err)
+		}
+		return
+	}
+
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+
+	if _, err := io.Copy(w, content); err != nil {
+		s.logger(r).Errorf("Error while send attachment %d: %v", attachment.ID, err)
+	}
+}
+
+//deleteAttachment remove attachment of own snippet with its content
+func (s *Server) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	snippet := s.getOwnSnippet(w, r)
+
+	if snippet == nil {
+		return
+	}
+
+	attachment := s.getSnippetAttachment(w, r, snippet)
+
+	if attachment == nil {
+		return
+	}
+
+	if err := s.attachmentStore.Delete(r.Context(), attachment.ID); err != nil {
+		if err == models.ErrNoRecord {
+			s.notFound(w, r)
+		} else {
+			s.serverError(w, r, err)
+		}
+		return
+	}
+
+	s.removeBlobs(r, []*models.Attachment{attachment})
+
+	s.audit(r, &models.AuditEvent{
+		ActorID:    snippet.OwnerID,
+		Action:     models.AuditAttachmentDelete,
+		TargetType: "snippet",
+		TargetID:   snippet.ID,
+		Details:    "filename=" + attachment.Filename,
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", snippet.ID), 303)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	blobmock "githib.com/VladimirStepanov/snippetbox/pkg/blob/mock"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+//postFile send multipart form with file and CSRF token, redirects aren't followed
+func postFile(t *testing.T, srv *httptest.Server, url, csrfToken, filename string, content []byte) (int, []byte) {
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	form.WriteField("gorilla.csrf.Token", csrfToken)
+
+	if filename != "" {
+		f, err := form.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(content)
+	}
+
+	form.Close()
+
+	client := srv.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	rs, err := client.Post(url, form.FormDataContentType(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+
+	data := new(bytes.Buffer)
+	data.ReadFrom(rs.Body)
+
+	return rs.StatusCode, data.Bytes()
+}
+
+func TestUploadAttachment(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 1, true, 2), getTestSnippetData(2, 1, true, 1)...)
+	ss = append(ss, getTestSnippetData(3, 1, false, 1)...)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := s.attachmentStore.(*mock.AttachmentStore)
+	blobs := s.attachments.store.(*blobmock.BlobStore)
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+	login(t, srv, "conor@mail.com", "12345678")
+
+	_, _, body := get(fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), t, srv)
+	csrfToken := extractCSRFToken(t, body)
+
+	own := fmt.Sprintf("%s/snippet/%d/attachments", srv.URL, ss[0].ID)
+	gzipped := []byte{0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		Name     string
+		URL      string
+		Filename string
+		Content  []byte
+		WantCode int
+		WantData string
+	}{
+		{"Log", own, "app.log", []byte("panic: runtime error\n"), http.StatusSeeOther, ""},
+		{"Binary with path", own, `C:\dumps\core.gz`, gzipped, http.StatusSeeOther, ""},
+		{"Not owner", fmt.Sprintf("%s/snippet/%d/attachments", srv.URL, ss[1].ID), "app.log", []byte("log"), http.StatusForbidden, ""},
+		{"Private of other user", fmt.Sprintf("%s/snippet/%d/attachments", srv.URL, ss[2].ID), "app.log", []byte("log"), http.StatusNotFound, ""},
+		{"Unknown snippet", srv.URL + "/snippet/100/attachments", "app.log", []byte("log"), http.StatusNotFound, ""},
+		{"No file", own, "", nil, http.StatusBadRequest, "Choose file to attach"},
+		{"HTML", own, "page.html", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType, "text/html are not allowed"},
+		{"Too large", own, "big.log", bytes.Repeat([]byte("a"), int(s.attachments.maxSize)+1), http.StatusRequestEntityTooLarge, "Attachment is too large"},
+		{"Too large with form", own, "big.log", bytes.Repeat([]byte("a"), int(s.attachments.maxSize+uploadFormOverhead)), http.StatusRequestEntityTooLarge, "Attachment is too large"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			code, body := postFile(t, srv, test.URL, csrfToken, test.Filename, test.Content)
+
+			if code != test.WantCode || !strings.Contains(string(body), test.WantData) {
+				t.Fatalf("Want %d %s, Get: %d %s", test.WantCode, test.WantData, code, body)
+			}
+		})
+	}
+
+	if len(attachments.DB) != 2 {
+		t.Fatalf("Want 2 attachments, Get: %d", len(attachments.DB))
+	}
+
+	log, core := attachments.DB[0], attachments.DB[1]
+
+	if log.Filename != "app.log" || log.ContentType != "text/plain" || string(blobs.Blobs[log.Key]) != "panic: runtime error\n" {
+		t.Fatalf("Bad log attachment: %+v", log)
+	}
+
+	if core.Filename != "core.gz" || core.ContentType != "application/x-gzip" || core.Size != int64(len(gzipped)) {
+		t.Fatalf("Bad binary attachment: %+v", core)
+	}
+
+	for i := len(attachments.DB); i < maxSnippetAttachments; i++ {
+		attachments.Insert(context.Background(), &models.Attachment{SnippetID: ss[0].ID, Filename: "a.log"})
+	}
+
+	if code, body := postFile(t, srv, own, csrfToken, "one-more.log", []byte("log")); code != http.StatusBadRequest || !strings.Contains(string(body), "more than 10 attachments") {
+		t.Fatalf("Want attachments limit, Get: %d %s", code, body)
+	}
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 1, true, 2), getTestSnippetData(2, 1, false, 2)...)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := s.attachmentStore.(*mock.AttachmentStore)
+	blobs := s.attachments.store.(*blobmock.BlobStore)
+
+	for _, snippet := range ss {
+		a := &models.Attachment{SnippetID: snippet.ID, Filename: "app log.txt", ContentType: "text/plain", Size: 4, Key: newBlobKey(snippet.ID)}
+		attachments.Insert(context.Background(), a)
+		blobs.Put(context.Background(), a.Key, strings.NewReader("data"), 4, a.ContentType)
+	}
+	attachments.Insert(context.Background(), &models.Attachment{SnippetID: ss[0].ID, Filename: "lost.txt", Key: "snippets/lost"})
+
+	public, private := attachments.DB[0], attachments.DB[1]
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		Name     string
+		URL      string
+		Login    bool
+		WantCode int
+	}{
+		{"Public", fmt.Sprintf("/snippet/%d/attachments/%d", ss[0].ID, public.ID), false, http.StatusOK},
+		{"Private anonymous", fmt.Sprintf("/snippet/%d/attachments/%d", ss[1].ID, private.ID), false, http.StatusNotFound},
+		{"Private by public snippet", fmt.Sprintf("/snippet/%d/attachments/%d", ss[0].ID, private.ID), false, http.StatusNotFound},
+		{"Missing blob", fmt.Sprintf("/snippet/%d/attachments/3", ss[0].ID), false, http.StatusNotFound},
+		{"Unknown attachment", fmt.Sprintf("/snippet/%d/attachments/100", ss[0].ID), false, http.StatusNotFound},
+		{"Private owner", fmt.Sprintf("/snippet/%d/attachments/%d", ss[1].ID, private.ID), true, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			if test.Login {
+				login(t, srv, "conor@mail.com", "12345678")
+			}
+
+			code, header, body := get(srv.URL+test.URL, t, srv)
+
+			if code != test.WantCode {
+				t.Fatalf("Want %d, Get: %d", test.WantCode, code)
+			}
+
+			if code != http.StatusOK {
+				return
+			}
+
+			if string(body) != "data" || header.Get("Content-Type") != "text/plain" || header.Get("Content-Disposition") != `attachment; filename="app log.txt"` {
+				t.Fatalf("Bad download: %v %s", header, body)
+			}
+		})
+	}
+
+	code, _, body := get(fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK || !strings.Contains(string(body), fmt.Sprintf("/snippet/%d/attachments/%d", ss[0].ID, public.ID)) || !strings.Contains(string(body), "4 B") {
+		t.Fatalf("Want attachments on snippet page, Get: %d %s", code, body)
+	}
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 1, true, 2), getTestSnippetData(2, 1, true, 1)...)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := s.attachmentStore.(*mock.AttachmentStore)
+	blobs := s.attachments.store.(*blobmock.BlobStore)
+
+	for _, snippet := range append(ss, ss[0]) {
+		a := &models.Attachment{SnippetID: snippet.ID, Filename: "app.log", Key: newBlobKey(snippet.ID)}
+		attachments.Insert(context.Background(), a)
+		blobs.Put(context.Background(), a.Key, strings.NewReader("data"), 4, "")
+	}
+
+	own, other, second := attachments.DB[0], attachments.DB[1], attachments.DB[2]
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+	login(t, srv, "conor@mail.com", "12345678")
+
+	_, _, body := get(fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), t, srv)
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		Name     string
+		URL      string
+		WantCode int
+	}{
+		{"Not owner", fmt.Sprintf("/snippet/%d/attachments/%d/delete", ss[1].ID, other.ID), http.StatusForbidden},
+		{"Attachment of other snippet", fmt.Sprintf("/snippet/%d/attachments/%d/delete", ss[0].ID, other.ID), http.StatusNotFound},
+		{"Own", fmt.Sprintf("/snippet/%d/attachments/%d/delete", ss[0].ID, own.ID), http.StatusSeeOther},
+		{"Deleted", fmt.Sprintf("/snippet/%d/attachments/%d/delete", ss[0].ID, own.ID), http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			code, _, _ := postForm(map[string][]string{"gorilla.csrf.Token": {csrfToken}}, srv.URL+test.URL, t, srv)
+
+			if code != test.WantCode {
+				t.Fatalf("Want %d, Get: %d", test.WantCode, code)
+			}
+		})
+	}
+
+	if _, ok := blobs.Blobs[own.Key]; ok || len(blobs.Blobs) != 2 {
+		t.Fatalf("Want only blob of deleted attachment removed, Get: %v", blobs.Blobs)
+	}
+
+	hash := extractLogoutHash(t, body)
+
+	if code, _, _ := get(fmt.Sprintf("%s/snippet/delete/%d?hash=%s", srv.URL, ss[0].ID, hash), t, srv); code != http.StatusSeeOther {
+		t.Fatalf("Want snippet deleted, Get: %d", code)
+	}
+
+	if _, ok := blobs.Blobs[second.Key]; ok || len(blobs.Blobs) != 1 {
+		t.Fatalf("Want blobs of deleted snippet removed, Get: %v", blobs.Blobs)
+	}
+}
+
+//ctxBlobStore fail delete with error of context
+type ctxBlobStore struct {
+	*blobmock.BlobStore
+}
+
+func (bs *ctxBlobStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return bs.BlobStore.Delete(ctx, key)
+}
+
+func TestRemoveBlobsCanceledRequest(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+	blobs := &blobmock.BlobStore{Blobs: map[string][]byte{"snippets/1/a": []byte("log")}}
+	s.attachments.store = &ctxBlobStore{blobs}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s.removeBlobs(httptest.NewRequest("POST", "/snippet/delete", nil).WithContext(ctx), []*models.Attachment{{ID: 1, Key: "snippets/1/a"}})
+
+	if len(blobs.Blobs) != 0 {
+		t.Fatalf("Blob of canceled request is not removed")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/cache"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+//cacheBackends are values of CACHE_BACKEND
+var cacheBackends = []string{"none", "memory", "redis"}
+
+//redisKeyPrefix separate keys of application in shared Redis
+const redisKeyPrefix = "snippetbox:"
+
+//withCache put read-through cache in front of store, returned function closes connection to cache backend.
+//Unavailable Redis isn't fatal: lookups are counted as errors and go to store.
+func withCache(ctx context.Context, c cacheConfig, store models.SnippetRepository, m *metrics, log *logrus.Logger) (models.SnippetRepository, func() error, error) {
+	var backend cache.Backend
+	closeBackend := func() error { return nil }
+
+	switch c.backend {
+	case "none":
+		return store, closeBackend, nil
+	case "memory":
+		backend = cache.NewLRU(c.size)
+	case "redis":
+		opts, err := redis.ParseURL(c.redisURL)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		client := redis.NewClient(opts)
+		closeBackend = client.Close
+
+		pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+
+		if err := client.Ping(pingCtx).Err(); err != nil {
+			log.Warnf("Redis cache is unavailable, snippets are read from database: %v", err)
+		}
+
+		backend = &cache.Redis{Client: client, Prefix: redisKeyPrefix}
+	}
+
+	return &cache.SnippetStore{
+		Store:    store,
+		Backend:  backend,
+		TTL:      c.ttl,
+		Observer: m.observeCache,
+	}, closeBackend, nil
+}
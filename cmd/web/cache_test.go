@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/cache"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWithCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	tests := map[string]struct {
+		config     cacheConfig
+		wantCached bool
+	}{
+		"None":   {cacheConfig{backend: "none"}, false},
+		"Memory": {cacheConfig{backend: "memory", size: 10, ttl: time.Minute}, true},
+		"Redis":  {cacheConfig{backend: "redis", ttl: time.Minute, redisURL: "redis://" + mr.Addr() + "/0"}, true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := newMetrics()
+			ss := getTestSnippetData(1, 1, true, 1)
+
+			store, closeCache, err := withCache(context.Background(), test.config, &mock.SnippetStore{DB: ss}, m, log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeCache()
+
+			if _, ok := store.(*cache.SnippetStore); ok != test.wantCached {
+				t.Fatalf("Want cached: %v, Get: %T", test.wantCached, store)
+			}
+
+			for i := 0; i < 2; i++ {
+				if _, err := store.Get(context.Background(), ss[0].ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want := map[string]float64{cache.Hit: 0, cache.Miss: 0}
+			if test.wantCached {
+				want = map[string]float64{cache.Hit: 1, cache.Miss: 1}
+			}
+
+			for result, count := range want {
+				if got := testutil.ToFloat64(m.cacheRequests.WithLabelValues("snippets.get", result)); got != count {
+					t.Errorf("%s: want %v, get %v", result, count, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWithCacheRedisDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	ss := getTestSnippetData(1, 1, true, 1)
+	store, closeCache, err := withCache(context.Background(), cacheConfig{backend: "redis", ttl: time.Minute, redisURL: "redis://" + addr}, &mock.SnippetStore{DB: ss}, newMetrics(), log)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeCache()
+
+	if _, err := store.Get(context.Background(), ss[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get(context.Background(), 100500); err != models.ErrNoRecord {
+		t.Fatalf("Want: %v, Get: %v", models.ErrNoRecord, err)
+	}
+}
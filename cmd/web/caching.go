@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//publicCacheControl let shared caches keep public snippets for a short time, edits become visible within a minute
+const publicCacheControl = "public, max-age=60"
+
+//privateCacheControl keep response only in browser and revalidate it on every use
+const privateCacheControl = "private, no-cache"
+
+//etagMatch report if If-None-Match header contains etag, weak comparison is used
+func etagMatch(header, etag string) bool {
+	for _, val := range strings.Split(header, ",") {
+		val = strings.TrimSpace(val)
+		if val == "*" || strings.TrimPrefix(val, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+//checkNotModified set ETag and Last-Modified, if client has the same version 304 is sent and true is returned
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	h := w.Header()
+	h.Set("ETag", etag)
+
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatch(inm, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+//setSnippetCacheControl choose caching policy for snippet content, public snippet can be stored by shared caches
+//unless response sets cookie, e.g. CSRF cookie for new visitor, shared cache would give it to everyone
+func setSnippetCacheControl(w http.ResponseWriter, snippet *models.Snippet) {
+	w.Header().Add("Vary", "Cookie")
+
+	if snippet.IsPublic && w.Header().Get("Set-Cookie") == "" {
+		w.Header().Set("Cache-Control", publicCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", privateCacheControl)
+	}
+}
+
+//rawETag is strong validator of raw content, parts are prefixed with length, so moving text between them changes it
+func rawETag(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:8]) + `"`
+}
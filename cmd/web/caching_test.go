@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+//conditionalGet send GET with validators of previous response
+func conditionalGet(t *testing.T, srv *httptest.Server, url string, header map[string]string) *http.Response {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, val := range header {
+		req.Header.Set(key, val)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp
+}
+
+func TestSnippetCaching(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 1, true, 1), getTestSnippetData(2, 1, false, 1)...)
+	store := &mock.SnippetStore{DB: ss, UsersMap: um}
+
+	s, err := NewTestServerWithUI("../../ui/html", store, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	publicPage := fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID)
+	publicRaw := publicPage + "/raw"
+	privatePage := fmt.Sprintf("%s/snippet/%d", srv.URL, ss[1].ID)
+	privateRaw := privatePage + "/raw"
+
+	tests := []struct {
+		name        string
+		url         string
+		login       bool
+		wantCode    int
+		wantCache   string
+		conditional bool
+	}{
+		{"Public page anonymous", publicPage, false, 200, privateCacheControl, false},
+		{"Public raw anonymous", publicRaw, false, 200, publicCacheControl, true},
+		{"Private page anonymous", privatePage, false, 404, "", false},
+		{"Private raw anonymous", privateRaw, false, 404, "", false},
+		{"Public page of owner", publicPage, true, 200, privateCacheControl, false},
+		{"Public raw of owner", publicRaw, true, 200, publicCacheControl, true},
+		{"Private page of owner", privatePage, true, 200, privateCacheControl, false},
+		{"Private raw of owner", privateRaw, true, 200, privateCacheControl, true},
+	}
+
+	loggedIn := false
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.login && !loggedIn {
+				login(t, srv, "vova@mail.com", "12345678")
+				loggedIn = true
+			}
+
+			resp := conditionalGet(t, srv, test.url, nil)
+
+			if resp.StatusCode != test.wantCode {
+				t.Fatalf("Want: %d, Get: %d", test.wantCode, resp.StatusCode)
+			}
+
+			if test.wantCode != 200 {
+				return
+			}
+
+			if got := resp.Header.Get("Cache-Control"); got != test.wantCache {
+				t.Errorf("Cache-Control: want %q, get %q", test.wantCache, got)
+			}
+
+			etag := resp.Header.Get("ETag")
+			modified := resp.Header.Get("Last-Modified")
+
+			if !test.conditional {
+				if etag != "" || modified != "" {
+					t.Errorf("Page has validators, ETag: %q, Last-Modified: %q", etag, modified)
+				}
+				return
+			}
+
+			if etag == "" || modified == "" {
+				t.Fatalf("Validators are not set, ETag: %q, Last-Modified: %q", etag, modified)
+			}
+
+			validators := map[string]map[string]string{
+				"If-None-Match":     {"If-None-Match": etag},
+				"If-Modified-Since": {"If-Modified-Since": modified},
+			}
+
+			for name, header := range validators {
+				if resp := conditionalGet(t, srv, test.url, header); resp.StatusCode != http.StatusNotModified {
+					t.Errorf("%s: want %d, get %d", name, http.StatusNotModified, resp.StatusCode)
+				}
+			}
+
+			if resp := conditionalGet(t, srv, test.url, map[string]string{"If-None-Match": `"other"`}); resp.StatusCode != http.StatusOK {
+				t.Errorf("Other ETag: want %d, get %d", http.StatusOK, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestSnippetETagChanges(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, true, 1)
+	store := &mock.SnippetStore{DB: ss, UsersMap: um}
+
+	s, err := NewTestServerWithUI("../../ui/html", store, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	raw := fmt.Sprintf("%s/snippet/%d/raw", srv.URL, ss[0].ID)
+	etag := conditionalGet(t, srv, raw, nil).Header.Get("ETag")
+
+	time.Sleep(10 * time.Millisecond)
+	err = store.Update(context.Background(), &models.Snippet{ID: ss[0].ID, Title: ss[0].Title, Content: ss[0].Content + " changed", IsPublic: true, Expires: ss[0].Expires}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := conditionalGet(t, srv, raw, map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusOK {
+		t.Errorf("Changed snippet is not modified")
+	}
+}
+
+func TestSnippetCachingWithCookie(t *testing.T) {
+	ss := getTestSnippetData(1, 1, true, 1)
+	s := NewTestServer(&mock.SnippetStore{DB: ss}, &mock.UsersStore{})
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/snippet/%d/raw", ss[0].ID), nil))
+
+	if w.Header().Get("Set-Cookie") == "" {
+		t.Fatal("Response of new visitor has no cookie")
+	}
+
+	if got := w.Header().Get("Cache-Control"); got != privateCacheControl {
+		t.Errorf("Cache-Control: want %q, get %q", privateCacheControl, got)
+	}
+}
+
+func TestRawSnippet(t *testing.T) {
+	ss := getTestSnippetData(1, 1, true, 1)
+	ss[0].Content = "<script>alert(1)</script>"
+
+	s := NewTestServer(&mock.SnippetStore{DB: ss}, &mock.UsersStore{})
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/snippet/%d/raw", ss[0].ID), nil))
+
+	if w.Body.String() != ss[0].Content {
+		t.Errorf("Want: %q, Get: %q", ss[0].Content, w.Body.String())
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type: %s", ct)
+	}
+}
+
+func TestRevalidateCompressed(t *testing.T) {
+	ss := getTestSnippetData(1, 1, true, 1)
+	ss[0].Content = strings.Repeat("compressed content\n", 100)
+
+	s := NewTestServer(&mock.SnippetStore{DB: ss}, &mock.UsersStore{})
+	url := fmt.Sprintf("/snippet/%d/raw", ss[0].ID)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", url, nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	s.routes().ServeHTTP(w, r)
+
+	etag := w.Header().Get("ETag")
+	if w.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(etag, "W/") {
+		t.Fatalf("Want weak ETag of gzip response, Get: %q %q", w.Header().Get("Content-Encoding"), etag)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", url, nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", etag)
+	s.routes().ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified || w.Header().Get("ETag") != etag {
+		t.Fatalf("Want %d with ETag %q, Get: %d %q", http.StatusNotModified, etag, w.Code, w.Header().Get("ETag"))
+	}
+}
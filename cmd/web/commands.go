@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//purgeCommand remove expired snippets once, usage: snippetbox purge [--dry-run]
+func purgeCommand(ctx context.Context, args []string, out io.Writer, config *Config, store models.SnippetRepository, attachments models.AttachmentRepository) error {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	fs.SetOutput(out)
+
+	dryRun := fs.Bool("dry-run", false, "only print count of snippets to remove")
+	grace := fs.Duration("grace", config.purge.grace, "remove snippets expired longer than grace period")
+	batchSize := fs.Int("batch-size", config.purge.batchSize, "max rows removed by one query")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *batchSize < 1 {
+		return fmt.Errorf("batch-size must be greater than zero")
+	}
+
+	if *dryRun {
+		count, err := store.CountExpired(ctx, time.Now().UTC().Add(-*grace))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d expired snippets would be removed\n", count)
+		return nil
+	}
+
+	sw := &sweeper{
+		store:       store,
+		attachments: attachments,
+		blobs:       config.attachments.store,
+		log:         config.log,
+		metrics:     newMetrics(),
+		batchSize:   *batchSize,
+		grace:       *grace,
+	}
+
+	removed, err := sw.sweep(ctx)
+	fmt.Fprintf(out, "%d expired snippets removed\n", removed)
+
+	return err
+}
+
+//configCommand show effective config, usage: snippetbox config print
+func configCommand(args []string, out io.Writer, src *configSource) error {
+	if len(args) != 1 || args[0] != "print" {
+		return fmt.Errorf("usage: snippetbox config print")
+	}
+
+	if err := src.print(out); err != nil {
+		return err
+	}
+
+	if _, err := NewConfig(src); err != nil {
+		return fmt.Errorf("config is invalid: %v", err)
+	}
+
+	return nil
+}
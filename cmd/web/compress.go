@@ -0,0 +1,237 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+//compressMinSize responses smaller than it are sent as is, compression doesn't pay off
+const compressMinSize = 1024
+
+//compressibleTypes prefixes of media types which are compressed, images and fonts are compressed already
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+var gzipPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+//negotiateEncoding choose br or gzip from Accept-Encoding, empty string means identity
+func negotiateEncoding(header string) string {
+	accepted := map[string]bool{}
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+
+		for _, param := range fields[1:] {
+			if val := strings.TrimSpace(param); strings.HasPrefix(val, "q=") {
+				if parsed, err := strconv.ParseFloat(val[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		if coding != "" {
+			accepted[coding] = q > 0
+		}
+	}
+
+	for _, coding := range []string{"br", "gzip"} {
+		if ok, listed := accepted[coding]; ok || (!listed && accepted["*"]) {
+			return coding
+		}
+	}
+
+	return ""
+}
+
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//weakenETag mark ETag as weak, compressed body is not byte-equal to original, weak comparison still matches
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+//compressWriter buffer beginning of response until it is clear if response should be compressed
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	enc      io.WriteCloser
+	gz       *gzip.Writer
+	br       *brotli.Writer
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+
+	if len(cw.buf) >= compressMinSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+//decide write headers and buffered data, compression is started if response is big enough and has compressible type
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.Header()
+
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		//net/http would detect type of compressed data
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if cw.status == http.StatusNotModified {
+		//client may revalidate compressed response, 304 has to carry the same weak ETag
+		weakenETag(h)
+	}
+
+	compress := len(cw.buf) >= compressMinSize &&
+		cw.status != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" &&
+		h.Get("Content-Range") == "" &&
+		isCompressible(h.Get("Content-Type"))
+
+	if !compress {
+		cw.ResponseWriter.WriteHeader(cw.status)
+		_, err := cw.ResponseWriter.Write(cw.buf)
+		return err
+	}
+
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+	weakenETag(h)
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.encoding == "br" {
+		cw.br = brotli.NewWriterLevel(cw.ResponseWriter, 5)
+		cw.enc = cw.br
+	} else {
+		cw.gz = gzipPool.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+		cw.enc = cw.gz
+	}
+
+	_, err := cw.enc.Write(cw.buf)
+	return err
+}
+
+//Flush send buffered data, compression is decided by data written so far
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide()
+	}
+
+	if cw.gz != nil {
+		cw.gz.Flush()
+	} else if cw.br != nil {
+		cw.br.Flush()
+	}
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) close() error {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return nil
+		}
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+
+	if cw.enc == nil {
+		return nil
+	}
+
+	err := cw.enc.Close()
+
+	if cw.gz != nil {
+		gzipPool.Put(cw.gz)
+	}
+
+	return err
+}
+
+//compress encode responses with brotli or gzip if client accepts it
+func (s *Server) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+
+			if encoding == "" || r.Method == "HEAD" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+			next.ServeHTTP(cw, r)
+
+			if err := cw.close(); err != nil {
+				s.logger(r).Warnf("Compressed response isn't written: %v", err)
+			}
+		})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]struct {
+		header string
+		want   string
+	}{
+		"Empty":          {"", ""},
+		"Gzip":           {"gzip", "gzip"},
+		"Brotli":         {"gzip, deflate, br", "br"},
+		"Brotli refused": {"gzip, br;q=0", "gzip"},
+		"Both refused":   {"gzip;q=0, br;q=0", ""},
+		"Any":            {"*", "br"},
+		"Any except br":  {"br;q=0, *", "gzip"},
+		"Identity":       {"identity", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := negotiateEncoding(test.header); got != test.want {
+				t.Errorf("Want: %q, Get: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+
+	big := strings.Repeat("<p>snippet</p>", 200)
+
+	handler := s.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", "2800")
+			w.Write([]byte(big))
+		case "/small":
+			w.Write([]byte("<p>small</p>"))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(big))
+		case "/chunks":
+			for i := 0; i < 200; i++ {
+				w.Write([]byte("<p>snippet</p>"))
+			}
+		case "/not-modified":
+			w.Header().Set("ETag", `"v1"`)
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+
+	tests := map[string]struct {
+		path         string
+		accept       string
+		wantEncoding string
+		wantETag     string
+	}{
+		"Gzip":            {"/big", "gzip", "gzip", `W/"v1"`},
+		"Brotli":          {"/big", "gzip, br", "br", `W/"v1"`},
+		"Not accepted":    {"/big", "", "", `"v1"`},
+		"Small":           {"/small", "gzip", "", ""},
+		"Image":           {"/image", "gzip", "", ""},
+		"Many writes":     {"/chunks", "br", "br", ""},
+		"Not modified":    {"/not-modified", "gzip", "", `W/"v1"`},
+		"Plain 304":       {"/not-modified", "", "", `"v1"`},
+		"Unknown request": {"/none", "gzip", "", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", test.path, nil)
+			r.Header.Set("Accept-Encoding", test.accept)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			resp := w.Result()
+
+			if got := resp.Header.Get("Content-Encoding"); got != test.wantEncoding {
+				t.Fatalf("Content-Encoding: want %q, get %q", test.wantEncoding, got)
+			}
+
+			if got := resp.Header.Get("ETag"); got != test.wantETag {
+				t.Errorf("ETag: want %q, get %q", test.wantETag, got)
+			}
+
+			if resp.Header.Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Vary: Accept-Encoding is not set")
+			}
+
+			var body io.Reader = resp.Body
+
+			switch test.wantEncoding {
+			case "gzip":
+				if resp.Header.Get("Content-Length") != "" {
+					t.Errorf("Content-Length of original body is sent")
+				}
+				gz, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = gz
+			case "br":
+				body = brotli.NewReader(resp.Body)
+			}
+
+			data, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.path == "/big" && string(data) != big {
+				t.Errorf("Decoded body differs from original")
+			}
+		})
+	}
+}
@@ -2,51 +2,439 @@ package main
 
 import (
 	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
-	"githib.com/VladimirStepanov/snippetbox/pkg/common"
+	"githib.com/VladimirStepanov/snippetbox/pkg/blob"
+	"githib.com/VladimirStepanov/snippetbox/pkg/mailer"
 	"github.com/gorilla/sessions"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 )
 
 //Config struct for web application
 type Config struct {
-	addr         string
-	log          *logrus.Logger
-	sessionStore *sessions.CookieStore
-	csrfKey      string
-	dsn          string
+	addr            string
+	log             *logrus.Logger
+	sessionStore    *sessions.CookieStore
+	csrfKey         string
+	dsn             string
+	purge           purgeConfig
+	pool            poolConfig
+	cache           cacheConfig
+	attachments     attachmentConfig
+	mailer          mailer.Mailer
+	baseURL         string
+	remindEvery     time.Duration
+	metricsAddr     string
+	shutdownTimeout time.Duration
+	traceExporter   string
+	queryTimeout    time.Duration
+	devMode         bool
+	ui              fs.FS
+	static          *staticFiles
+	certs           *certReloader
+	redirectAddr    string
+	hstsMaxAge      time.Duration
+	hstsSubdomains  bool
+	trustedProxies  []*net.IPNet
 }
 
-func getLogger(levelString string) (*logrus.Logger, error) {
+type purgeConfig struct {
+	interval  time.Duration
+	batchSize int
+	grace     time.Duration
+}
+
+func getPurgeConfig(src *configSource) (purgeConfig, error) {
+	var err error
+	res := purgeConfig{}
+
+	if res.interval, err = src.Duration("PURGE_INTERVAL"); err != nil {
+		return res, err
+	}
+
+	if res.batchSize, err = src.Int("PURGE_BATCH_SIZE"); err != nil {
+		return res, err
+	}
+
+	if res.batchSize < 1 {
+		return res, fmt.Errorf("PURGE_BATCH_SIZE must be greater than zero")
+	}
+
+	if res.grace, err = src.Duration("PURGE_GRACE_PERIOD"); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+//poolConfig is sql.DB connection pool settings and startup connect deadline
+type poolConfig struct {
+	maxOpen        int
+	maxIdle        int
+	maxLifetime    time.Duration
+	maxIdleTime    time.Duration
+	connectTimeout time.Duration
+}
+
+func getPoolConfig(src *configSource) (poolConfig, error) {
+	var err error
+	res := poolConfig{}
+
+	if res.maxOpen, err = src.Int("DB_MAX_OPEN_CONNS"); err != nil {
+		return res, err
+	}
+
+	if res.maxIdle, err = src.Int("DB_MAX_IDLE_CONNS"); err != nil {
+		return res, err
+	}
+
+	if res.maxOpen > 0 && res.maxIdle > res.maxOpen {
+		return res, fmt.Errorf("DB_MAX_IDLE_CONNS must not be greater than DB_MAX_OPEN_CONNS")
+	}
+
+	if res.maxLifetime, err = src.Duration("DB_CONN_MAX_LIFETIME"); err != nil {
+		return res, err
+	}
+
+	if res.maxIdleTime, err = src.Duration("DB_CONN_MAX_IDLE_TIME"); err != nil {
+		return res, err
+	}
+
+	if res.connectTimeout, err = src.Duration("DB_CONNECT_TIMEOUT"); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+type cacheConfig struct {
+	backend  string
+	size     int
+	ttl      time.Duration
+	redisURL string
+}
+
+func getCacheConfig(src *configSource) (cacheConfig, error) {
+	var err error
+	res := cacheConfig{backend: src.String("CACHE_BACKEND"), redisURL: src.String("REDIS_URL")}
+
+	if !slices.Contains(cacheBackends, res.backend) {
+		return res, fmt.Errorf("CACHE_BACKEND: unknown backend %q, want one of %v", res.backend, cacheBackends)
+	}
+
+	if res.size, err = src.Int("CACHE_SIZE"); err != nil {
+		return res, err
+	}
+
+	if res.size < 1 {
+		return res, fmt.Errorf("CACHE_SIZE must be greater than zero")
+	}
+
+	if res.ttl, err = src.Duration("CACHE_TTL"); err != nil {
+		return res, err
+	}
+
+	if res.ttl <= 0 {
+		return res, fmt.Errorf("CACHE_TTL must be greater than zero")
+	}
+
+	if res.backend == "redis" {
+		if res.redisURL == "" {
+			return res, fmt.Errorf("REDIS_URL must be set for redis cache backend")
+		}
+		if _, err := redis.ParseURL(res.redisURL); err != nil {
+			return res, fmt.Errorf("REDIS_URL: %v", err)
+		}
+	}
+
+	return res, nil
+}
+
+//attachmentConfig limits of uploaded attachments and storage of their content
+type attachmentConfig struct {
+	maxSize int64
+	types   []string
+	store   blob.BlobStore
+}
+
+func getAttachmentConfig(src *configSource) (attachmentConfig, error) {
+	res := attachmentConfig{}
+
+	maxSize, err := src.Int("ATTACHMENT_MAX_SIZE")
+	if err != nil {
+		return res, err
+	}
+
+	if maxSize < 1 {
+		return res, fmt.Errorf("ATTACHMENT_MAX_SIZE must be greater than zero")
+	}
+
+	res.maxSize = int64(maxSize)
+
+	for _, t := range strings.Split(src.String("ATTACHMENT_TYPES"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			res.types = append(res.types, t)
+		}
+	}
+
+	switch backend := src.String("BLOB_BACKEND"); backend {
+	case "local":
+		if src.String("BLOB_DIR") == "" {
+			return res, fmt.Errorf("BLOB_DIR must be set for local blob backend")
+		}
+		res.store = &blob.LocalStore{Dir: src.String("BLOB_DIR")}
+	case "s3":
+		useSSL, err := src.Bool("S3_USE_SSL")
+		if err != nil {
+			return res, err
+		}
+
+		if src.String("S3_ENDPOINT") == "" || src.String("S3_BUCKET") == "" {
+			return res, fmt.Errorf("S3_ENDPOINT and S3_BUCKET must be set for s3 blob backend")
+		}
+
+		res.store, err = blob.NewS3Store(
+			src.String("S3_ENDPOINT"),
+			src.String("S3_REGION"),
+			src.String("S3_ACCESS_KEY"),
+			src.String("S3_SECRET_KEY"),
+			src.String("S3_BUCKET"),
+			useSSL,
+		)
+
+		if err != nil {
+			return res, fmt.Errorf("S3_ENDPOINT: %v", err)
+		}
+	default:
+		return res, fmt.Errorf("BLOB_BACKEND: unknown backend %q, want one of %v", backend, blobBackends)
+	}
+
+	return res, nil
+}
+
+func getLogger(levelString, format string) (*logrus.Logger, error) {
 	log := logrus.New()
 	level, err := logrus.ParseLevel(levelString)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("LOG_LEVEL: %v", err)
 	}
 
 	log.SetLevel(level)
 
+	switch format {
+	case "text":
+	case "json":
+		log.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT: unknown format %q, want text or json", format)
+	}
+
 	return log, nil
 }
 
-//NewConfig ...
-func NewConfig() (*Config, error) {
+//minSecretLength is minimal length of SESSION_KEY and CSRF_KEY in production
+const minSecretLength = 32
+
+//isPlaceholder report whether secret is example value like the one of config.example.yaml
+func isPlaceholder(secret string) bool {
+	secret = strings.ToLower(secret)
+	return strings.Contains(secret, "change-me") || strings.Contains(secret, "changeme")
+}
+
+//validateSecrets refuse default, placeholder or short secrets in production and warn about them in development
+func validateSecrets(src *configSource, log *logrus.Logger) error {
+	env := src.String("ENV")
+
+	if env != "development" && env != "production" {
+		return fmt.Errorf("ENV: unknown environment %q, want development or production", env)
+	}
+
+	for _, key := range []string{"SESSION_KEY", "CSRF_KEY", "DSN"} {
+		var problem string
+
+		if src.isDefault(key) {
+			problem = "has default value"
+		} else if key != "DSN" && isPlaceholder(src.String(key)) {
+			problem = "is a placeholder"
+		} else if key != "DSN" && len(src.String(key)) < minSecretLength {
+			problem = fmt.Sprintf("is shorter than %d bytes", minSecretLength)
+		} else {
+			continue
+		}
+
+		if env == "production" {
+			return fmt.Errorf("%s %s, it's not allowed in production", key, problem)
+		}
+
+		log.Warnf("%s %s, don't use it in production", key, problem)
+	}
+
+	return nil
+}
+
+//NewConfig build Config from flags, env variables and config file
+func NewConfig(src *configSource) (*Config, error) {
+
+	log, err := getLogger(src.String("LOG_LEVEL"), src.String("LOG_FORMAT"))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateSecrets(src, log); err != nil {
+		return nil, err
+	}
+
+	port, err := src.Int("PORT")
+	if err != nil {
+		return nil, err
+	}
+
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("PORT must be between 1 and 65535")
+	}
 
-	log, err := getLogger(common.GetEnvVariableString("LOG_LEVEL", "INFO"))
+	purge, err := getPurgeConfig(src)
 	if err != nil {
 		return nil, err
 	}
 
-	addr := common.GetEnvVariableString("ADDR", "0.0.0.0")
-	port := common.GetEnvVariableString("PORT", "8080")
+	pool, err := getPoolConfig(src)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := getCacheConfig(src)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := getAttachmentConfig(src)
+	if err != nil {
+		return nil, err
+	}
+
+	remindEvery, err := src.Duration("REMINDER_INTERVAL")
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeout, err := src.Duration("SHUTDOWN_TIMEOUT")
+	if err != nil {
+		return nil, err
+	}
+
+	queryTimeout, err := src.Duration("QUERY_TIMEOUT")
+	if err != nil {
+		return nil, err
+	}
+
+	traceExporter := src.String("TRACE_EXPORTER")
+	if !slices.Contains(traceExporters, traceExporter) {
+		return nil, fmt.Errorf("TRACE_EXPORTER: unknown exporter %q, want one of %v", traceExporter, traceExporters)
+	}
+
+	var certs *certReloader
+	certFile, keyFile := src.String("TLS_CERT_FILE"), src.String("TLS_KEY_FILE")
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	if certFile != "" {
+		if certs, err = newCertReloader(certFile, keyFile, log); err != nil {
+			return nil, fmt.Errorf("TLS_CERT_FILE: %v", err)
+		}
+	}
+
+	redirectAddr := src.String("TLS_REDIRECT_ADDR")
+	if redirectAddr != "" && certs == nil {
+		return nil, fmt.Errorf("TLS_REDIRECT_ADDR requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	hstsMaxAge, err := src.Duration("HSTS_MAX_AGE")
+	if err != nil {
+		return nil, err
+	}
+
+	hstsSubdomains, err := src.Bool("HSTS_INCLUDE_SUBDOMAINS")
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := parseTrustedProxies(src.String("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, err
+	}
+
+	devMode, err := src.Bool("DEV_MODE")
+	if err != nil {
+		return nil, err
+	}
+
+	//conf.env enables DEV_MODE, production must not serve ./ui of working directory
+	if devMode && src.String("ENV") == "production" {
+		log.Warn("DEV_MODE is ignored in production")
+		devMode = false
+	}
+
+	uiFS := uiFiles(devMode)
+	static, err := newStaticFiles(uiFS, !devMode)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionStore := sessions.NewCookieStore([]byte(src.String("SESSION_KEY")))
+	sessionStore.Options.HttpOnly = true
+	sessionStore.Options.SameSite = http.SameSiteLaxMode
+
+	var m mailer.Mailer = &mailer.LogMailer{Log: log}
+
+	if smtpAddr := src.String("SMTP_ADDR"); smtpAddr != "" {
+		m = &mailer.SMTPMailer{
+			Addr:     smtpAddr,
+			From:     src.String("SMTP_FROM"),
+			Username: src.String("SMTP_USERNAME"),
+			Password: src.String("SMTP_PASSWORD"),
+		}
+	}
+
+	baseURL := src.String("BASE_URL")
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", port)
+	}
 
 	return &Config{
-		addr:         fmt.Sprintf("%s:%s", addr, port),
-		log:          log,
-		sessionStore: sessions.NewCookieStore([]byte(common.GetEnvVariableString("SESSION_KEY", "session_key"))),
-		csrfKey:      common.GetEnvVariableString("CSRF_KEY", "csrf_key"),
-		dsn:          common.GetEnvVariableString("DSN", "root:123@/snippetbox?parseTime=true"),
+		addr:            fmt.Sprintf("%s:%d", src.String("ADDR"), port),
+		log:             log,
+		sessionStore:    sessionStore,
+		csrfKey:         src.String("CSRF_KEY"),
+		dsn:             src.String("DSN"),
+		purge:           purge,
+		pool:            pool,
+		cache:           cache,
+		attachments:     attachments,
+		mailer:          m,
+		baseURL:         baseURL,
+		remindEvery:     remindEvery,
+		metricsAddr:     src.String("METRICS_ADDR"),
+		shutdownTimeout: shutdownTimeout,
+		traceExporter:   traceExporter,
+		queryTimeout:    queryTimeout,
+		certs:           certs,
+		redirectAddr:    redirectAddr,
+		hstsMaxAge:      hstsMaxAge,
+		hstsSubdomains:  hstsSubdomains,
+		trustedProxies:  trustedProxies,
+		devMode:         devMode,
+		ui:              uiFS,
+		static:          static,
 	}, nil
 
 }
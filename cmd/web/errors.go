@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+//errorInfo is shown on error page
+type errorInfo struct {
+	Status     int    `json:"status"`
+	Title      string `json:"error"`
+	Message    string `json:"message"`
+	IncidentID string `json:"incident_id,omitempty"`
+}
+
+var errorMessages = map[int]string{
+	http.StatusNotFound:            "The page you are looking for doesn't exist.",
+	http.StatusForbidden:           "You don't have access to this page.",
+	http.StatusMethodNotAllowed:    "This method is not allowed for the page.",
+	http.StatusInternalServerError: "Something went wrong on our side, we are looking into it.",
+}
+
+//wantsJSON report if client prefers JSON to HTML
+func wantsJSON(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return true
+		case "text/html", "application/xhtml+xml":
+			return false
+		}
+	}
+
+	return false
+}
+
+//errorPage write error as JSON or html page rendered through base layout
+func (s *Server) errorPage(w http.ResponseWriter, r *http.Request, info *errorInfo) {
+	info.Title = http.StatusText(info.Status)
+	if info.Message == "" {
+		info.Message = errorMessages[info.Status]
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(info.Status)
+		json.NewEncoder(w).Encode(info)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	cache, _ := s.getTemplates()
+	tmpl, ok := cache["error.page.html"]
+
+	if ok {
+		td := s.addDefaultData(&templateData{Error: info, Title: info.Title})
+		td.User = getAuthUserFromRequest(r)
+		td.CSPNonce = getCSPNonce(r)
+
+		if err := tmpl.ExecuteTemplate(buf, "error.page.html", td); err != nil {
+			s.logger(r).Errorf("Error page isn't rendered: %v", err)
+			ok = false
+		}
+	}
+
+	//templates are broken or not loaded, plain text is better than nothing
+	if !ok {
+		text := info.Title + "\n" + info.Message
+		if info.IncidentID != "" {
+			text += "\nIncident ID: " + info.IncidentID
+		}
+		http.Error(w, text, info.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(info.Status)
+	buf.WriteTo(w)
+}
+
+//clientError show error page with status and message, default message of status is used if it is empty
+func (s *Server) clientError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	s.errorPage(w, r, &errorInfo{Status: status, Message: message})
+}
+
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	s.clientError(w, r, http.StatusNotFound, "")
+}
+
+func (s *Server) forbidden(w http.ResponseWriter, r *http.Request) {
+	s.clientError(w, r, http.StatusForbidden, "")
+}
+
+func (s *Server) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	s.clientError(w, r, http.StatusMethodNotAllowed, "")
+}
+
+//csrfFailure is called by csrf middleware when token is missing or invalid
+func (s *Server) csrfFailure(w http.ResponseWriter, r *http.Request) {
+	s.clientError(w, r, http.StatusForbidden, "Form has expired or is invalid, please reload the page and try again.")
+}
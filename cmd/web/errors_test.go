@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/sirupsen/logrus"
+)
+
+//failingStore return error for every Get
+type failingStore struct {
+	*mock.SnippetStore
+}
+
+func (s *failingStore) Get(ctx context.Context, snippetID int64) (*models.Snippet, error) {
+	return nil, errors.New("database is down")
+}
+
+func TestErrorPages(t *testing.T) {
+	um := getTestUserData()
+
+	s, err := NewTestServerWithUI("../../ui/html", &failingStore{&mock.SnippetStore{UsersMap: um}}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	tests := map[string]struct {
+		method   string
+		path     string
+		accept   string
+		wantCode int
+		wantSee  string
+	}{
+		"Not found":                 {"GET", "/unknown", "text/html", 404, "The page you are looking for doesn&#39;t exist."},
+		"Not found by handler":      {"GET", "/snippet/delete/1?hash=bad", "", 404, "404 Not Found"},
+		"Method not allowed":        {"GET", "/snippet/expire/1", "text/html", 405, "405 Method Not Allowed"},
+		"Forbidden":                 {"GET", "/admin/audit", "text/html", 403, "You don&#39;t have access to this page."},
+		"Internal error":            {"GET", "/snippet/1", "text/html", 500, "incident ID"},
+		"Bad CSRF":                  {"POST", "/snippet/create", "text/html", 403, "please reload the page"},
+		"JSON not found":            {"GET", "/unknown", "application/json", 404, `"error":"Not Found"`},
+		"JSON preferred":            {"GET", "/unknown", "application/json, text/html", 404, `"status":404`},
+		"HTML preferred":            {"GET", "/unknown", "text/html, application/json", 404, "<html"},
+		"JSON static not found":     {"GET", "/static/css/none.css", "application/json", 404, `"error":"Not Found"`},
+		"HTML static not found":     {"GET", "/static/css/none.css", "text/html", 404, "<html"},
+		"JSON for API path":         {"GET", "/api/unknown", "", 404, `"message":`},
+		"JSON method not allowed":   {"GET", "/snippet/expire/1", "application/json", 405, `"error":"Method Not Allowed"`},
+		"JSON for internal error":   {"GET", "/snippet/1", "application/json", 500, `"incident_id":`},
+		"Page has layout and links": {"GET", "/unknown", "", 404, "Logout (Conor)"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, srv.URL+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", test.accept)
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != test.wantCode {
+				t.Fatalf("Want: %d, Get: %d", test.wantCode, resp.StatusCode)
+			}
+
+			if !strings.Contains(string(body), test.wantSee) {
+				t.Errorf("%q not found in %s", test.wantSee, body)
+			}
+		})
+	}
+}
+
+func TestIncidentIDLogged(t *testing.T) {
+	s, err := NewTestServerWithUI("../../ui/html", &failingStore{&mock.SnippetStore{}}, &mock.UsersStore{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	s.log.SetOutput(&logs)
+	s.log.SetFormatter(&logrus.JSONFormatter{})
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	code, _, body := get(srv.URL+"/snippet/1", t, srv)
+
+	if code != http.StatusInternalServerError {
+		t.Fatalf("Want: %d, Get: %d", http.StatusInternalServerError, code)
+	}
+
+	m := regexp.MustCompile(`<code>([0-9a-f]+)</code>`).FindSubmatch(body)
+	if m == nil {
+		t.Fatalf("Incident ID not found in %s", body)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+
+		if entry["incident_id"] == string(m[1]) {
+			if entry["stack"] == nil || !strings.Contains(fmt.Sprint(entry["msg"]), "database is down") {
+				t.Errorf("Entry with incident ID has no stack or error: %s", line)
+			}
+			return
+		}
+	}
+
+	t.Fatalf("Incident ID %s not logged: %s", m[1], logs.String())
+}
+
+func TestWantsJSON(t *testing.T) {
+	tests := map[string]struct {
+		path   string
+		accept string
+		want   bool
+	}{
+		"Browser":    {"/", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		"Empty":      {"/", "", false},
+		"Any":        {"/", "*/*", false},
+		"JSON":       {"/", "application/json", true},
+		"JSON first": {"/", "application/json, text/html", true},
+		"API path":   {"/api/snippets", "", true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", (&url.URL{Path: test.path}).String(), nil)
+			r.Header.Set("Accept", test.accept)
+
+			if got := wantsJSON(r); got != test.want {
+				t.Errorf("Want: %v, Get: %v", test.want, got)
+			}
+		})
+	}
+}
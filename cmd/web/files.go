@@ -0,0 +1,216 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gorilla/mux"
+)
+
+//maxSnippetFiles limit of files in snippet besides its content
+const maxSnippetFiles = 10
+
+//fileLanguages chroma lexers which can be chosen for snippet file, empty is plain text
+var fileLanguages = []string{
+	"", "bash", "c", "cpp", "css", "diff", "docker", "go", "html", "ini", "java", "javascript",
+	"json", "makefile", "markdown", "python", "ruby", "rust", "sql", "toml", "typescript", "yaml",
+}
+
+//filenameRX allow names which are safe in URL path and zip archive
+var filenameRX = regexp.MustCompile(`^[\w.-]{1,100}$`)
+
+//reservedFilenames can't be used for snippet files: content is in zip archive as snippet.txt or snippet.md
+var reservedFilenames = []string{".", "..", "snippet.txt", "snippet.md"}
+
+var fileFormatter = chromahtml.New(chromahtml.WithClasses(true))
+
+//fileForm is snippet file in create and edit form
+type fileForm struct {
+	Filename string
+	Language string
+	Content  string
+}
+
+//parseFileForms read files from form, rows with all empty fields are skipped, so file is removed by clearing it
+func parseFileForms(r *http.Request) []*fileForm {
+	names, languages, contents := r.PostForm["file_name"], r.PostForm["file_language"], r.PostForm["file_content"]
+	files := []*fileForm{}
+
+	for i := range names {
+		file := &fileForm{Filename: names[i]}
+
+		if i < len(languages) {
+			file.Language = languages[i]
+		}
+
+		if i < len(contents) {
+			file.Content = contents[i]
+		}
+
+		if file.Filename != "" || file.Content != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+//newFileForms return files of snippet for edit form
+func newFileForms(files []*models.SnippetFile) []*fileForm {
+	res := make([]*fileForm, len(files))
+	for i, file := range files {
+		res[i] = &fileForm{Filename: file.Filename, Language: file.Language, Content: file.Content}
+	}
+	return res
+}
+
+//validateFiles check files of snippet form, error tells which file is wrong
+func validateFiles(value interface{}) error {
+	files, _ := value.([]*fileForm)
+
+	if len(files) > maxSnippetFiles {
+		return fmt.Errorf("no more than %d files are allowed", maxSnippetFiles)
+	}
+
+	names := map[string]bool{}
+
+	for i, file := range files {
+		err := validation.ValidateStruct(file,
+			validation.Field(&file.Filename, validation.Required, validation.Match(filenameRX), validation.NotIn(toInterfaces(reservedFilenames)...)),
+			validation.Field(&file.Language, validation.In(toInterfaces(fileLanguages)...)),
+			validation.Field(&file.Content, validation.Required),
+		)
+
+		if err != nil {
+			return fmt.Errorf("file %d: %v", i+1, err)
+		}
+
+		if names[file.Filename] {
+			return fmt.Errorf("file %d: name %s is used twice", i+1, file.Filename)
+		}
+
+		names[file.Filename] = true
+	}
+
+	return nil
+}
+
+//snippetFilesOf return files of form for saving
+func snippetFilesOf(files []*fileForm) []*models.SnippetFile {
+	res := make([]*models.SnippetFile, len(files))
+	for i, file := range files {
+		res[i] = &models.SnippetFile{Filename: file.Filename, Language: file.Language, Content: file.Content}
+	}
+	return res
+}
+
+//highlight render file content as HTML with chroma classes, content is escaped by formatter
+func highlight(content, language string) (template.HTML, error) {
+	lexer := lexers.Fallback
+	if language != "" {
+		if l := lexers.Get(language); l != nil {
+			lexer = l
+		}
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err := fileFormatter.Format(buf, styles.Get(highlightStyle), iterator); err != nil {
+		return "", err
+	}
+
+	return template.HTML(buf.String()), nil
+}
+
+//archiveFiles return content and files of snippet as they are stored in zip archive
+func archiveFiles(snippet *models.Snippet) []*models.SnippetFile {
+	content := &models.SnippetFile{Filename: "snippet.txt", Content: snippet.Content}
+	if snippet.IsMarkdown() {
+		content.Filename = "snippet.md"
+	}
+
+	return append([]*models.SnippetFile{content}, snippet.Files...)
+}
+
+//rawFile return content of snippet file as plain text
+func (s *Server) rawFile(w http.ResponseWriter, r *http.Request) {
+	snippet := s.getVisibleSnippet(w, r)
+
+	if snippet == nil {
+		return
+	}
+
+	file := snippet.File(mux.Vars(r)["filename"])
+
+	if file == nil {
+		s.notFound(w, r)
+		return
+	}
+
+	setSnippetCacheControl(w, snippet)
+	if checkNotModified(w, r, rawETag(file.Content), snippet.Updated) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(file.Content))
+}
+
+//zipSnippet download content and files of snippet as zip archive
+func (s *Server) zipSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := s.getVisibleSnippet(w, r)
+
+	if snippet == nil {
+		return
+	}
+
+	files := archiveFiles(snippet)
+	parts := []string{}
+
+	for _, file := range files {
+		parts = append(parts, file.Filename, file.Content)
+	}
+
+	setSnippetCacheControl(w, snippet)
+	if checkNotModified(w, r, rawETag(parts...), snippet.Updated) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snippet-%d.zip"`, snippet.ID))
+
+	archive := zip.NewWriter(w)
+
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: file.Filename, Method: zip.Deflate, Modified: snippet.Updated})
+
+		if err == nil {
+			_, err = f.Write([]byte(file.Content))
+		}
+
+		if err != nil {
+			s.logger(r).Errorf("Error while write zip of snippet %d: %v", snippet.ID, err)
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		s.logger(r).Errorf("Error while write zip of snippet %d: %v", snippet.ID, err)
+	}
+}
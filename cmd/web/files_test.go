@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+func TestValidateFiles(t *testing.T) {
+	tooMany := []*fileForm{}
+	for i := 0; i <= maxSnippetFiles; i++ {
+		tooMany = append(tooMany, &fileForm{Filename: fmt.Sprintf("%d.txt", i), Content: "1"})
+	}
+
+	tests := map[string]struct {
+		Files   []*fileForm
+		WantErr string
+	}{
+		"No files":       {[]*fileForm{}, ""},
+		"Valid":          {[]*fileForm{{"main.go", "go", "package main"}, {"Makefile", "makefile", "all:"}}, ""},
+		"Too many":       {tooMany, "no more than"},
+		"Empty name":     {[]*fileForm{{"", "", "1"}}, "file 1: Filename: cannot be blank"},
+		"Path in name":   {[]*fileForm{{"a.go", "go", "1"}, {"../a.go", "go", "1"}}, "file 2: Filename: must be in a valid format"},
+		"Reserved name":  {[]*fileForm{{"snippet.md", "", "1"}}, "file 1: Filename: must not be in list"},
+		"Empty content":  {[]*fileForm{{"a.go", "go", ""}}, "file 1: Content: cannot be blank"},
+		"Bad language":   {[]*fileForm{{"a.go", "cobol++", "1"}}, "file 1: Language: must be a valid value"},
+		"Duplicate name": {[]*fileForm{{"a.go", "go", "1"}, {"a.go", "", "2"}}, "file 2: name a.go is used twice"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateFiles(test.Files)
+
+			if test.WantErr == "" {
+				if err != nil {
+					t.Fatalf("Want no error, Get: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.WantErr) {
+				t.Fatalf("Want error %s, Get: %v", test.WantErr, err)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	rendered, err := highlight("<script>alert(1)</script>", "html")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(rendered), "<script>") || !strings.Contains(string(rendered), `<pre class="chroma">`) {
+		t.Fatalf("Want escaped highlighted content, Get: %s", rendered)
+	}
+
+	if _, err := highlight("plain", "unknown"); err != nil {
+		t.Fatalf("Want fallback for unknown language, Get: %v", err)
+	}
+}
+
+func TestSnippetFilesPages(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 1, true, 2), getTestSnippetData(2, 1, false, 2)...)
+	for _, snippet := range ss {
+		snippet.Files = []*models.SnippetFile{
+			{SnippetID: snippet.ID, Filename: "main.go", Language: "go", Content: "package main"},
+			{SnippetID: snippet.ID, Filename: "notes.txt", Content: "<b>notes</b>"},
+		}
+	}
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	public, private := ss[0].ID, ss[1].ID
+
+	code, _, body := get(fmt.Sprintf("%s/snippet/%d", srv.URL, public), t, srv)
+
+	for _, want := range []string{"main.go", `<span class="kn">package</span>`, "&lt;b&gt;notes&lt;/b&gt;", fmt.Sprintf("/snippet/%d/raw/notes.txt", public), fmt.Sprintf("/snippet/%d/zip", public)} {
+		if code != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Fatalf("Want %s in snippet page, Get: %d %s", want, code, body)
+		}
+	}
+
+	tests := map[string]struct {
+		URL      string
+		WantCode int
+		WantData string
+	}{
+		"Raw file":             {fmt.Sprintf("/snippet/%d/raw/notes.txt", public), http.StatusOK, "<b>notes</b>"},
+		"Unknown file":         {fmt.Sprintf("/snippet/%d/raw/other.txt", public), http.StatusNotFound, ""},
+		"Raw file of private":  {fmt.Sprintf("/snippet/%d/raw/notes.txt", private), http.StatusNotFound, ""},
+		"Zip of private":       {fmt.Sprintf("/snippet/%d/zip", private), http.StatusNotFound, ""},
+		"Raw file of unknown":  {"/snippet/100/raw/notes.txt", http.StatusNotFound, ""},
+		"Raw content is whole": {fmt.Sprintf("/snippet/%d/raw", public), http.StatusOK, ss[0].Content},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			code, header, body := get(srv.URL+test.URL, t, srv)
+
+			if code != test.WantCode {
+				t.Fatalf("Want %d, Get: %d", test.WantCode, code)
+			}
+
+			if code == http.StatusOK && (string(body) != test.WantData || !strings.HasPrefix(header.Get("Content-Type"), "text/plain")) {
+				t.Fatalf("Want %s, Get: %s %s", test.WantData, header.Get("Content-Type"), body)
+			}
+		})
+	}
+
+	code, header, body := get(fmt.Sprintf("%s/snippet/%d/zip", srv.URL, public), t, srv)
+
+	if code != http.StatusOK || header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("Want zip, Get: %d %s", code, header.Get("Content-Type"))
+	}
+
+	if want := fmt.Sprintf(`attachment; filename="snippet-%d.zip"`, public); header.Get("Content-Disposition") != want {
+		t.Fatalf("Want %s, Get: %s", want, header.Get("Content-Disposition"))
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFiles := map[string]string{"snippet.txt": ss[0].Content, "main.go": "package main", "notes.txt": "<b>notes</b>"}
+
+	if len(archive.File) != len(wantFiles) {
+		t.Fatalf("Want %d files in zip, Get: %d", len(wantFiles), len(archive.File))
+	}
+
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := ioutil.ReadAll(rc)
+		rc.Close()
+
+		if want, ok := wantFiles[f.Name]; !ok || string(data) != want {
+			t.Fatalf("Unexpected file %s in zip: %s", f.Name, data)
+		}
+	}
+}
+
+func TestSnippetFilesForm(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, true, 2)
+	ss[0].Files = []*models.SnippetFile{{SnippetID: ss[0].ID, Filename: "old.go", Language: "go", Content: "package old"}}
+	store := &mock.SnippetStore{DB: ss, UsersMap: um}
+
+	s, err := NewTestServerWithUI("../../ui/html", store, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+	login(t, srv, "conor@mail.com", "12345678")
+
+	code, _, body := get(fmt.Sprintf("%s/snippet/edit/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK || !strings.Contains(string(body), "value='old.go'") || !strings.Contains(string(body), "package old") {
+		t.Fatalf("Want files in edit form, Get: %d %s", code, body)
+	}
+
+	editToken := extractCSRFToken(t, body)
+	_, _, body = get(srv.URL+"/snippet/create", t, srv)
+	createToken := extractCSRFToken(t, body)
+
+	form := func(csrfToken string, names, languages, contents []string) url.Values {
+		return url.Values{
+			"title":              {"title"},
+			"content":            {"content"},
+			"expire":             {"1d"},
+			"type":               {"Public"},
+			"file_name":          names,
+			"file_language":      languages,
+			"file_content":       contents,
+			"gorilla.csrf.Token": {csrfToken},
+		}
+	}
+
+	code, _, body = postForm(form(createToken, []string{"a.go", "a.go"}, []string{"go", "go"}, []string{"1", "2"}), srv.URL+"/snippet/create", t, srv)
+
+	if code != http.StatusOK || !strings.Contains(string(body), "name a.go is used twice") || !strings.Contains(string(body), "value='a.go'") {
+		t.Fatalf("Want form with error, Get: %d %s", code, body)
+	}
+
+	code, _, _ = postForm(form(createToken, []string{"a.go", "", "b.sql"}, []string{"go", "", "sql"}, []string{"package a", "", "select 1"}), srv.URL+"/snippet/create", t, srv)
+
+	if code != http.StatusSeeOther {
+		t.Fatalf("Want %d, Get: %d", http.StatusSeeOther, code)
+	}
+
+	created := store.DB[len(store.DB)-1]
+
+	if len(created.Files) != 2 || created.File("a.go") == nil || created.File("b.sql").Content != "select 1" {
+		t.Fatalf("Want files a.go and b.sql, Get: %v", created.Files)
+	}
+
+	code, _, _ = postForm(form(editToken, []string{"new.go"}, []string{"go"}, []string{"package new"}), fmt.Sprintf("%s/snippet/edit/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusSeeOther {
+		t.Fatalf("Want %d, Get: %d", http.StatusSeeOther, code)
+	}
+
+	if len(ss[0].Files) != 1 || ss[0].File("new.go") == nil {
+		t.Fatalf("Want only new.go after edit, Get: %v", ss[0].Files)
+	}
+}
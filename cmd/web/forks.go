@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//forkOf return copy of snippet owned by user, fork keeps visibility and lifetime of parent.
+//Attachments aren't copied, their content belongs to parent.
+func forkOf(parent *models.Snippet, ownerID int64, now time.Time) *models.Snippet {
+	fork := &models.Snippet{
+		Title:    parent.Title,
+		Content:  parent.Content,
+		OwnerID:  ownerID,
+		IsPublic: parent.IsPublic,
+		Unlisted: parent.Unlisted,
+		Format:   parent.Format,
+		ParentID: parent.ID,
+	}
+
+	if !parent.NeverExpires() {
+		fork.Expires = now.Add(parent.Expires.Sub(parent.Created))
+	}
+
+	for _, file := range parent.Files {
+		fork.Files = append(fork.Files, &models.SnippetFile{Filename: file.Filename, Language: file.Language, Content: file.Content})
+	}
+
+	return fork
+}
+
+//visibleParent return parent of fork if current user can see it. Unlisted parent is shown only to owners,
+//fork owner had its link, while for others the fork page would reveal it
+func (s *Server) visibleParent(r *http.Request, fork *models.Snippet, user *models.User) *models.Snippet {
+	if fork.ParentID == 0 {
+		return nil
+	}
+
+	parent, err := s.snippetStore.Get(r.Context(), fork.ParentID)
+
+	if err != nil {
+		if err != models.ErrNoRecord {
+			s.logger(r).Errorf("Error while get parent of snippet %d: %v", fork.ID, err)
+		}
+		return nil
+	}
+
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+
+	if parent.OwnerID == userID || (parent.IsPublic && (!parent.Unlisted || fork.OwnerID == userID)) {
+		return parent
+	}
+
+	return nil
+}
+
+//createFork save fork of snippet visible to current user, on error response is written and nil returned
+func (s *Server) createFork(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	parent := s.getVisibleSnippet(w, r)
+
+	if parent == nil {
+		return nil
+	}
+
+	currentUser := getAuthUserFromRequest(r)
+	now := time.Now().UTC()
+	fork := forkOf(parent, currentUser.ID, now)
+
+	id, err := s.snippetStore.Insert(r.Context(), fork)
+
+	if err == models.ErrUnknownParent {
+		s.notFound(w, r)
+		return nil
+	} else if err != nil {
+		s.serverError(w, r, err)
+		return nil
+	}
+
+	fork.ID = id
+	fork.Created = now
+
+	s.metrics.snippetsCreated.Inc()
+
+	s.audit(r, &models.AuditEvent{
+		ActorID:    currentUser.ID,
+		Action:     models.AuditSnippetCreate,
+		TargetType: "snippet",
+		TargetID:   id,
+		Details:    fmt.Sprintf("fork_of=%d", parent.ID),
+	})
+
+	return fork
+}
+
+//forkSnippet create fork of snippet and show it
+func (s *Server) forkSnippet(w http.ResponseWriter, r *http.Request) {
+	fork := s.createFork(w, r)
+
+	if fork == nil {
+		return
+	}
+
+	if err := s.addFlashMessage(w, r, fmt.Sprintf("Snippet is forked from #%d", fork.ParentID)); err != nil {
+		s.logger(r).Errorf("Error while add flash message: %v", err)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", fork.ID), 303)
+}
+
+//snippetForks show public forks of snippet
+func (s *Server) snippetForks(w http.ResponseWriter, r *http.Request) {
+	parent := s.getVisibleSnippet(w, r)
+
+	if parent == nil {
+		return
+	}
+
+	list := s.listSnippets(w, r, -1, parent.ID)
+
+	if list == nil {
+		return
+	}
+
+	s.render(w, r, "snippets", &templateData{
+		Title:      fmt.Sprintf("Forks of #%d", parent.ID),
+		Snippets:   list.Page.Snippets,
+		Page:       newPageNav(r.URL, list.Page, list.Options.SortBy()),
+		FormList:   list.Form,
+		SortFields: models.SortFields,
+	})
+}
+
+//apiSnippetForks return public forks of snippet as JSON
+func (s *Server) apiSnippetForks(w http.ResponseWriter, r *http.Request) {
+	parent := s.getVisibleSnippet(w, r)
+
+	if parent == nil {
+		return
+	}
+
+	list := s.listSnippets(w, r, -1, parent.ID)
+
+	if list == nil {
+		return
+	}
+
+	s.writeSnippetList(w, r, list)
+}
+
+//apiForkSnippet create fork of snippet and return it as JSON, request must have X-CSRF-Token header
+func (s *Server) apiForkSnippet(w http.ResponseWriter, r *http.Request) {
+	if getAuthUserFromRequest(r) == nil {
+		s.clientError(w, r, http.StatusUnauthorized, "Login is required for fork")
+		return
+	}
+
+	fork := s.createFork(w, r)
+
+	if fork == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/snippet/%d", fork.ID))
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(newAPISnippet(fork)); err != nil {
+		s.logger(r).Errorf("Error while encode snippet: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+func TestForkOf(t *testing.T) {
+	now := time.Now().UTC()
+	parent := &models.Snippet{
+		ID:       5,
+		Title:    "deploy",
+		Content:  "run it",
+		Created:  now.Add(-time.Hour),
+		Expires:  now.Add(23 * time.Hour),
+		OwnerID:  1,
+		IsPublic: true,
+		Unlisted: true,
+		Format:   models.FormatMarkdown,
+		Files:    []*models.SnippetFile{{ID: 3, SnippetID: 5, Filename: "run.sh", Language: "bash", Content: "echo ok"}},
+	}
+
+	fork := forkOf(parent, 2, now)
+
+	if fork.ParentID != 5 || fork.OwnerID != 2 || fork.Title != "deploy" || fork.Format != models.FormatMarkdown || !fork.IsPublic || !fork.Unlisted {
+		t.Fatalf("Bad fork: %+v", fork)
+	}
+
+	if !fork.Expires.Equal(now.Add(24 * time.Hour)) {
+		t.Fatalf("Want lifetime of parent, Get: %v", fork.Expires.Sub(now))
+	}
+
+	if len(fork.Files) != 1 || fork.Files[0] == parent.Files[0] || fork.Files[0].ID != 0 || fork.Files[0].Content != "echo ok" {
+		t.Fatalf("Want copy of files, Get: %+v", fork.Files)
+	}
+
+	parent.Expires = time.Time{}
+
+	if fork := forkOf(parent, 2, now); !fork.NeverExpires() {
+		t.Fatalf("Want never expiring fork, Get: %v", fork.Expires)
+	}
+}
+
+func TestForkSnippet(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 1, true, 1), getTestSnippetData(2, 1, false, 1)...)
+	store := &mock.SnippetStore{DB: ss, UsersMap: um}
+
+	s, err := NewTestServerWithUI("../../ui/html", store, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	public, private := ss[0].ID, ss[1].ID
+
+	_, _, body := get(fmt.Sprintf("%s/snippet/%d", srv.URL, public), t, srv)
+
+	if strings.Contains(string(body), "value='Fork'") {
+		t.Fatal("Want no fork button for anonymous user")
+	}
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	_, _, body = get(fmt.Sprintf("%s/snippet/%d", srv.URL, public), t, srv)
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		Name     string
+		ID       int64
+		WantCode int
+	}{
+		{"Private of other user", private, http.StatusNotFound},
+		{"Unknown", 100, http.StatusNotFound},
+		{"Public", public, http.StatusSeeOther},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			code, header, _ := postForm(url.Values{"gorilla.csrf.Token": {csrfToken}}, fmt.Sprintf("%s/snippet/%d/fork", srv.URL, test.ID), t, srv)
+
+			if code != test.WantCode {
+				t.Fatalf("Want %d, Get: %d", test.WantCode, code)
+			}
+
+			if code == http.StatusSeeOther && header.Get("Location") != fmt.Sprintf("/snippet/%d", store.DB[len(store.DB)-1].ID) {
+				t.Fatalf("Want redirect to fork, Get: %s", header.Get("Location"))
+			}
+		})
+	}
+
+	fork := store.DB[len(store.DB)-1]
+
+	if len(store.DB) != 3 || fork.ParentID != public || fork.OwnerID != 2 || fork.Content != ss[0].Content {
+		t.Fatalf("Bad fork: %+v", fork)
+	}
+
+	code, _, body := get(fmt.Sprintf("%s/snippet/%d", srv.URL, fork.ID), t, srv)
+
+	if code != http.StatusOK || !strings.Contains(string(body), fmt.Sprintf(`Forked from <a href="/snippet/%d">#%d</a>`, public, public)) {
+		t.Fatalf("Want parent link on fork page, Get: %d %s", code, body)
+	}
+
+	code, _, body = get(fmt.Sprintf("%s/snippet/%d/forks", srv.URL, public), t, srv)
+
+	if code != http.StatusOK || !strings.Contains(string(body), fmt.Sprintf("Forks of #%d", public)) || !strings.Contains(string(body), fmt.Sprintf(`<a href="/snippet/%d">`, fork.ID)) {
+		t.Fatalf("Want fork in forks list, Get: %d %s", code, body)
+	}
+
+	if code, _, _ := get(fmt.Sprintf("%s/snippet/%d/forks", srv.URL, private), t, srv); code != http.StatusNotFound {
+		t.Fatalf("Want 404 for forks of invisible snippet, Get: %d", code)
+	}
+}
+
+func TestForkParentLink(t *testing.T) {
+	um := getTestUserData()
+	parents := append(getTestSnippetData(1, 1, true, 1), getTestSnippetData(2, 1, false, 1)...)
+	parents = append(parents, getTestSnippetData(3, 1, true, 1)...)
+	parents[2].Unlisted = true
+
+	forks := getTestSnippetData(4, 3, true, 2)
+	for i, fork := range forks {
+		fork.ParentID = parents[i].ID
+	}
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: append(parents, forks...), UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name     string
+		Login    string
+		Fork     *models.Snippet
+		WantLink bool
+	}{
+		{"Public parent", "", forks[0], true},
+		{"Private parent", "", forks[1], false},
+		{"Unlisted parent", "", forks[2], false},
+		{"Unlisted parent for fork owner", "conor@mail.com", forks[2], true},
+		{"Private parent for fork owner", "conor@mail.com", forks[1], false},
+		{"Private parent for its owner", "vova@mail.com", forks[1], true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			srv := NewHttptestServer(t, s.routes())
+			defer srv.Close()
+
+			if test.Login != "" {
+				login(t, srv, test.Login, "12345678")
+			}
+
+			code, _, body := get(fmt.Sprintf("%s/snippet/%d", srv.URL, test.Fork.ID), t, srv)
+			link := fmt.Sprintf(`Forked from <a href="/snippet/%d">`, test.Fork.ParentID)
+
+			if code != http.StatusOK || strings.Contains(string(body), link) != test.WantLink {
+				t.Fatalf("Want link: %v, Get: %d %s", test.WantLink, code, body)
+			}
+		})
+	}
+}
+
+func TestAPIForks(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, true, 1)
+	store := &mock.SnippetStore{DB: ss, UsersMap: um}
+
+	s, err := NewTestServerWithUI("../../ui/html", store, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	fork := func(csrfToken string) (int, http.Header, []byte) {
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/snippets/%d/fork", srv.URL, ss[0].ID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-CSRF-Token", csrfToken)
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := ioutil.ReadAll(resp.Body)
+
+		return resp.StatusCode, resp.Header, data
+	}
+
+	login(t, srv, "conor@mail.com", "12345678")
+	_, _, page := get(srv.URL+"/snippet/create", t, srv)
+	csrfToken := extractCSRFToken(t, page)
+
+	code, header, body := fork(csrfToken)
+
+	created := &apiSnippet{}
+	if err := json.Unmarshal(body, created); err != nil {
+		t.Fatalf("Bad JSON %s: %v", body, err)
+	}
+
+	if code != http.StatusCreated || created.ParentID == nil || *created.ParentID != ss[0].ID || created.OwnerID != 2 || header.Get("Location") != fmt.Sprintf("/snippet/%d", created.ID) {
+		t.Fatalf("Bad fork response: %d %v %s", code, header, body)
+	}
+
+	code, _, body = get(fmt.Sprintf("%s/api/snippets/%d/forks", srv.URL, ss[0].ID), t, srv)
+
+	list := &apiSnippetList{}
+	if err := json.Unmarshal(body, list); err != nil {
+		t.Fatalf("Bad JSON %s: %v", body, err)
+	}
+
+	if code != http.StatusOK || list.Total != 1 || list.Snippets[0].ID != created.ID {
+		t.Fatalf("Want fork in list, Get: %d %s", code, body)
+	}
+
+	if code, _, _ = get(fmt.Sprintf("%s/api/snippets/100/forks", srv.URL), t, srv); code != http.StatusNotFound {
+		t.Fatalf("Want 404 for forks of unknown snippet, Get: %d", code)
+	}
+
+	get(fmt.Sprintf("%s/user/logout?hash=%s", srv.URL, extractLogoutHash(t, page)), t, srv)
+
+	if code, _, _ := fork(csrfToken); code != http.StatusUnauthorized {
+		t.Fatalf("Want %d for fork after logout, Get: %d", http.StatusUnauthorized, code)
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
 	validation "github.com/go-ozzo/ozzo-validation"
@@ -32,28 +35,133 @@ func getPage(r *http.Request) (int, error) {
 	return page, nil
 }
 
-func (s *Server) home(w http.ResponseWriter, r *http.Request) {
-	page, err := getPage(r)
+//getPageRequest read cursor of snippets list from "after" or "before" query parameter
+func getPageRequest(r *http.Request, count int) (models.PageRequest, error) {
+	req := models.PageRequest{Count: count}
+	after, before := r.URL.Query().Get("after"), r.URL.Query().Get("before")
+	var err error
+
+	switch {
+	case after != "" && before != "":
+		return req, models.ErrBadCursor
+	case after != "":
+		req.After, err = models.ParseCursor(after)
+	case before != "":
+		req.Before, err = models.ParseCursor(before)
+	}
+
+	return req, err
+}
+
+//expiringSoon period of "expiring soon" filter of snippets lists
+const expiringSoon = 24 * time.Hour
+
+//parseListForm read filter and order of snippets list from query, visibility filter is allowed only in lists of owner
+func parseListForm(r *http.Request, ownerID int64) (*listForm, models.ListOptions, error) {
+	lForm := &listForm{
+		Sort:       r.URL.Query().Get("sort"),
+		Order:      r.URL.Query().Get("order"),
+		Visibility: r.URL.Query().Get("visibility"),
+		Expiring:   r.URL.Query().Get("expiring") != "",
+		From:       r.URL.Query().Get("from"),
+		To:         r.URL.Query().Get("to"),
+	}
+
+	visibilityRule := validation.In(toInterfaces(models.Visibilities)...)
+	if ownerID == -1 {
+		visibilityRule = validation.In()
+	}
+
+	errors := validation.ValidateStruct(lForm,
+		validation.Field(&lForm.Sort, validation.In(toInterfaces(models.SortFields)...)),
+		validation.Field(&lForm.Order, validation.In("asc", "desc")),
+		validation.Field(&lForm.Visibility, visibilityRule),
+		validation.Field(&lForm.From, validation.Date(dateLayout)),
+		validation.Field(&lForm.To, validation.Date(dateLayout)),
+	)
+
+	if errors != nil {
+		return lForm, models.ListOptions{}, errors
+	}
+
+	opts := models.ListOptions{
+		OwnerID:    ownerID,
+		Sort:       lForm.Sort,
+		Asc:        lForm.Order == "asc",
+		Visibility: lForm.Visibility,
+	}
+
+	if lForm.Expiring {
+		//rounded, so lists requested during a minute share cache
+		opts.ExpiresBefore = time.Now().UTC().Add(expiringSoon).Truncate(time.Minute)
+	}
+
+	if lForm.From != "" {
+		opts.CreatedFrom, _ = time.Parse(dateLayout, lForm.From)
+	}
+
+	if lForm.To != "" {
+		to, _ := time.Parse(dateLayout, lForm.To)
+		opts.CreatedTo = to.AddDate(0, 0, 1)
+	}
+
+	return lForm, opts, nil
+}
+
+//snippetList is page of snippets list requested by query
+type snippetList struct {
+	Form    *listForm
+	Options models.ListOptions
+	Page    *models.SnippetPage
+}
+
+//listSnippets return page of snippets list by query of request, parentID limits list to forks of snippet if it isn't 0.
+//On error response is written and nil returned
+func (s *Server) listSnippets(w http.ResponseWriter, r *http.Request, ownerID, parentID int64) *snippetList {
+	lForm, opts, err := parseListForm(r, ownerID)
 	if err != nil {
-		s.serverError(w, err)
-		return
+		s.clientError(w, r, http.StatusBadRequest, err.Error())
+		return nil
 	}
-	snippets, err := s.snippetStore.LatestAll(-1, 10, page)
 
+	opts.ParentID = parentID
+
+	req, err := getPageRequest(r, snippetsPerPage)
 	if err != nil {
-		s.serverError(w, err)
-		return
+		s.clientError(w, r, http.StatusBadRequest, "Invalid page cursor")
+		return nil
 	}
-	s.render(w, r, "snippets", &templateData{Title: "Home", Snippets: snippets})
+
+	page, err := s.snippetStore.List(r.Context(), opts, req)
+
+	if err == models.ErrBadCursor {
+		s.clientError(w, r, http.StatusBadRequest, "Invalid page cursor")
+		return nil
+	} else if err != nil {
+		s.serverError(w, r, err)
+		return nil
+	}
+
+	return &snippetList{Form: lForm, Options: opts, Page: page}
 }
 
-func (s *Server) userSnippets(w http.ResponseWriter, r *http.Request) {
-	page, err := getPage(r)
-	if err != nil {
-		s.serverError(w, err)
+func (s *Server) home(w http.ResponseWriter, r *http.Request) {
+	list := s.listSnippets(w, r, -1, 0)
+
+	if list == nil {
 		return
 	}
 
+	s.render(w, r, "snippets", &templateData{
+		Title:      "Home",
+		Snippets:   list.Page.Snippets,
+		Page:       newPageNav(r.URL, list.Page, list.Options.SortBy()),
+		FormList:   list.Form,
+		SortFields: models.SortFields,
+	})
+}
+
+func (s *Server) userSnippets(w http.ResponseWriter, r *http.Request) {
 	u := getAuthUserFromRequest(r)
 
 	if u == nil {
@@ -61,46 +169,89 @@ func (s *Server) userSnippets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	snippets, err := s.snippetStore.LatestAll(u.ID, 10, page)
+	list := s.listSnippets(w, r, u.ID, 0)
 
-	if err != nil {
-		s.serverError(w, err)
+	if list == nil {
 		return
 	}
-	s.render(w, r, "snippets", &templateData{Title: "My snippets", Snippets: snippets})
+
+	s.render(w, r, "snippets", &templateData{
+		Title:        "My snippets",
+		Snippets:     list.Page.Snippets,
+		Page:         newPageNav(r.URL, list.Page, list.Options.SortBy()),
+		FormList:     list.Form,
+		SortFields:   models.SortFields,
+		Visibilities: models.Visibilities,
+	})
 }
 
-func (s *Server) showSnippet(w http.ResponseWriter, r *http.Request) {
+//getVisibleSnippet return snippet from URL if current user can see it, otherwise error page is shown and nil returned
+func (s *Server) getVisibleSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	snippet, err := s.snippetStore.Get(int64(id))
+	snippet, err := s.snippetStore.Get(r.Context(), int64(id))
 
 	if err != nil {
 		if err == models.ErrNoRecord {
-			http.NotFound(w, r)
+			s.notFound(w, r)
 		} else {
-			s.serverError(w, err)
+			s.serverError(w, r, err)
 		}
-		return
+		return nil
 	}
 
 	currentUser := getAuthUserFromRequest(r)
 
 	if !snippet.IsPublic {
 		if currentUser == nil || currentUser.ID != snippet.OwnerID {
-			http.NotFound(w, r)
-			return
+			s.notFound(w, r)
+			return nil
 		}
 	}
 
+	return snippet
+}
+
+func (s *Server) showSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := s.getVisibleSnippet(w, r)
+
+	if snippet == nil {
+		return
+	}
+
+	currentUser := getAuthUserFromRequest(r)
+
+	attachments, err := s.attachmentStore.List(r.Context(), snippet.ID)
+
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	//view is every shown page of snippet for anonymous user or not owner, raw, zip and API reads are not views
+	if currentUser == nil || currentUser.ID != snippet.OwnerID {
+		if err := s.snippetStore.IncrementViews(r.Context(), snippet.ID); err != nil {
+			s.logger(r).Errorf("Error while count view of snippet %d: %v", snippet.ID, err)
+		}
+	}
+
+	//page has CSP nonce and CSRF token of the request, so it is never served from cache or validated
+	w.Header().Set("Cache-Control", privateCacheControl)
+
 	var templateUser *models.User
 
 	if currentUser != nil && snippet.OwnerID == currentUser.ID {
 		templateUser = currentUser
 	}
 
-	s.render(w, r, "snippet", &templateData{Snippet: snippet, FormUser: templateUser})
+	s.render(w, r, "snippet", &templateData{
+		Snippet:     snippet,
+		Parent:      s.visibleParent(r, snippet, currentUser),
+		Attachments: attachments,
+		FormUser:    templateUser,
+		CSRFField:   csrf.TemplateField(r),
+	})
 }
 
 func (s *Server) signUp(w http.ResponseWriter, r *http.Request) {
@@ -130,7 +281,7 @@ func (s *Server) signUpPOST(w http.ResponseWriter, r *http.Request) {
 		s.render(w, r, "signup", &templateData{Errors: errMap, FormUser: u, CSRFField: csrf.TemplateField(r)})
 		return
 	}
-	_, err := s.userStore.Insert(u.Firstname, u.Lastname, u.Email, u.Password)
+	_, err := s.userStore.Insert(r.Context(), u.Firstname, u.Lastname, u.Email, u.Password)
 
 	if err == models.ErrDuplicateEmail {
 		s.render(
@@ -144,12 +295,14 @@ func (s *Server) signUpPOST(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	} else if err != nil {
-		s.serverError(w, err)
+		s.serverError(w, r, err)
 		return
 	}
 
+	s.metrics.signups.Inc()
+
 	if err := s.addFlashMessage(w, r, "User successfully created! Please log in.. "); err != nil {
-		s.serverError(w, err)
+		s.serverError(w, r, err)
 		return
 	}
 	http.Redirect(w, r, "/user/login", 303)
@@ -173,9 +326,21 @@ func (s *Server) loginPOST(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := s.userStore.Authenticate(u.Email, u.Password)
+	userID, err := s.userStore.Authenticate(r.Context(), u.Email, u.Password)
 
 	if err == models.ErrAuth {
+		event := &models.AuditEvent{Action: models.AuditLoginFailed, Details: "email=" + u.Email}
+
+		//failed login into existing account is shown in activity of its owner
+		if target, err := s.userStore.GetByEmail(r.Context(), u.Email); err == nil {
+			event.TargetType = "user"
+			event.TargetID = target.ID
+		} else if err != models.ErrNoRecord {
+			s.logger(r).Errorf("Error while get user of failed login: %v", err)
+		}
+
+		s.audit(r, event)
+		s.metrics.logins.WithLabelValues("failure").Inc()
 		s.render(
 			w, r,
 			"login",
@@ -187,15 +352,18 @@ func (s *Server) loginPOST(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	} else if err != nil {
-		s.serverError(w, err)
+		s.serverError(w, r, err)
 		return
 	}
 
 	if err = s.addNewUserSession(w, r, userID); err != nil {
-		s.serverError(w, err)
+		s.serverError(w, r, err)
 		return
 	}
 
+	s.audit(r, &models.AuditEvent{ActorID: userID, Action: models.AuditLogin})
+	s.metrics.logins.WithLabelValues("success").Inc()
+
 	http.Redirect(w, r, "/", 303)
 
 }
@@ -207,10 +375,11 @@ func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
 	if currentUser.LogoutHash == hash {
 		session, err := s.session.Get(r, "SID")
 		if err != nil {
-			s.serverError(w, err)
+			s.serverError(w, r, err)
 			return
 		}
-		removeSession(w, r, session)
+		s.removeSession(w, r, session)
+		s.audit(r, &models.AuditEvent{ActorID: currentUser.ID, Action: models.AuditLogout})
 		http.Redirect(w, r, "/user/login", 303)
 		return
 	}
@@ -225,22 +394,60 @@ func (s *Server) deleteSnippet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	if currentUser.LogoutHash == hash {
-		if err := s.snippetStore.Delete(int64(id), currentUser.ID); err == models.ErrNoRecord {
-			http.NotFound(w, r)
-			return
-		} else if err != nil {
-			s.serverError(w, err)
-			return
-		}
-	} else {
-		http.NotFound(w, r)
+	if currentUser.LogoutHash != hash {
+		s.notFound(w, r)
+		return
+	}
+
+	attachments, err := s.attachmentStore.List(r.Context(), int64(id))
+
+	if err != nil {
+		s.serverError(w, r, err)
 		return
 	}
 
+	if err := s.snippetStore.Delete(r.Context(), int64(id), currentUser.ID); err == models.ErrNoRecord {
+		s.notFound(w, r)
+		return
+	} else if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	//attachments rows are removed by cascade, so blobs are removed by keys listed before delete
+	s.removeBlobs(r, attachments)
+
+	s.audit(r, &models.AuditEvent{
+		ActorID:    currentUser.ID,
+		Action:     models.AuditSnippetDelete,
+		TargetType: "snippet",
+		TargetID:   int64(id),
+	})
+
 	http.Redirect(w, r, "/", 303)
 }
 
+//snippetTypes values of snippet type in create and edit form
+var snippetTypes = []string{"Public", "Unlisted", "Private"}
+
+//setSnippetType set visibility of snippet by type from form
+func setSnippetType(snippet *models.Snippet, snippetType string) {
+	snippet.IsPublic = snippetType != "Private"
+	snippet.Unlisted = snippetType == "Unlisted"
+}
+
+//snippetTypeOf return type for form by visibility of snippet
+func snippetTypeOf(snippet *models.Snippet) string {
+	switch snippet.Visibility() {
+	case models.VisibilityPrivate:
+		return "Private"
+	case models.VisibilityUnlisted:
+		return "Unlisted"
+	default:
+		return "Public"
+	}
+}
+
 func (s *Server) createSnippet(w http.ResponseWriter, r *http.Request) {
 	s.render(w, r, "create", &templateData{Title: "Create snippet", CSRFField: csrf.TemplateField(r), FormAction: "/snippet/create"})
 }
@@ -252,13 +459,18 @@ func (s *Server) createPOST(w http.ResponseWriter, r *http.Request) {
 		Content: r.FormValue("content"),
 		Expire:  r.FormValue("expire"),
 		Type:    r.FormValue("type"),
+		Format:  r.FormValue("format"),
+		Remind:  r.FormValue("remind") != "",
+		Files:   parseFileForms(r),
 	}
 
 	errors := validation.ValidateStruct(sForm,
 		validation.Field(&sForm.Title, validation.Required),
 		validation.Field(&sForm.Content, validation.Required),
-		validation.Field(&sForm.Expire, validation.Required, validation.By(validateInteger)),
-		validation.Field(&sForm.Type, validation.Required, validation.In("Public", "Private")),
+		validation.Field(&sForm.Expire, validation.Required, validation.In(expirePresetValues()...)),
+		validation.Field(&sForm.Type, validation.Required, validation.In(toInterfaces(snippetTypes)...)),
+		validation.Field(&sForm.Format, validation.In(toInterfaces(models.Formats)...)),
+		validation.Field(&sForm.Files, validation.By(validateFiles)),
 	)
 
 	if errors != nil {
@@ -278,25 +490,35 @@ func (s *Server) createPOST(w http.ResponseWriter, r *http.Request) {
 
 	currentUser := getAuthUserFromRequest(r)
 
-	expire, err := strconv.Atoi(sForm.Expire)
-
-	if err != nil {
-		s.serverError(w, err)
-		return
-	}
+	preset, _ := findExpirePreset(sForm.Expire)
 
-	snippetType := true
-	if sForm.Type == "Private" {
-		snippetType = false
+	snippet := &models.Snippet{
+		Title:        sForm.Title,
+		Content:      sForm.Content,
+		Expires:      preset.expiresFrom(time.Now().UTC()),
+		OwnerID:      currentUser.ID,
+		RemindExpiry: sForm.Remind,
+		Format:       sForm.Format,
+		Files:        snippetFilesOf(sForm.Files),
 	}
+	setSnippetType(snippet, sForm.Type)
 
-	_, err = s.snippetStore.Insert(sForm.Title, sForm.Content, expire, snippetType, currentUser.ID)
+	snippetID, err := s.snippetStore.Insert(r.Context(), snippet)
 
 	if err != nil {
-		s.serverError(w, err)
+		s.serverError(w, r, err)
 		return
 	}
 
+	s.metrics.snippetsCreated.Inc()
+
+	s.audit(r, &models.AuditEvent{
+		ActorID:    currentUser.ID,
+		Action:     models.AuditSnippetCreate,
+		TargetType: "snippet",
+		TargetID:   snippetID,
+	})
+
 	http.Redirect(w, r, "/snippets", 303)
 }
 
@@ -304,13 +526,13 @@ func (s *Server) editSnippet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	snippet, err := s.snippetStore.Get(int64(id))
+	snippet, err := s.snippetStore.Get(r.Context(), int64(id))
 
 	if err != nil {
 		if err == models.ErrNoRecord {
-			http.NotFound(w, r)
+			s.notFound(w, r)
 		} else {
-			s.serverError(w, err)
+			s.serverError(w, r, err)
 		}
 		return
 	}
@@ -318,22 +540,17 @@ func (s *Server) editSnippet(w http.ResponseWriter, r *http.Request) {
 	currentUser := getAuthUserFromRequest(r)
 
 	if snippet.OwnerID != currentUser.ID {
-		w.WriteHeader(http.StatusForbidden)
+		s.forbidden(w, r)
 		return
 	}
 
-	snippetType := "Private"
-	if snippet.IsPublic {
-		snippetType = "Public"
-	}
-
-	diffDate := snippet.Expires.Sub(snippet.Created).Hours() / 24
-
 	sForm := &snippetForm{
 		Title:   snippet.Title,
 		Content: snippet.Content,
-		Expire:  fmt.Sprintf("%d", int(diffDate)),
-		Type:    snippetType,
+		Type:    snippetTypeOf(snippet),
+		Format:  snippet.Format,
+		Remind:  snippet.RemindExpiry,
+		Files:   newFileForms(snippet.Files),
 	}
 
 	s.render(
@@ -342,6 +559,7 @@ func (s *Server) editSnippet(w http.ResponseWriter, r *http.Request) {
 		&templateData{
 			IsEdit:      true,
 			Title:       "Edit snippet",
+			Snippet:     snippet,
 			FormSnippet: sForm,
 			FormAction:  "/snippet/edit/" + fmt.Sprintf("%d", id),
 			CSRFField:   csrf.TemplateField(r)})
@@ -354,13 +572,20 @@ func (s *Server) editPOST(w http.ResponseWriter, r *http.Request) {
 	sForm := &snippetForm{
 		Title:   r.FormValue("title"),
 		Content: r.FormValue("content"),
+		Expire:  r.FormValue("expire"),
 		Type:    r.FormValue("type"),
+		Format:  r.FormValue("format"),
+		Remind:  r.FormValue("remind") != "",
+		Files:   parseFileForms(r),
 	}
 
 	errors := validation.ValidateStruct(sForm,
 		validation.Field(&sForm.Title, validation.Required),
 		validation.Field(&sForm.Content, validation.Required),
-		validation.Field(&sForm.Type, validation.Required, validation.In("Public", "Private")),
+		validation.Field(&sForm.Expire, validation.In(expirePresetValues()...)),
+		validation.Field(&sForm.Type, validation.Required, validation.In(toInterfaces(snippetTypes)...)),
+		validation.Field(&sForm.Format, validation.In(toInterfaces(models.Formats)...)),
+		validation.Field(&sForm.Files, validation.By(validateFiles)),
 	)
 
 	if errors != nil {
@@ -378,24 +603,320 @@ func (s *Server) editPOST(w http.ResponseWriter, r *http.Request) {
 	}
 	currentUser := getAuthUserFromRequest(r)
 
-	snippetType := true
-	if sForm.Type == "Private" {
-		snippetType = false
+	oldSnippet, err := s.snippetStore.Get(r.Context(), int64(id))
+
+	if err != nil {
+		if err == models.ErrNoRecord {
+			s.notFound(w, r)
+		} else {
+			s.serverError(w, r, err)
+		}
+		return
 	}
 
-	err := s.snippetStore.Update(
-		&models.Snippet{ID: int64(id), Title: sForm.Title, Content: sForm.Content, IsPublic: snippetType},
-		currentUser.ID,
-	)
+	oldVisibility := oldSnippet.Visibility()
+	expires := oldSnippet.Expires
+
+	if preset, ok := findExpirePreset(sForm.Expire); ok {
+		expires = preset.expiresFrom(time.Now().UTC())
+	}
+
+	snippet := &models.Snippet{
+		ID:           int64(id),
+		Title:        sForm.Title,
+		Content:      sForm.Content,
+		Expires:      expires,
+		RemindExpiry: sForm.Remind,
+		Format:       sForm.Format,
+		Files:        snippetFilesOf(sForm.Files),
+	}
+	setSnippetType(snippet, sForm.Type)
+
+	err = s.snippetStore.Update(r.Context(), snippet, currentUser.ID)
 
 	if err != nil {
 		if err == models.ErrNoRecord {
-			http.NotFound(w, r)
+			s.notFound(w, r)
 		} else {
-			s.serverError(w, err)
+			s.serverError(w, r, err)
 		}
 		return
 	}
 
+	s.audit(r, &models.AuditEvent{
+		ActorID:    currentUser.ID,
+		Action:     models.AuditSnippetUpdate,
+		TargetType: "snippet",
+		TargetID:   int64(id),
+	})
+
+	if oldVisibility != snippet.Visibility() {
+		s.audit(r, &models.AuditEvent{
+			ActorID:    currentUser.ID,
+			Action:     models.AuditSnippetVisibility,
+			TargetType: "snippet",
+			TargetID:   int64(id),
+			Details:    "visibility=" + snippet.Visibility(),
+		})
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), 303)
 }
+
+func (s *Server) expireSnippet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	preset, ok := findExpirePreset(r.FormValue("expire"))
+
+	if !ok {
+		s.clientError(w, r, http.StatusBadRequest, "Unknown expiration")
+		return
+	}
+
+	currentUser := getAuthUserFromRequest(r)
+	expires := preset.expiresFrom(time.Now().UTC())
+
+	err := s.snippetStore.SetExpiration(r.Context(), int64(id), currentUser.ID, expires)
+
+	if err != nil {
+		if err == models.ErrNoRecord {
+			s.notFound(w, r)
+		} else {
+			s.serverError(w, r, err)
+		}
+		return
+	}
+
+	s.audit(r, &models.AuditEvent{
+		ActorID:    currentUser.ID,
+		Action:     models.AuditSnippetUpdate,
+		TargetType: "snippet",
+		TargetID:   int64(id),
+		Details:    "expires=" + humanExpires(expires),
+	})
+
+	if err = s.addFlashMessage(w, r, "Snippet expiration changed to "+preset.Label); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), 303)
+}
+
+func (s *Server) userActivity(w http.ResponseWriter, r *http.Request) {
+	page, err := getPage(r)
+	if err != nil {
+		s.clientError(w, r, http.StatusBadRequest, "Invalid page number")
+		return
+	}
+
+	u := getAuthUserFromRequest(r)
+
+	events, err := s.auditStore.List(r.Context(), &models.AuditFilter{UserID: u.ID}, 20, page)
+
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	s.render(w, r, "activity", &templateData{Title: "Security activity", AuditEvents: events})
+}
+
+func parseAuditForm(r *http.Request) (*auditForm, *models.AuditFilter, error) {
+	aForm := &auditForm{
+		Actor:  r.URL.Query().Get("actor"),
+		Action: r.URL.Query().Get("action"),
+		From:   r.URL.Query().Get("from"),
+		To:     r.URL.Query().Get("to"),
+	}
+
+	errors := validation.ValidateStruct(aForm,
+		validation.Field(&aForm.Actor, validation.By(validateOptionalInteger)),
+		validation.Field(&aForm.Action, validation.In(toInterfaces(models.AuditActions)...)),
+		validation.Field(&aForm.From, validation.Date(dateLayout)),
+		validation.Field(&aForm.To, validation.Date(dateLayout)),
+	)
+
+	if errors != nil {
+		return aForm, nil, errors
+	}
+
+	filter := &models.AuditFilter{Action: aForm.Action}
+
+	if aForm.Actor != "" {
+		filter.ActorID, _ = strconv.ParseInt(aForm.Actor, 10, 64)
+	}
+
+	if aForm.From != "" {
+		filter.From, _ = time.Parse(dateLayout, aForm.From)
+	}
+
+	if aForm.To != "" {
+		to, _ := time.Parse(dateLayout, aForm.To)
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	return aForm, filter, nil
+}
+
+func (s *Server) adminAudit(w http.ResponseWriter, r *http.Request) {
+	page, err := getPage(r)
+	if err != nil {
+		s.clientError(w, r, http.StatusBadRequest, "Invalid page number")
+		return
+	}
+
+	aForm, filter, err := parseAuditForm(r)
+
+	td := &templateData{
+		Title:        "Audit log",
+		FormAudit:    aForm,
+		AuditActions: models.AuditActions,
+	}
+
+	if err != nil {
+		td.Errors = err.(validation.Errors)
+		s.render(w, r, "audit", td)
+		return
+	}
+
+	td.AuditEvents, err = s.auditStore.List(r.Context(), filter, 50, page)
+
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	s.render(w, r, "audit", td)
+}
+
+func (s *Server) adminAuditExport(w http.ResponseWriter, r *http.Request) {
+	page, err := getPage(r)
+	if err != nil {
+		s.clientError(w, r, http.StatusBadRequest, "Invalid page number")
+		return
+	}
+
+	_, filter, err := parseAuditForm(r)
+
+	if err != nil {
+		s.clientError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := s.auditStore.List(r.Context(), filter, 1000, page)
+
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.json"`)
+
+	if err = json.NewEncoder(w).Encode(events); err != nil {
+		s.logger(r).Errorf("Error while export audit events: %v", err)
+	}
+}
+
+//rawSnippet return content of snippet as plain text
+func (s *Server) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := s.getVisibleSnippet(w, r)
+
+	if snippet == nil {
+		return
+	}
+
+	setSnippetCacheControl(w, snippet)
+	if checkNotModified(w, r, rawETag(snippet.Content), snippet.Updated) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(snippet.Content))
+}
+
+//embedSnippet show public snippet without layout, page can be shown in iframe on other sites
+func (s *Server) embedSnippet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	snippet, err := s.snippetStore.Get(r.Context(), int64(id))
+
+	if err != nil {
+		if err == models.ErrNoRecord {
+			s.notFound(w, r)
+		} else {
+			s.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !snippet.IsPublic {
+		s.notFound(w, r)
+		return
+	}
+
+	s.render(w, r, "embed", &templateData{Snippet: snippet})
+}
+
+//healthz report that process is alive
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+//readyz report that server can handle requests: database is reachable and templates are loaded
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if cache, _ := s.getTemplates(); len(cache) == 0 {
+		http.Error(w, "templates are not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := s.db.PingContext(ctx); err != nil {
+		s.logger(r).Warnf("Readiness check failed: %v", err)
+		http.Error(w, "database is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Write([]byte("ok"))
+}
+
+//dbStats is connection pool state returned by debug endpoint
+type dbStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+//debugDB show connection pool stats
+func (s *Server) debugDB(w http.ResponseWriter, r *http.Request) {
+	stats := s.db.Stats()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(&dbStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	})
+
+	if err != nil {
+		s.logger(r).Errorf("Error while write database stats: %v", err)
+	}
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
 	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
@@ -88,25 +91,33 @@ func TestHomeWithData(t *testing.T) {
 	defer srv.Close()
 
 	tests := map[string]showSnippetsData{
-		"Bad page number": {
-			WantCode: 500,
-			WantPage: "ff",
+		"Bad cursor": {
+			WantCode: 400,
+			Query:    "after=ff",
 		},
-		"Negative page number": {
-			WantCode: 500,
-			WantPage: "-1",
+		"Both cursors": {
+			WantCode: 400,
+			Query:    "after=" + models.CursorOf(ss[5], models.SortCreated).String() + "&before=" + models.CursorOf(ss[5], models.SortCreated).String(),
 		},
 		"Test first page": {
 			WantCode: 200,
-			WantSee:  ss[:10],
-			WantHide: ss[10:],
-			WantPage: "1",
+			WantSee:  ss[5:],
+			WantHide: ss[:5],
+			WantNext: true,
 		},
 		"Test second page": {
 			WantCode: 200,
-			WantSee:  ss[11:],
-			WantHide: ss[:10],
-			WantPage: "2",
+			WantSee:  ss[:5],
+			WantHide: ss[5:],
+			WantPrev: true,
+			Query:    "after=" + models.CursorOf(ss[5], models.SortCreated).String(),
+		},
+		"Test back to first page": {
+			WantCode: 200,
+			WantSee:  ss[5:],
+			WantHide: ss[:5],
+			WantNext: true,
+			Query:    "before=" + models.CursorOf(ss[4], models.SortCreated).String(),
 		},
 	}
 
@@ -131,24 +142,102 @@ func TestUserSnippetsWithData(t *testing.T) {
 	login(t, srv, "conor@mail.com", "12345678")
 
 	tests := map[string]showSnippetsData{
-		"Bad page number": {
-			WantCode: 500,
-			WantPage: "ff",
-		},
-		"Negative page number": {
-			WantCode: 500,
-			WantPage: "-1",
+		"Bad cursor": {
+			WantCode: 400,
+			Query:    "before=" + base64.RawURLEncoding.EncodeToString([]byte("yesterday_1")),
 		},
 		"Test first page": {
 			WantCode: 200,
 			WantSee:  ss[:5],
 			WantHide: ss[5:],
-			WantPage: "1",
 		},
 	}
 	testSnippetsPage(srv, t, tests, "/snippets")
 }
 
+func TestSnippetsListOptions(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 3, true, 2)
+	ss[1].Unlisted = true
+	ss[2].IsPublic = false
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	testSnippetsPage(srv, t, map[string]showSnippetsData{
+		"Unlisted are hidden": {
+			WantCode: 200,
+			WantSee:  ss[:1],
+			WantHide: ss[1:],
+		},
+		"Visibility filter of public list": {
+			WantCode: 400,
+			Query:    "visibility=unlisted",
+		},
+		"Bad order": {
+			WantCode: 400,
+			Query:    "order=random",
+		},
+	}, "/")
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	testSnippetsPage(srv, t, map[string]showSnippetsData{
+		"All own snippets": {
+			WantCode: 200,
+			WantSee:  ss,
+		},
+		"Unlisted": {
+			WantCode: 200,
+			WantSee:  ss[1:2],
+			WantHide: []*models.Snippet{ss[0], ss[2]},
+			Query:    "visibility=unlisted&sort=title&order=asc",
+		},
+		"Expiring soon": {
+			WantCode: 200,
+			WantSee:  ss,
+			Query:    "expiring=soon",
+		},
+		"Created in future": {
+			WantCode: 200,
+			WantHide: ss,
+			Query:    "from=" + time.Now().AddDate(0, 0, 2).Format(dateLayout),
+		},
+	}, "/snippets")
+}
+
+func TestSnippetViews(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, true, 2)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	page := fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID)
+
+	get(page, t, srv)
+	get(page, t, srv)
+
+	login(t, srv, "conor@mail.com", "12345678")
+	get(page, t, srv)
+
+	if ss[0].Views != 2 {
+		t.Fatalf("Want 2 views without views of owner, Get: %d", ss[0].Views)
+	}
+}
+
 func TestShowSnippetForNotAuthUser(t *testing.T) {
 	um := getTestUserData()
 	// ss := getTestSnippetData(1, 15, true, 1)
@@ -582,7 +671,7 @@ func TestDelete(t *testing.T) {
 
 func TestCreateSnippetForm(t *testing.T) {
 	um := getTestUserData()
-	ss := append(getTestSnippetData(1, 5, false, 2))
+	ss := getTestSnippetData(1, 5, false, 2)
 
 	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
 
@@ -616,11 +705,12 @@ func TestCreateSnippetForm(t *testing.T) {
 		"Empty content":                     {"1", "", "3", "4", http.StatusOK, []byte("cannot be blank"), csrfToken},
 		"Empty expire":                      {"1", "2", "", "4", http.StatusOK, []byte("cannot be blank"), csrfToken},
 		"Empty type":                        {"1", "2", "3", "", http.StatusOK, []byte("cannot be blank"), csrfToken},
-		"Type not in ['Public', 'Private']": {"1", "2", "3", "Bad", http.StatusOK, []byte("must be a valid value"), csrfToken},
-		"Negative expire":                   {"1", "2", "-3", "4", http.StatusOK, []byte("value must be integer greater than zero"), csrfToken},
-		"Bad expire":                        {"1", "2", "ff", "4", http.StatusOK, []byte("value must be integer greater than zero"), csrfToken},
-		"Success create private":            {"title", "content", "150", "Private", http.StatusSeeOther, nil, csrfToken},
-		"Success create public":             {"title", "content", "150", "Public", http.StatusSeeOther, nil, csrfToken},
+		"Type not in ['Public', 'Private']": {"1", "2", "1d", "Bad", http.StatusOK, []byte("must be a valid value"), csrfToken},
+		"Negative expire":                   {"1", "2", "-3", "Public", http.StatusOK, []byte("must be a valid value"), csrfToken},
+		"Bad expire":                        {"1", "2", "ff", "Public", http.StatusOK, []byte("must be a valid value"), csrfToken},
+		"Success create private":            {"title", "content", "10m", "Private", http.StatusSeeOther, nil, csrfToken},
+		"Success create public":             {"title", "content", "1M", "Public", http.StatusSeeOther, nil, csrfToken},
+		"Success create never expiring":     {"title", "content", "never", "Public", http.StatusSeeOther, nil, csrfToken},
 	}
 
 	for name, test := range tests {
@@ -702,7 +792,7 @@ func TestGetEditPage(t *testing.T) {
 
 func TestEditSnippetForm(t *testing.T) {
 	um := getTestUserData()
-	ss := append(getTestSnippetData(1, 5, false, 2))
+	ss := getTestSnippetData(1, 5, false, 2)
 
 	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
 
@@ -761,3 +851,312 @@ func TestEditSnippetForm(t *testing.T) {
 		})
 	}
 }
+
+func TestUserActivity(t *testing.T) {
+	um := getTestUserData()
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.auditStore.Insert(context.Background(), &models.AuditEvent{Action: models.AuditLoginFailed, TargetType: "user", TargetID: 2})
+	s.auditStore.Insert(context.Background(), &models.AuditEvent{ActorID: 1, Action: models.AuditSnippetDelete, TargetType: "snippet", TargetID: 7})
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	code, _, body := get(fmt.Sprintf("%s/user/activity", srv.URL), t, srv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Want: %d, Get: %d", http.StatusOK, code)
+	}
+
+	for _, action := range []string{models.AuditLogin, models.AuditLoginFailed} {
+		if !bytes.Contains(body, []byte(action)) {
+			t.Fatalf("No '%s' event in activity page", action)
+		}
+	}
+
+	if bytes.Contains(body, []byte(models.AuditSnippetDelete)) {
+		t.Fatalf("Event of other user in activity page")
+	}
+
+	as := s.auditStore.(*mock.AuditStore)
+
+	if len(as.DB) != 3 || as.DB[2].ActorID != 2 || as.DB[2].IP == "" {
+		t.Fatalf("Bad audit events: %v", as.DB)
+	}
+}
+
+func TestAuditEvents(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 2, true, 2)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	code, _, data := get(fmt.Sprintf("%s/user/login", srv.URL), t, srv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Return code %d != %d", code, http.StatusOK)
+	}
+
+	formValues := url.Values{}
+	formValues.Add("email", "conor@mail.com")
+	formValues.Add("password", "bad password")
+	formValues.Add("gorilla.csrf.Token", extractCSRFToken(t, data))
+
+	postForm(formValues, fmt.Sprintf("%s/user/login", srv.URL), t, srv)
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	code, _, data = get(fmt.Sprintf("%s/snippet/edit/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Return code %d != %d", code, http.StatusOK)
+	}
+
+	formValues = url.Values{}
+	formValues.Add("title", "title")
+	formValues.Add("content", "content")
+	formValues.Add("type", "Private")
+	formValues.Add("gorilla.csrf.Token", extractCSRFToken(t, data))
+
+	code, _, _ = postForm(formValues, fmt.Sprintf("%s/snippet/edit/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusSeeOther {
+		t.Fatalf("Want: %d, Get: %d", http.StatusSeeOther, code)
+	}
+
+	wantActions := []string{models.AuditLoginFailed, models.AuditLogin, models.AuditSnippetUpdate, models.AuditSnippetVisibility}
+	as := s.auditStore.(*mock.AuditStore)
+
+	if len(as.DB) != len(wantActions) {
+		t.Fatalf("Want %d events, Get %d", len(wantActions), len(as.DB))
+	}
+
+	for i, action := range wantActions {
+		if as.DB[i].Action != action {
+			t.Fatalf("Want action: %s, Get: %s", action, as.DB[i].Action)
+		}
+	}
+
+	if as.DB[0].ActorID != 0 || as.DB[0].TargetType != "user" || as.DB[0].TargetID != 2 || as.DB[3].TargetID != ss[0].ID || as.DB[3].Details != "visibility=private" {
+		t.Fatalf("Bad audit events: %v %v", as.DB[0], as.DB[3])
+	}
+}
+
+func TestAdminAudit(t *testing.T) {
+	um := getTestUserData()
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.auditStore.Insert(context.Background(), &models.AuditEvent{ActorID: 1, Action: models.AuditSnippetDelete, TargetType: "snippet", TargetID: 7})
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	code, _, _ := get(fmt.Sprintf("%s/admin/audit", srv.URL), t, srv)
+
+	if code != http.StatusForbidden {
+		t.Fatalf("Want: %d, Get: %d", http.StatusForbidden, code)
+	}
+
+	setClearCookieJar(t, srv)
+	login(t, srv, "admin@mail.com", "12345678")
+
+	tests := map[string]struct {
+		Path     string
+		WantCode int
+		WantData []byte
+		WantHide []byte
+	}{
+		"Audit page": {
+			Path:     "/admin/audit",
+			WantCode: http.StatusOK,
+			WantData: []byte("snippet #7"),
+		},
+		"Filter by action": {
+			Path:     "/admin/audit?action=" + models.AuditLogin,
+			WantCode: http.StatusOK,
+			WantData: []byte(models.AuditLogin),
+			WantHide: []byte("snippet #7"),
+		},
+		"Bad filter": {
+			Path:     "/admin/audit?from=bad",
+			WantCode: http.StatusOK,
+			WantData: []byte("must be a valid date"),
+		},
+		"Bad page": {
+			Path:     "/admin/audit?page=-1",
+			WantCode: http.StatusBadRequest,
+			WantData: []byte("Invalid page number"),
+		},
+		"Export": {
+			Path:     "/admin/audit/export?actor=1",
+			WantCode: http.StatusOK,
+			WantData: []byte(`"action":"snippet_delete"`),
+			WantHide: []byte(models.AuditLogin),
+		},
+		"Export bad filter": {
+			Path:     "/admin/audit/export?actor=ff",
+			WantCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			code, _, body := get(fmt.Sprintf("%s%s", srv.URL, test.Path), t, srv)
+
+			if code != test.WantCode {
+				t.Fatalf("Want: %d, Get: %d", test.WantCode, code)
+			}
+
+			if test.WantData != nil && !bytes.Contains(body, test.WantData) {
+				t.Fatalf("%s not in result body", string(test.WantData))
+			}
+
+			if test.WantHide != nil && bytes.Contains(body, test.WantHide) {
+				t.Fatalf("%s in result body", string(test.WantHide))
+			}
+		})
+	}
+}
+
+func TestEditSnippetExpiration(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, false, 2)
+	oldExpires := ss[0].Expires
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+	login(t, srv, "conor@mail.com", "12345678")
+
+	code, _, data := get(fmt.Sprintf("%s/snippet/edit/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Return code %d != %d", code, http.StatusOK)
+	}
+
+	if !bytes.Contains(data, []byte("Keep current")) {
+		t.Fatal("No 'Keep current' expire option on edit page")
+	}
+
+	csrfToken := extractCSRFToken(t, data)
+
+	tests := []struct {
+		Name        string
+		Expire      string
+		WantCode    int
+		WantExpires func() time.Time
+	}{
+		{"Bad expire", "5y", http.StatusOK, func() time.Time { return oldExpires }},
+		{"Keep current expire", "", http.StatusSeeOther, func() time.Time { return oldExpires }},
+		{"Change expire", "10m", http.StatusSeeOther, func() time.Time { return time.Now().Add(10 * time.Minute) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			formValues := url.Values{}
+			formValues.Add("title", "title")
+			formValues.Add("content", "content")
+			formValues.Add("expire", test.Expire)
+			formValues.Add("type", "Private")
+			formValues.Add("gorilla.csrf.Token", csrfToken)
+
+			code, _, _ := postForm(formValues, fmt.Sprintf("%s/snippet/edit/%d", srv.URL, ss[0].ID), t, srv)
+
+			if code != test.WantCode {
+				t.Fatalf("Want: %d, Get: %d", test.WantCode, code)
+			}
+
+			if diff := ss[0].Expires.Sub(test.WantExpires()); diff > time.Second || diff < -time.Second {
+				t.Fatalf("Want expires: %v, Get: %v", test.WantExpires(), ss[0].Expires)
+			}
+		})
+	}
+}
+
+func TestExpireSnippet(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 1, true, 2), getTestSnippetData(2, 1, true, 1)...)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+	login(t, srv, "conor@mail.com", "12345678")
+
+	code, _, data := get(fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Return code %d != %d", code, http.StatusOK)
+	}
+
+	csrfToken := extractCSRFToken(t, data)
+
+	tests := []struct {
+		Name      string
+		ID        int64
+		Expire    string
+		WantCode  int
+		WantNever bool
+	}{
+		{"Bad expire", ss[0].ID, "5y", http.StatusBadRequest, false},
+		{"Snippet of other user", ss[1].ID, "never", http.StatusNotFound, false},
+		{"Never expires", ss[0].ID, "never", http.StatusSeeOther, true},
+		{"Shorten expiration", ss[0].ID, "10m", http.StatusSeeOther, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			formValues := url.Values{}
+			formValues.Add("expire", test.Expire)
+			formValues.Add("gorilla.csrf.Token", csrfToken)
+
+			code, _, _ := postForm(formValues, fmt.Sprintf("%s/snippet/expire/%d", srv.URL, test.ID), t, srv)
+
+			if code != test.WantCode {
+				t.Fatalf("Want: %d, Get: %d", test.WantCode, code)
+			}
+
+			if ss[0].NeverExpires() != test.WantNever {
+				t.Fatalf("Want never expires: %v, Get expires: %v", test.WantNever, ss[0].Expires)
+			}
+		})
+	}
+
+	if ss[1].NeverExpires() {
+		t.Fatal("Snippet of other user changed")
+	}
+
+	code, _, data = get(fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK || !bytes.Contains(data, []byte("Snippet expiration changed to 10 minutes")) {
+		t.Fatalf("No flash message on snippet page, code: %d", code)
+	}
+}
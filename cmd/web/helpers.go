@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
+	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
 	"strconv"
@@ -13,8 +16,55 @@ import (
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
+//expirePreset is one of snippet lifetimes offered by create and edit forms
+type expirePreset struct {
+	Value    string
+	Label    string
+	months   int
+	duration time.Duration
+	never    bool
+}
+
+var expirePresets = []expirePreset{
+	{Value: "10m", Label: "10 minutes", duration: 10 * time.Minute},
+	{Value: "1h", Label: "1 hour", duration: time.Hour},
+	{Value: "1d", Label: "1 day", duration: 24 * time.Hour},
+	{Value: "1w", Label: "1 week", duration: 7 * 24 * time.Hour},
+	{Value: "1M", Label: "1 month", months: 1},
+	{Value: "never", Label: "Never", never: true},
+}
+
+//expiresFrom return expiration time of snippet created at t, zero time for never expiring snippet
+func (p expirePreset) expiresFrom(t time.Time) time.Time {
+	if p.never {
+		return time.Time{}
+	}
+	return t.AddDate(0, p.months, 0).Add(p.duration)
+}
+
+func findExpirePreset(value string) (expirePreset, bool) {
+	for _, p := range expirePresets {
+		if p.Value == value {
+			return p, true
+		}
+	}
+
+	return expirePreset{}, false
+}
+
+func expirePresetValues() []interface{} {
+	res := make([]interface{}, len(expirePresets))
+	for i, p := range expirePresets {
+		res[i] = p.Value
+	}
+
+	return res
+}
+
 func validateInteger(value interface{}) error {
 	s, _ := value.(string)
 
@@ -26,23 +76,75 @@ func validateInteger(value interface{}) error {
 	return nil
 }
 
-func openDB(dsn string) (*sql.DB, error) {
+//toInterfaces convert strings for validation.In
+func toInterfaces(values []string) []interface{} {
+	res := make([]interface{}, len(values))
+	for i, value := range values {
+		res[i] = value
+	}
+	return res
+}
+
+func validateOptionalInteger(value interface{}) error {
+	if s, _ := value.(string); s == "" {
+		return nil
+	}
+
+	return validateInteger(value)
+}
+
+//maxConnectBackoff limits delay between connection attempts of openDB
+const maxConnectBackoff = 5 * time.Second
+
+//openDB configure connection pool and wait until database is available at most pool.connectTimeout
+func openDB(ctx context.Context, dsn string, pool poolConfig, log *logrus.Logger) (*sql.DB, error) {
 	db, err := sql.Open("mysql", dsn)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
-		db.Close()
-		return nil, err
-	}
+	db.SetMaxOpenConns(pool.maxOpen)
+	db.SetMaxIdleConns(pool.maxIdle)
+	db.SetConnMaxLifetime(pool.maxLifetime)
+	db.SetConnMaxIdleTime(pool.maxIdleTime)
+
+	ctx, cancel := context.WithTimeout(ctx, pool.connectTimeout)
+	defer cancel()
+
+	backoff := 250 * time.Millisecond
+
+	for {
+		if err = db.PingContext(ctx); err == nil {
+			return db, nil
+		}
+
+		log.Warnf("Database is not available, retry in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("database is not available after %s: %v", pool.connectTimeout, err)
+		case <-time.After(backoff):
+		}
 
-	return db, nil
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
 }
-func (s *Server) serverError(w http.ResponseWriter, err error) {
-	s.log.Errorf("Internal error: %v %s", err, string(debug.Stack()))
-	http.Error(w, "Internal error", http.StatusInternalServerError)
+func (s *Server) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() == context.Canceled {
+		s.logger(r).Warnf("Request canceled by client: %v", err)
+		return
+	}
+
+	incidentID := newIncidentID()
+	s.logger(r).WithFields(logrus.Fields{
+		"stack":       string(debug.Stack()),
+		"incident_id": incidentID,
+	}).Errorf("Internal error: %v", err)
+	s.errorPage(w, r, &errorInfo{Status: http.StatusInternalServerError, IncidentID: incidentID})
 }
 
 func (s *Server) addFlashMessage(w http.ResponseWriter, r *http.Request, message string) error {
@@ -53,7 +155,7 @@ func (s *Server) addFlashMessage(w http.ResponseWriter, r *http.Request, message
 
 	session.AddFlash(message)
 
-	err = session.Save(r, w)
+	err = s.saveSession(w, r, session)
 	if err != nil {
 		return err
 	}
@@ -71,10 +173,10 @@ func (s *Server) getFlashes(w http.ResponseWriter, r *http.Request) ([]interface
 
 	flashes := session.Flashes()
 
-	err = session.Save(r, w)
+	err = s.saveSession(w, r, session)
 
 	if err != nil {
-		s.serverError(w, err)
+		s.serverError(w, r, err)
 		return nil, err
 	}
 
@@ -100,16 +202,22 @@ func (s *Server) addNewUserSession(w http.ResponseWriter, r *http.Request, id in
 	session.Values["userID"] = id
 	session.Values["logoutHash"] = hex.EncodeToString(hasher.Sum(nil))
 
-	if err = session.Save(r, w); err != nil {
+	if err = s.saveSession(w, r, session); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func removeSession(w http.ResponseWriter, r *http.Request, session *sessions.Session) {
+//saveSession save session cookie, it's Secure when client uses HTTPS
+func (s *Server) saveSession(w http.ResponseWriter, r *http.Request, session *sessions.Session) error {
+	session.Options.Secure = s.isSecure(r)
+	return session.Save(r, w)
+}
+
+func (s *Server) removeSession(w http.ResponseWriter, r *http.Request, session *sessions.Session) {
 	session.Options.MaxAge = -1
-	session.Save(r, w)
+	s.saveSession(w, r, session)
 }
 
 func getAuthUserFromRequest(r *http.Request) *models.User {
@@ -120,3 +228,62 @@ func getAuthUserFromRequest(r *http.Request) *models.User {
 
 	return u
 }
+
+func getRequestInfo(r *http.Request) *requestInfo {
+	info, ok := r.Context().Value(contextKeyRequest).(*requestInfo)
+	if !ok {
+		return &requestInfo{}
+	}
+
+	return info
+}
+
+//newRequestID return random hex identifier of request
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//newIncidentID return short ID shown to user on internal error and logged with stack trace
+func newIncidentID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//logger return log entry with request ID and user ID of request
+func (s *Server) logger(r *http.Request) *logrus.Entry {
+	info := getRequestInfo(r)
+	fields := logrus.Fields{"request_id": info.id}
+
+	if info.userID != 0 {
+		fields["user_id"] = info.userID
+	}
+
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+
+	return s.log.WithFields(fields)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+//audit save security-relevant event, request data is added to event, IP is resolved through trusted proxies.
+//Event is saved even if client disconnects, action is already done at this point
+func (s *Server) audit(r *http.Request, event *models.AuditEvent) {
+	event.IP = s.realIP(r)
+	event.UserAgent = r.UserAgent()
+
+	if _, err := s.auditStore.Insert(context.WithoutCancel(r.Context()), event); err != nil {
+		s.logger(r).Errorf("Error while save audit event %s: %v", event.Action, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/sirupsen/logrus"
+)
+
+func TestOpenDBRetry(t *testing.T) {
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+
+	pool := poolConfig{maxOpen: 5, maxIdle: 2, connectTimeout: 800 * time.Millisecond}
+
+	start := time.Now()
+	db, err := openDB(context.Background(), "root:123@tcp(127.0.0.1:1)/snippetbox", pool, log)
+
+	if err == nil {
+		db.Close()
+		t.Fatal("Want error for unavailable database")
+	}
+
+	if elapsed := time.Since(start); elapsed < pool.connectTimeout {
+		t.Fatalf("openDB gave up after %s, before connect timeout", elapsed)
+	}
+
+	if retries := strings.Count(logs.String(), "Database is not available"); retries < 2 {
+		t.Fatalf("Want several attempts, Get: %d", retries)
+	}
+}
+
+func TestOpenDBCanceled(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(&bytes.Buffer{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pool := poolConfig{connectTimeout: time.Minute}
+
+	start := time.Now()
+	_, err := openDB(ctx, "root:123@tcp(127.0.0.1:1)/snippetbox", pool, log)
+
+	if err == nil || time.Since(start) > 5*time.Second {
+		t.Fatalf("openDB doesn't stop on canceled context: %v", err)
+	}
+}
+
+//ctxAuditStore fail insert with error of context
+type ctxAuditStore struct {
+	mock.AuditStore
+}
+
+func (as *ctxAuditStore) Insert(ctx context.Context, event *models.AuditEvent) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return as.AuditStore.Insert(ctx, event)
+}
+
+func TestAuditCanceledRequest(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+	as := &ctxAuditStore{}
+	s.auditStore = as
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s.audit(httptest.NewRequest("POST", "/user/logout", nil).WithContext(ctx), &models.AuditEvent{ActorID: 1, Action: models.AuditLogout})
+
+	if len(as.DB) != 1 {
+		t.Fatalf("Event of canceled request is not saved")
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+//runPeriodically call fn every interval until ctx is done
+func runPeriodically(ctx context.Context, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
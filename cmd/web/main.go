@@ -1,39 +1,115 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models/mysql"
-	"github.com/joho/godotenv"
 )
 
-func init() {
-	godotenv.Load("conf.env")
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 }
 
-func main() {
+//run load config and start server or command from args
+func run(args []string) error {
+
+	src, args, err := loadConfigSource(args, "conf.env", os.Stderr)
+
+	if err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Error while load config: %v", err)
+	}
+
+	if len(args) > 0 && args[0] == "config" {
+		return configCommand(args[1:], os.Stdout, src)
+	}
+
+	config, err := NewConfig(src)
+
+	if err != nil {
+		return fmt.Errorf("Error while create Config: %v", err)
+	}
+
+	if len(args) > 0 && args[0] != "purge" {
+		return fmt.Errorf("Unknown command %q, see --help", args[0])
+	}
 
-	config, err := NewConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := openDB(ctx, config.dsn, config.pool, config.log)
+
+	if err != nil {
+		return fmt.Errorf("Error while open DB connection: %v", err)
+	}
+
+	defer db.Close()
+
+	if len(args) > 0 && args[0] == "purge" {
+		err = purgeCommand(
+			ctx,
+			args[1:],
+			os.Stdout,
+			config,
+			&mysql.SnippetStore{DB: db, QueryTimeout: config.queryTimeout},
+			&mysql.AttachmentStore{DB: db, QueryTimeout: config.queryTimeout},
+		)
+
+		if err != nil {
+			return fmt.Errorf("Error while purge snippets: %v", err)
+		}
+		return nil
+	}
+
+	shutdownTracing, err := setupTracing(config.traceExporter, os.Stdout)
 
 	if err != nil {
-		fmt.Printf("Error while create Config: %v\n", err)
+		return fmt.Errorf("Error while setup tracing: %v", err)
 	}
 
-	db, err := openDB(config.dsn)
+	defer shutdownTracing(context.Background())
+
+	m := newMetrics()
+	m.registerDB(db)
+
+	snippets, closeCache, err := withCache(
+		ctx,
+		config.cache,
+		&mysql.SnippetStore{DB: db, Observer: m.observeQuery, QueryTimeout: config.queryTimeout},
+		m,
+		config.log,
+	)
 
 	if err != nil {
-		config.log.Errorf("Error while open DB connection %v", err)
-		return
+		return fmt.Errorf("Error while setup cache: %v", err)
 	}
 
+	defer closeCache()
+
 	serv := New(
 		config,
-		&mysql.UsersStore{DB: db},
-		&mysql.SnippetStore{DB: db},
+		m,
+		db,
+		&mysql.UsersStore{DB: db, Observer: m.observeQuery, QueryTimeout: config.queryTimeout},
+		snippets,
+		&mysql.AuditStore{DB: db, Observer: m.observeQuery, QueryTimeout: config.queryTimeout},
+		&mysql.AttachmentStore{DB: db, Observer: m.observeQuery, QueryTimeout: config.queryTimeout},
 	)
 
-	if err = serv.Start(); err != nil {
-		config.log.Errorf("Error while Start server... %v\n", err)
+	if err = serv.Start(ctx); err != nil {
+		return fmt.Errorf("Error while Start server: %v", err)
 	}
 
+	config.log.Infof("Server stopped")
+
+	return nil
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"regexp"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+)
+
+//maxPreviewSize limit of markdown source sent for preview
+const maxPreviewSize = 1 << 20
+
+//highlightStyle chroma style of static/css/highlight.css, fenced code is rendered with classes, so CSP allows it
+const highlightStyle = "github"
+
+//markdown converter, raw HTML in source is omitted
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle(highlightStyle),
+			highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+		),
+	),
+)
+
+//markdownPolicy sanitize rendered markdown: no scripts, styles and event handlers, links only to http, https and mailto
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w -]+$`)).OnElements("pre", "code", "span")
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+//renderMarkdown convert markdown source to sanitized HTML
+func renderMarkdown(source string) (template.HTML, error) {
+	buf := new(bytes.Buffer)
+
+	if err := markdown.Convert([]byte(source), buf); err != nil {
+		return "", err
+	}
+
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes())), nil
+}
+
+//previewSnippet render markdown content from create and edit form
+func (s *Server) previewSnippet(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPreviewSize)
+
+	if err := r.ParseForm(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.clientError(w, r, http.StatusRequestEntityTooLarge, "Content is too large for preview")
+		} else {
+			s.clientError(w, r, http.StatusBadRequest, "Bad form")
+		}
+		return
+	}
+
+	rendered, err := renderMarkdown(r.PostForm.Get("content"))
+
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(rendered))
+}
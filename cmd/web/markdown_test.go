@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := map[string]struct {
+		Source   string
+		WantHTML []string
+		WantHide []string
+	}{
+		"Heading": {
+			Source:   "# Runbook",
+			WantHTML: []string{"<h1>Runbook</h1>"},
+		},
+		"Script": {
+			Source:   "text\n\n<script>alert(1)</script>",
+			WantHTML: []string{"<p>text</p>"},
+			WantHide: []string{"<script", "alert(1)"},
+		},
+		"Event handler": {
+			Source:   `<img src="x" onerror="alert(1)">`,
+			WantHide: []string{"onerror"},
+		},
+		"Javascript link": {
+			Source:   "[click](javascript:alert(1))",
+			WantHide: []string{"javascript:"},
+		},
+		"External link": {
+			Source:   "[docs](https://example.com)",
+			WantHTML: []string{`href="https://example.com"`, "noopener", `target="_blank"`},
+		},
+		"Fenced code": {
+			Source:   "```go\nfunc main() {}\n```",
+			WantHTML: []string{`<pre class="chroma">`, `<span class="kd">func</span>`},
+			WantHide: []string{"style="},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rendered, err := renderMarkdown(test.Source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range test.WantHTML {
+				if !strings.Contains(string(rendered), want) {
+					t.Fatalf("Want %s in: %s", want, rendered)
+				}
+			}
+
+			for _, hide := range test.WantHide {
+				if strings.Contains(string(rendered), hide) {
+					t.Fatalf("Want no %s in: %s", hide, rendered)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkdownSnippet(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, true, 2)
+	ss[0].Format = models.FormatMarkdown
+	ss[0].Content = "# Runbook\n\n<script>alert(1)</script>"
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	code, _, body := get(fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK || !strings.Contains(string(body), "<h1>Runbook</h1>") || strings.Contains(string(body), "alert(1)") {
+		t.Fatalf("Markdown isn't rendered: %d %s", code, body)
+	}
+
+	code, _, body = get(fmt.Sprintf("%s/snippet/%d/raw", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK || string(body) != ss[0].Content {
+		t.Fatalf("Want source in raw view, Get: %d %s", code, body)
+	}
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	_, _, body = get(fmt.Sprintf("%s/snippet/create", srv.URL), t, srv)
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := map[string]struct {
+		Content  string
+		WantCode int
+		WantData string
+	}{
+		"Preview":   {"*note*", http.StatusOK, "<p><em>note</em></p>"},
+		"Too large": {strings.Repeat("a", maxPreviewSize), http.StatusRequestEntityTooLarge, ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", srv.URL+"/snippet/preview", strings.NewReader(url.Values{"content": {test.Content}}.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("X-CSRF-Token", csrfToken)
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			data, _ := ioutil.ReadAll(resp.Body)
+
+			if resp.StatusCode != test.WantCode || !strings.Contains(string(data), test.WantData) {
+				t.Fatalf("Want %d %s, Get: %d %s", test.WantCode, test.WantData, resp.StatusCode, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "snippetbox"
+
+//metrics holds prometheus collectors of application, each Server has own registry
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	signups         prometheus.Counter
+	logins          *prometheus.CounterVec
+	snippetsCreated prometheus.Counter
+	purgeRuns       prometheus.Counter
+	purgeErrors     prometheus.Counter
+	purgeRemoved    prometheus.Counter
+	panics          *prometheus.CounterVec
+	cacheRequests   *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Count of HTTP requests by route template and response status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Duration of repository operations.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"op", "result"}),
+		signups: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "signups_total",
+			Help:      "Count of registered users.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "logins_total",
+			Help:      "Count of login attempts by result.",
+		}, []string{"result"}),
+		snippetsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "snippets_created_total",
+			Help:      "Count of created snippets.",
+		}),
+		purgeRuns: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "purge_runs_total",
+			Help:      "Count of expired snippets purge runs.",
+		}),
+		purgeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "purge_errors_total",
+			Help:      "Count of failed expired snippets purge runs.",
+		}),
+		purgeRemoved: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "purge_removed_snippets_total",
+			Help:      "Count of removed expired snippets.",
+		}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_panics_total",
+			Help:      "Count of panics recovered in HTTP handlers by route template.",
+		}, []string{"route"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_requests_total",
+			Help:      "Count of cache lookups by repository operation and result: hit, miss or error.",
+		}, []string{"op", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+		m.signups,
+		m.logins,
+		m.snippetsCreated,
+		m.purgeRuns,
+		m.purgeErrors,
+		m.purgeRemoved,
+		m.panics,
+		m.cacheRequests,
+	)
+
+	return m
+}
+
+//registerDB add sql.DB connection pool stats to metrics
+func (m *metrics) registerDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, metricsNamespace))
+}
+
+//observeQuery is mysql.QueryObserver for stores
+func (m *metrics) observeQuery(op string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	m.queryDuration.WithLabelValues(op, result).Observe(duration.Seconds())
+}
+
+//observeCache is cache.Observer for cached stores
+func (m *metrics) observeCache(op, result string) {
+	m.cacheRequests.WithLabelValues(op, result).Inc()
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+//routeTemplate return mux route template for request, so labels have bounded cardinality
+func routeTemplate(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+
+	if !router.Match(r, &match) || match.Route == nil {
+		return "unmatched"
+	}
+
+	tmpl, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return "unmatched"
+	}
+
+	return tmpl
+}
+
+func (s *Server) metricsMiddleware(router *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			lwr := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(lwr, r)
+
+			route := routeTemplate(router, r)
+			s.metrics.requests.WithLabelValues(r.Method, route, strconv.Itoa(lwr.statusCode)).Inc()
+			s.metrics.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, true, 1)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.metrics.observeQuery("snippets.get", time.Millisecond, nil)
+	s.metrics.observeQuery("snippets.get", time.Millisecond, errors.New("connection lost"))
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	get(fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), t, srv)
+	get(fmt.Sprintf("%s/snippet/100500", srv.URL), t, srv)
+	get(fmt.Sprintf("%s/unknown/path", srv.URL), t, srv)
+	login(t, srv, "conor@mail.com", "12345678")
+
+	code, _, body := get(fmt.Sprintf("%s/metrics", srv.URL), t, srv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Want: %d, Get: %d", http.StatusOK, code)
+	}
+
+	wantLines := []string{
+		`snippetbox_http_requests_total{method="GET",route="/snippet/{id:[0-9]+}",status="200"} 1`,
+		`snippetbox_http_requests_total{method="GET",route="/snippet/{id:[0-9]+}",status="404"} 1`,
+		`snippetbox_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`snippetbox_http_request_duration_seconds_count{method="POST",route="/user/login"} 1`,
+		`snippetbox_db_query_duration_seconds_count{op="snippets.get",result="ok"} 1`,
+		`snippetbox_db_query_duration_seconds_count{op="snippets.get",result="error"} 1`,
+		`snippetbox_logins_total{result="success"} 1`,
+	}
+
+	for _, line := range wantLines {
+		if !bytes.Contains(body, []byte(line)) {
+			t.Fatalf("No line %s in metrics:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsOnAdminListener(t *testing.T) {
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{}, &mock.UsersStore{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.metricsAddr = "127.0.0.1:0"
+
+	srv := httptest.NewServer(s.routes())
+	defer srv.Close()
+
+	code, _, _ := get(fmt.Sprintf("%s/metrics", srv.URL), t, srv)
+
+	if code != http.StatusNotFound {
+		t.Fatalf("Want: %d, Get: %d", http.StatusNotFound, code)
+	}
+
+	adminSrv := httptest.NewServer(s.adminRoutes())
+	defer adminSrv.Close()
+
+	code, _, body := get(fmt.Sprintf("%s/metrics", adminSrv.URL), t, adminSrv)
+
+	if code != http.StatusOK || !bytes.Contains(body, []byte("snippetbox_snippets_created_total")) {
+		t.Fatalf("No metrics on admin listener, code: %d", code)
+	}
+}
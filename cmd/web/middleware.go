@@ -2,18 +2,43 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 type contextKey string
 
 var contextKeyUser = contextKey("user")
+var contextKeyRequest = contextKey("request")
+
+const requestIDHeader = "X-Request-ID"
+
+//requestIDRX restricts propagated request IDs, so they are safe to write to logs
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+//requestInfo is filled by middlewares and written with every log entry of request
+type requestInfo struct {
+	id     string
+	userID int64
+}
+
+//quietPaths are not written to access log, orchestrator probes them too often
+var quietPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
 
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
@@ -21,38 +46,126 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
-func (s *Server) loggerMiddleware(next http.Handler) http.Handler {
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	n, err := lrw.ResponseWriter.Write(b)
+	lrw.bytes += n
+	return n, err
+}
+
+//requestID take X-Request-ID of request or generate new one and put it to context and response
+func (s *Server) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestIDHeader)
+			if !requestIDRX.MatchString(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(requestIDHeader, id)
+			ctx := context.WithValue(r.Context(), contextKeyRequest, &requestInfo{id: id})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+}
+
+func (s *Server) loggerMiddleware(router *mux.Router, next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			lwr := &loggingResponseWriter{w, http.StatusOK}
+			start := time.Now()
+			lwr := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 			next.ServeHTTP(lwr, r)
-			s.log.Infof("%s %s %s %s %d", r.Method, r.Proto, r.RemoteAddr, r.RequestURI, lwr.statusCode)
+			if quietPaths[r.URL.Path] {
+				return
+			}
+			s.logger(r).WithFields(logrus.Fields{
+				"method":      r.Method,
+				"proto":       r.Proto,
+				"remote_addr": r.RemoteAddr,
+				"uri":         r.RequestURI,
+				"route":       routeTemplate(router, r),
+				"status":      lwr.statusCode,
+				"bytes":       lwr.bytes,
+				"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			}).Info("Request completed")
 		})
 
 }
 
+//panicResponseWriter remember if response is started, error page can't be written after it
+type panicResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (pw *panicResponseWriter) WriteHeader(code int) {
+	pw.wroteHeader = true
+	pw.ResponseWriter.WriteHeader(code)
+}
+
+func (pw *panicResponseWriter) Write(b []byte) (int, error) {
+	pw.wroteHeader = true
+	return pw.ResponseWriter.Write(b)
+}
+
+//recoverPanic log panic of handler with stack, count it and show internal error page
+func (s *Server) recoverPanic(router *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			pw := &panicResponseWriter{ResponseWriter: w}
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				s.metrics.panics.WithLabelValues(routeTemplate(router, r)).Inc()
+
+				if pw.wroteHeader {
+					s.logger(r).WithField("stack", string(debug.Stack())).Errorf("Panic after response is started: %v", rec)
+					//net/http closes connection without logging
+					panic(http.ErrAbortHandler)
+				}
+
+				w.Header().Set("Connection", "close")
+				s.serverError(w, r, fmt.Errorf("panic: %v", rec))
+			}()
+
+			next.ServeHTTP(pw, r)
+		})
+}
+
 func (s *Server) authUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			session, err := s.session.Get(r, "SID")
 			if err != nil {
-				removeSession(w, r, session)
+				s.removeSession(w, r, session)
 				next.ServeHTTP(w, r)
 				return
 			}
 			if len(session.Values) == 2 {
-				userID := session.Values["userID"].(int64)
+				userID, okID := session.Values["userID"].(int64)
+				logoutHash, okHash := session.Values["logoutHash"].(string)
+				if !okID || !okHash {
+					s.logger(r).Warn("Session has unexpected values, it is removed")
+					s.removeSession(w, r, session)
+					next.ServeHTTP(w, r)
+					return
+				}
 
-				u, err := s.userStore.Get(userID)
+				u, err := s.userStore.Get(r.Context(), userID)
 				if err == models.ErrNoRecord {
-					removeSession(w, r, session)
+					s.removeSession(w, r, session)
 					next.ServeHTTP(w, r)
 					return
 				} else if err != nil {
-					s.serverError(w, err)
+					s.serverError(w, r, err)
 					return
 				}
-				u.LogoutHash = session.Values["logoutHash"].(string)
+				u.LogoutHash = logoutHash
+				getRequestInfo(r).userID = u.ID
 				ctx := context.WithValue(r.Context(), contextKeyUser, u)
 				r = r.WithContext(ctx)
 			}
@@ -81,3 +194,19 @@ func (s *Server) accessOnlyAuth(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 		})
 }
+
+func (s *Server) accessOnlyAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			u := getAuthUserFromRequest(r)
+			if u == nil {
+				http.Redirect(w, r, "/user/login", 303)
+				return
+			}
+			if !u.IsAdmin {
+				s.forbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+}
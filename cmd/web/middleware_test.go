@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := map[string]struct {
+		header  string
+		wantOwn bool
+	}{
+		"Generated":     {"", false},
+		"Propagated":    {"edge-1234.abcd", true},
+		"Invalid chars": {"bad id with {spaces}", false},
+		"Too long":      {strings.Repeat("a", 129), false},
+	}
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{}, &mock.UsersStore{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", srv.URL+"/healthz", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.header != "" {
+				req.Header.Set(requestIDHeader, test.header)
+			}
+
+			rs, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			id := rs.Header.Get(requestIDHeader)
+
+			if test.wantOwn && id != test.header {
+				t.Fatalf("Want: %s, Get: %s", test.header, id)
+			}
+
+			if !test.wantOwn && (id == test.header || !requestIDRX.MatchString(id)) {
+				t.Fatalf("Want generated request ID, Get: %q", id)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, true, 2)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	s.log.SetOutput(&logs)
+	s.log.SetFormatter(&logrus.JSONFormatter{})
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	login(t, srv, "conor@mail.com", "12345678")
+	logs.Reset()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(requestIDHeader, "test-request")
+
+	rs, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	entry := map[string]interface{}{}
+
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Access log is not JSON: %v, %s", err, logs.String())
+	}
+
+	want := map[string]interface{}{
+		"request_id": "test-request",
+		"user_id":    float64(2),
+		"method":     "GET",
+		"route":      "/snippet/{id:[0-9]+}",
+		"status":     float64(http.StatusOK),
+	}
+
+	for key, val := range want {
+		if entry[key] != val {
+			t.Fatalf("Field %s, Want: %v, Get: %v", key, val, entry[key])
+		}
+	}
+
+	if entry["bytes"].(float64) == 0 {
+		t.Fatal("Bytes written are not logged")
+	}
+
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Fatal("Duration is not logged")
+	}
+}
+
+func TestServerErrorLogged(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+
+	var logs bytes.Buffer
+	s.log.SetOutput(&logs)
+	s.log.SetFormatter(&logrus.JSONFormatter{})
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(requestIDHeader, "broken-request")
+
+	rs, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	if rs.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Want: %d, Get: %d", http.StatusInternalServerError, rs.StatusCode)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+
+	for _, line := range lines {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["request_id"] != "broken-request" {
+			t.Fatalf("Log entry without request ID: %s", line)
+		}
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("Want error and access entries, Get: %v", lines)
+	}
+}
+
+func TestServerErrorCanceled(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+
+	var logs bytes.Buffer
+	s.log.SetOutput(&logs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	s.serverError(w, r, context.Canceled)
+
+	if strings.Contains(logs.String(), "level=error") || !strings.Contains(logs.String(), "canceled by client") {
+		t.Fatalf("Canceled request logged as error: %s", logs.String())
+	}
+}
+
+//contextCheckingStore fails test if handler doesn't pass request context to store
+type contextCheckingStore struct {
+	*mock.SnippetStore
+	t *testing.T
+}
+
+func (s *contextCheckingStore) Get(ctx context.Context, snippetID int64) (*models.Snippet, error) {
+	if ctx.Value(contextKeyRequest) == nil {
+		s.t.Error("Store is called without request context")
+	}
+
+	return s.SnippetStore.Get(ctx, snippetID)
+}
+
+func TestRequestContextPassedToStore(t *testing.T) {
+	ss := getTestSnippetData(1, 1, true, 1)
+
+	s, err := NewTestServerWithUI("../../ui/html", &contextCheckingStore{&mock.SnippetStore{DB: ss}, t}, &mock.UsersStore{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	code, _, _ := get(fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), t, srv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Want: %d, Get: %d", http.StatusOK, code)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{}, &mock.UsersStore{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	s.log.SetOutput(&logs)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["boom"]++
+	})
+	r.HandleFunc("/panic/started", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("after write")
+	})
+
+	handler := s.recoverPanic(r, r)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Want: %d, Get: %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "incident ID") {
+		t.Errorf("Error page is not shown: %s", w.Body.String())
+	}
+
+	if !strings.Contains(logs.String(), "assignment to entry in nil map") || !strings.Contains(logs.String(), "stack=") {
+		t.Errorf("Panic is not logged with stack: %s", logs.String())
+	}
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != http.ErrAbortHandler {
+				t.Errorf("Want ErrAbortHandler, Get: %v", rec)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic/started", nil))
+	}()
+
+	for route, want := range map[string]float64{"/panic": 1, "/panic/started": 1} {
+		if got := testutil.ToFloat64(s.metrics.panics.WithLabelValues(route)); got != want {
+			t.Errorf("Panics of %s: want %v, get %v", route, want, got)
+		}
+	}
+}
+
+func TestAuthUserBadSession(t *testing.T) {
+	um := getTestUserData()
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]map[interface{}]interface{}{
+		"Bad user ID":     {"userID": "1", "logoutHash": "hash"},
+		"Bad logout hash": {"userID": int64(1), "logoutHash": 42},
+	}
+
+	for name, values := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			rec := httptest.NewRecorder()
+
+			session, _ := s.session.Get(req, "SID")
+			session.Values = values
+			if err := session.Save(req, rec); err != nil {
+				t.Fatal(err)
+			}
+
+			req = httptest.NewRequest("GET", "/", nil)
+			for _, c := range rec.Result().Cookies() {
+				req.AddCookie(c)
+			}
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Want: %d, Get: %d", http.StatusOK, w.Code)
+			}
+
+			if !strings.Contains(w.Body.String(), "Login") {
+				t.Errorf("User is authenticated with bad session")
+			}
+		})
+	}
+}
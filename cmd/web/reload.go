@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+//templateReloadDelay wait for editor to finish writing before reload
+const templateReloadDelay = 100 * time.Millisecond
+
+//templateErrorRX match file and line from text/template and html/template errors
+var templateErrorRX = regexp.MustCompile(`template: ?([^:\s]+):(\d+)(?::\d+)?: (.*)`)
+
+//reloadTemplates parse templates and replace cache, on error old cache is kept and error is shown by render in DEV_MODE
+func (s *Server) reloadTemplates() error {
+	cache, err := s.loadTemplates()
+
+	s.templateMu.Lock()
+	defer s.templateMu.Unlock()
+
+	s.templateErr = err
+	if err != nil {
+		return err
+	}
+
+	s.templateCache = cache
+	return nil
+}
+
+//getTemplates return current template cache and last parse error
+func (s *Server) getTemplates() (map[string]*template.Template, error) {
+	s.templateMu.RLock()
+	defer s.templateMu.RUnlock()
+	return s.templateCache, s.templateErr
+}
+
+//watchTemplates reload templates when files in dir are changed, it is used in DEV_MODE
+func (s *Server) watchTemplates(ctx context.Context, dir string) error {
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return err
+	}
+
+	defer watcher.Close()
+
+	if err := watcher.Add(dir); err != nil {
+		return err
+	}
+
+	var reload <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			reload = time.After(templateReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			s.log.Warnf("Template watcher error: %v", err)
+		case <-reload:
+			reload = nil
+			if err := s.reloadTemplates(); err != nil {
+				s.log.Errorf("Templates reload failed: %v", err)
+			} else {
+				s.log.Info("Templates reloaded")
+			}
+		}
+	}
+}
+
+type sourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+type templateErrorData struct {
+	Message string
+	File    string
+	Line    int
+	Source  []sourceLine
+}
+
+var templateErrorPage = template.Must(template.New("error").Parse(`<!doctype html>
+<html lang='en'>
+    <head>
+        <meta charset='utf-8'>
+        <title>Template error - Snippetbox</title>
+    </head>
+    <body>
+        <h1>Template error</h1>
+        {{if .File}}<p><strong>{{.File}}:{{.Line}}</strong></p>{{end}}
+        <pre>{{.Message}}</pre>
+        {{if .Source}}
+        <pre>{{range .Source}}{{if .Current}}<mark>{{printf "%4d" .Number}} {{.Text}}</mark>{{else}}{{printf "%4d" .Number}} {{.Text}}{{end}}
+{{end}}</pre>
+        {{end}}
+    </body>
+</html>`))
+
+//parseTemplateError extract file, line and part of template source from error
+func (s *Server) parseTemplateError(err error) *templateErrorData {
+	data := &templateErrorData{Message: err.Error()}
+
+	m := templateErrorRX.FindStringSubmatch(err.Error())
+	if m == nil {
+		return data
+	}
+
+	data.File = m[1]
+	data.Line, _ = strconv.Atoi(m[2])
+
+	src, err := fs.ReadFile(s.ui, "html/"+data.File)
+	if err != nil {
+		return data
+	}
+
+	lines := strings.Split(string(src), "\n")
+	for i := data.Line - 4; i < data.Line+3; i++ {
+		if i >= 0 && i < len(lines) {
+			data.Source = append(data.Source, sourceLine{Number: i + 1, Text: lines[i], Current: i+1 == data.Line})
+		}
+	}
+
+	return data
+}
+
+//templateError show parse or execute error with file and line in DEV_MODE, otherwise it is server error
+func (s *Server) templateError(w http.ResponseWriter, r *http.Request, err error) {
+	if !s.devMode {
+		s.serverError(w, r, err)
+		return
+	}
+
+	s.logger(r).Errorf("Template error: %v", err)
+
+	buf := new(bytes.Buffer)
+	if execErr := templateErrorPage.Execute(buf, s.parseTemplateError(err)); execErr != nil {
+		s.serverError(w, r, execErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+//newDevServer return server in DEV_MODE with templates from temporary directory
+func newDevServer(t *testing.T, page string) (*Server, string) {
+	dir := t.TempDir()
+	html := filepath.Join(dir, "html")
+
+	files := map[string]string{
+		"base.layout.html":    `{{define "base"}}{{end}}`,
+		"footer.partial.html": `{{define "footer"}}{{end}}`,
+		"test.page.html":      page,
+	}
+
+	if err := os.Mkdir(html, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		writeTemplate(t, dir, name, data)
+	}
+
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+	s.devMode = true
+	s.ui = os.DirFS(dir)
+
+	return s, dir
+}
+
+func writeTemplate(t *testing.T, dir, name, data string) {
+	if err := os.WriteFile(filepath.Join(dir, "html", name), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func renderTest(s *Server) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.render(w, httptest.NewRequest("GET", "/", nil), "test", nil)
+	return w
+}
+
+func TestWatchTemplates(t *testing.T) {
+	s, dir := newDevServer(t, "first")
+
+	if err := s.reloadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.watchTemplates(ctx, filepath.Join(dir, "html"))
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	tests := []struct {
+		page     string
+		wantCode int
+		wantBody string
+	}{
+		{"second", 200, "second"},
+		{"{{.Broken", 500, "test.page.html:1"},
+		{"third", 200, "third"},
+	}
+
+	//watcher is added asynchronously, first change may be missed
+	time.Sleep(100 * time.Millisecond)
+
+	for _, test := range tests {
+		writeTemplate(t, dir, "test.page.html", test.page)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			w := renderTest(s)
+			if w.Code == test.wantCode && strings.Contains(w.Body.String(), test.wantBody) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Page %q: wait %d with %q, get %d with %q", test.page, test.wantCode, test.wantBody, w.Code, w.Body.String())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+func TestTemplateErrorPage(t *testing.T) {
+	tests := map[string]struct {
+		page     string
+		devMode  bool
+		wantSee  []string
+		wantHide []string
+	}{
+		"Parse error": {
+			page:    "line 1\nline 2\n{{if}}\nline 4",
+			devMode: true,
+			wantSee: []string{"test.page.html:3", "missing value for if", "line 2", "line 4"},
+		},
+		"Execute error": {
+			page:    "line 1\n{{.Missing}}",
+			devMode: true,
+			wantSee: []string{"test.page.html:2", "can&#39;t evaluate field Missing"},
+		},
+		"Production": {
+			page:     "{{.Missing}}",
+			devMode:  false,
+			wantSee:  []string{"Internal Server Error", "Incident ID"},
+			wantHide: []string{"test.page.html", "Missing"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, _ := newDevServer(t, test.page)
+			s.devMode = test.devMode
+			s.reloadTemplates()
+
+			w := renderTest(s)
+
+			if w.Code != 500 {
+				t.Fatalf("Wait 500, get %d", w.Code)
+			}
+
+			for _, want := range test.wantSee {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("%q not found in %q", want, w.Body.String())
+				}
+			}
+
+			for _, hide := range test.wantHide {
+				if strings.Contains(w.Body.String(), hide) {
+					t.Errorf("%q found in %q", hide, w.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/mailer"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	remindBefore   = 24 * time.Hour
+	remindersLimit = 100
+	//failed reminder is retried after remindRetryDelay, delay is doubled after every failure
+	remindRetryDelay  = 10 * time.Minute
+	maxRemindAttempts = 5
+)
+
+//reminder sends emails to owners of snippets which expire soon
+type reminder struct {
+	store    models.SnippetRepository
+	mailer   mailer.Mailer
+	log      *logrus.Logger
+	interval time.Duration
+	baseURL  string
+}
+
+//remind send reminders for snippets expiring in remindBefore, return count of sent emails
+func (rm *reminder) remind(ctx context.Context) (int, error) {
+	reminders, err := rm.store.ExpiryReminders(ctx, time.Now().UTC().Add(remindBefore), remindersLimit)
+
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+
+	for _, val := range reminders {
+		body := fmt.Sprintf(
+			"Hello, %s!\n\nYour snippet %q expires %s UTC.\nYou can extend it on %s/snippet/%d\n",
+			val.Firstname,
+			val.Snippet.Title,
+			humanDateTime(val.Snippet.Expires),
+			rm.baseURL,
+			val.Snippet.ID,
+		)
+
+		if err = rm.mailer.Send(val.Email, "Your snippet expires soon", body); err != nil {
+			rm.log.Errorf("Error while send reminder for snippet %d: %v", val.Snippet.ID, err)
+
+			if err = rm.failed(ctx, val); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		if err = rm.store.MarkReminderSent(ctx, val.Snippet.ID); err != nil {
+			return sent, err
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+//failed delay next send of reminder, after maxRemindAttempts reminder is marked as sent,
+//so failing addresses don't take places of other reminders forever
+func (rm *reminder) failed(ctx context.Context, val *models.ExpiryReminder) error {
+	if val.Attempts+1 >= maxRemindAttempts {
+		rm.log.Warnf("Reminder for snippet %d is not sent after %d attempts, giving up", val.Snippet.ID, maxRemindAttempts)
+		return rm.store.MarkReminderSent(ctx, val.Snippet.ID)
+	}
+
+	return rm.store.MarkReminderFailed(ctx, val.Snippet.ID, time.Now().UTC().Add(remindRetryDelay<<val.Attempts))
+}
+
+//run send reminders every interval until ctx is done
+func (rm *reminder) run(ctx context.Context) {
+	runPeriodically(ctx, rm.interval, func() {
+		if _, err := rm.remind(ctx); err != nil {
+			rm.log.Errorf("Error while send expiry reminders: %v", err)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	mailmock "githib.com/VladimirStepanov/snippetbox/pkg/mailer/mock"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/sirupsen/logrus"
+)
+
+func getReminderSnippets() []*models.Snippet {
+	ss := getTestSnippetData(1, 4, true, 2)
+	ss[0].RemindExpiry = true
+	ss[1].RemindExpiry = true
+	ss[1].Expires = time.Now().Add(48 * time.Hour)
+	ss[2].RemindExpiry = true
+	ss[2].Expires = time.Time{}
+
+	return ss
+}
+
+func TestRemind(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	tests := map[string]struct {
+		FailSend     bool
+		WantSent     int
+		WantMessages int
+	}{
+		"Send reminder": {
+			WantSent:     1,
+			WantMessages: 1,
+		},
+		"Send failed": {
+			FailSend:     true,
+			WantSent:     0,
+			WantMessages: 0,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ss := getReminderSnippets()
+			store := &mock.SnippetStore{DB: ss, UsersMap: getTestUserData()}
+			m := &mailmock.Mailer{FailSend: test.FailSend}
+			rm := &reminder{store: store, mailer: m, log: logger, baseURL: "http://localhost:8080"}
+
+			sent, err := rm.remind(context.Background())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sent != test.WantSent || len(m.Sent) != test.WantMessages {
+				t.Fatalf("Want sent: %d, Get: %d, messages: %d", test.WantSent, sent, len(m.Sent))
+			}
+
+			if test.WantMessages != 0 {
+				msg := m.Sent[0]
+				if msg.To != "conor@mail.com" || !strings.Contains(msg.Body, ss[0].Title) || !strings.Contains(msg.Body, "http://localhost:8080/snippet/1") {
+					t.Fatalf("Bad message: %v", msg)
+				}
+			}
+
+			sent, err = rm.remind(context.Background())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sent != 0 && !test.FailSend {
+				t.Fatalf("Reminder was sent twice")
+			}
+		})
+	}
+}
+
+func TestRemindRetry(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	store := &mock.SnippetStore{DB: getReminderSnippets(), UsersMap: getTestUserData()}
+	m := &mailmock.Mailer{FailSend: true}
+	rm := &reminder{store: store, mailer: m, log: logger, baseURL: "http://localhost:8080"}
+
+	for attempt := 1; attempt <= maxRemindAttempts; attempt++ {
+		if _, err := rm.remind(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if attempt == maxRemindAttempts {
+			break
+		}
+
+		retry := store.ReminderRetry[1]
+		wantDelay := remindRetryDelay << (attempt - 1)
+
+		if store.ReminderAttempts[1] != attempt || time.Until(retry) < wantDelay-time.Minute || time.Until(retry) > wantDelay {
+			t.Fatalf("Attempt %d: want retry in %s, Get: %d attempts, retry in %s", attempt, wantDelay, store.ReminderAttempts[1], time.Until(retry))
+		}
+
+		if reminders, _ := store.ExpiryReminders(context.Background(), time.Now().Add(remindBefore), remindersLimit); len(reminders) != 0 {
+			t.Fatalf("Attempt %d: failed reminder is not delayed", attempt)
+		}
+
+		store.ReminderRetry[1] = time.Now()
+	}
+
+	if !store.RemindersSent[1] {
+		t.Fatalf("Reminder is not given up after %d attempts", maxRemindAttempts)
+	}
+}
+
+func TestRemindAfterEdit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	store := &mock.SnippetStore{DB: getReminderSnippets(), UsersMap: getTestUserData()}
+	m := &mailmock.Mailer{}
+	rm := &reminder{store: store, mailer: m, log: logger, baseURL: "http://localhost:8080"}
+
+	if sent, err := rm.remind(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("Want 1 sent, Get: %d, %v", sent, err)
+	}
+
+	edited := *store.DB[0]
+	edited.Title = "Fixed typo"
+
+	tests := []struct {
+		Name     string
+		Expires  time.Time
+		WantSent int
+	}{
+		{"Same expiration", edited.Expires, 0},
+		{"New expiration", edited.Expires.Add(time.Hour), 1},
+	}
+
+	for _, test := range tests {
+		edited.Expires = test.Expires
+
+		if err := store.Update(context.Background(), &edited, edited.OwnerID); err != nil {
+			t.Fatal(err)
+		}
+
+		if sent, err := rm.remind(context.Background()); err != nil || sent != test.WantSent {
+			t.Fatalf("%s: want %d sent, Get: %d, %v", test.Name, test.WantSent, sent, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var contextKeyNonce = contextKey("cspNonce")
+
+//securityPolicy is part of security headers which can be changed per route
+type securityPolicy struct {
+	//frameAncestors is CSP frame-ancestors source list, X-Frame-Options: DENY is sent only for 'none'
+	frameAncestors string
+}
+
+var defaultSecurityPolicy = securityPolicy{frameAncestors: "'none'"}
+
+//embedSecurityPolicy allows any site to show page in iframe
+var embedSecurityPolicy = securityPolicy{frameAncestors: "*"}
+
+//newNonce return random value for CSP nonce, URL alphabet is used so html/template doesn't escape it
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func contentSecurityPolicy(nonce string, p securityPolicy) string {
+	return strings.Join([]string{
+		"default-src 'self'",
+		fmt.Sprintf("script-src 'self' 'nonce-%s'", nonce),
+		fmt.Sprintf("style-src 'self' 'nonce-%s'", nonce),
+		"img-src 'self' data:",
+		"font-src 'self'",
+		"object-src 'none'",
+		"base-uri 'none'",
+		"form-action 'self'",
+		"frame-ancestors " + p.frameAncestors,
+	}, "; ")
+}
+
+func setSecurityHeaders(h http.Header, nonce string, p securityPolicy) {
+	h.Set("Content-Security-Policy", contentSecurityPolicy(nonce, p))
+	h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=(), payment=(), usb=()")
+
+	if p.frameAncestors == "'none'" {
+		h.Set("X-Frame-Options", "DENY")
+	} else {
+		h.Del("X-Frame-Options")
+	}
+}
+
+func getCSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(contextKeyNonce).(string)
+	return nonce
+}
+
+//securityHeaders set headers of default policy and put CSP nonce to request context
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			nonce := newNonce()
+			setSecurityHeaders(w.Header(), nonce, defaultSecurityPolicy)
+			r = r.WithContext(context.WithValue(r.Context(), contextKeyNonce, nonce))
+			next.ServeHTTP(w, r)
+		})
+}
+
+//withSecurityPolicy replace default policy for route
+func (s *Server) withSecurityPolicy(p securityPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			setSecurityHeaders(w.Header(), getCSPNonce(r), p)
+			next.ServeHTTP(w, r)
+		})
+}
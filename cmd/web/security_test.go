@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+var nonceRX = regexp.MustCompile(`'nonce-([^']+)'`)
+
+func TestSecurityHeaders(t *testing.T) {
+	um := getTestUserData()
+	ss := append(getTestSnippetData(1, 1, true, 1), getTestSnippetData(2, 1, false, 1)...)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	tests := map[string]struct {
+		path         string
+		wantCode     int
+		wantFrameOpt string
+		wantAncestor string
+	}{
+		"Home":            {"/", 200, "DENY", "frame-ancestors 'none'"},
+		"Login":           {"/user/login", 200, "DENY", "frame-ancestors 'none'"},
+		"Not found":       {"/unknown", 404, "DENY", "frame-ancestors 'none'"},
+		"Embed public":    {fmt.Sprintf("/snippet/%d/embed", ss[0].ID), 200, "", "frame-ancestors *"},
+		"Embed private":   {fmt.Sprintf("/snippet/%d/embed", ss[1].ID), 404, "", "frame-ancestors *"},
+		"Embed not exist": {"/snippet/100000/embed", 404, "", "frame-ancestors *"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + test.path)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.wantCode {
+				t.Fatalf("Wait %d, get %d", test.wantCode, resp.StatusCode)
+			}
+
+			if val := resp.Header.Get("X-Frame-Options"); val != test.wantFrameOpt {
+				t.Errorf("X-Frame-Options: wait %q, get %q", test.wantFrameOpt, val)
+			}
+
+			csp := resp.Header.Get("Content-Security-Policy")
+			if !strings.Contains(csp, test.wantAncestor) {
+				t.Errorf("CSP %q doesn't contain %q", csp, test.wantAncestor)
+			}
+
+			for header, want := range map[string]string{
+				"Referrer-Policy":        "strict-origin-when-cross-origin",
+				"X-Content-Type-Options": "nosniff",
+			} {
+				if val := resp.Header.Get(header); val != want {
+					t.Errorf("%s: wait %q, get %q", header, want, val)
+				}
+			}
+
+			if resp.Header.Get("Permissions-Policy") == "" {
+				t.Errorf("Permissions-Policy is not set")
+			}
+		})
+	}
+}
+
+func TestCSPNonce(t *testing.T) {
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{}, &mock.UsersStore{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	seen := map[string]bool{}
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(srv.URL + "/")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m := nonceRX.FindStringSubmatch(resp.Header.Get("Content-Security-Policy"))
+		if m == nil {
+			t.Fatalf("Nonce not found in CSP %q", resp.Header.Get("Content-Security-Policy"))
+		}
+
+		if seen[m[1]] {
+			t.Errorf("Nonce %s is reused", m[1])
+		}
+		seen[m[1]] = true
+
+		if !strings.Contains(string(body), fmt.Sprintf(`nonce="%s"`, m[1])) {
+			t.Errorf("Nonce %s not found in page", m[1])
+		}
+	}
+}
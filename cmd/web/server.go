@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"database/sql"
 	"html/template"
+	"io/fs"
+	"net"
 	"net/http"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
@@ -12,78 +19,300 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+//database is connection pool used by probes and debug endpoint, implemented by *sql.DB
+type database interface {
+	PingContext(ctx context.Context) error
+	Stats() sql.DBStats
+}
+
 //Server apllication struct
 type Server struct {
-	addr          string
-	log           *logrus.Logger
-	templateCache map[string]*template.Template
-	userStore     models.UserRepository
-	snippetStore  models.SnippetRepository
-	session       *sessions.CookieStore
-	csrfKey       string
+	addr            string
+	shutdownTimeout time.Duration
+	db              database
+	log             *logrus.Logger
+	templateCache   map[string]*template.Template
+	templateErr     error
+	templateMu      sync.RWMutex
+	ui              fs.FS
+	static          *staticFiles
+	devMode         bool
+	userStore       models.UserRepository
+	snippetStore    models.SnippetRepository
+	auditStore      models.AuditRepository
+	attachmentStore models.AttachmentRepository
+	attachments     attachmentConfig
+	session         *sessions.CookieStore
+	csrfKey         string
+	sweeper         *sweeper
+	reminder        *reminder
+	metrics         *metrics
+	metricsAddr     string
+	certs           *certReloader
+	redirectAddr    string
+	hstsMaxAge      time.Duration
+	hstsSubdomains  bool
+	trustedProxies  []*net.IPNet
 }
 
 //Routes return mux.Router with filled routes
 func (s *Server) routes() http.Handler {
 
-	CSRF := csrf.Protect([]byte(s.csrfKey), csrf.Secure(false))
+	csrfSecure := csrf.Protect([]byte(s.csrfKey), csrf.Secure(true), csrf.SameSite(csrf.SameSiteLaxMode), csrf.Path("/"), csrf.ErrorHandler(http.HandlerFunc(s.csrfFailure)))
+	csrfPlain := csrf.Protect([]byte(s.csrfKey), csrf.Secure(false), csrf.SameSite(csrf.SameSiteLaxMode), csrf.Path("/"), csrf.ErrorHandler(http.HandlerFunc(s.csrfFailure)))
 
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(s.notFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(s.methodNotAllowed)
 
-	strPref := http.StripPrefix("/static/", http.FileServer(http.Dir("./ui/static/")))
-	r.PathPrefix("/static/").Handler(strPref)
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", s.static.handler(s.notFound)))
 	r.HandleFunc("/", s.home).Methods("GET")
+	r.HandleFunc("/healthz", s.healthz).Methods("GET")
+	r.HandleFunc("/readyz", s.readyz).Methods("GET")
 	r.Handle("/snippets", s.accessOnlyAuth(http.HandlerFunc(s.userSnippets))).Methods("GET")
 	r.Handle("/snippet/create", s.accessOnlyAuth(http.HandlerFunc(s.createSnippet))).Methods("GET")
 	r.Handle("/snippet/create", s.accessOnlyAuth(http.HandlerFunc(s.createPOST))).Methods("POST")
+	r.Handle("/snippet/preview", s.accessOnlyAuth(http.HandlerFunc(s.previewSnippet))).Methods("POST")
 	r.Handle("/snippet/delete/{id:[0-9]+}", s.accessOnlyAuth(http.HandlerFunc(s.deleteSnippet))).Methods("GET")
 	r.Handle("/snippet/edit/{id:[0-9]+}", s.accessOnlyAuth(http.HandlerFunc(s.editSnippet))).Methods("GET")
 	r.Handle("/snippet/edit/{id:[0-9]+}", s.accessOnlyAuth(http.HandlerFunc(s.editPOST))).Methods("POST")
+	r.Handle("/snippet/expire/{id:[0-9]+}", s.accessOnlyAuth(http.HandlerFunc(s.expireSnippet))).Methods("POST")
 	r.HandleFunc("/snippet/{id:[0-9]+}", s.showSnippet).Methods("GET")
+	r.HandleFunc("/snippet/{id:[0-9]+}/raw", s.rawSnippet).Methods("GET")
+	r.HandleFunc("/snippet/{id:[0-9]+}/raw/{filename}", s.rawFile).Methods("GET")
+	r.HandleFunc("/snippet/{id:[0-9]+}/zip", s.zipSnippet).Methods("GET")
+	r.Handle("/snippet/{id:[0-9]+}/fork", s.accessOnlyAuth(http.HandlerFunc(s.forkSnippet))).Methods("POST")
+	r.HandleFunc("/snippet/{id:[0-9]+}/forks", s.snippetForks).Methods("GET")
+	r.Handle("/snippet/{id:[0-9]+}/attachments", s.accessOnlyAuth(http.HandlerFunc(s.uploadAttachment))).Methods("POST")
+	r.HandleFunc("/snippet/{id:[0-9]+}/attachments/{aid:[0-9]+}", s.downloadAttachment).Methods("GET")
+	r.Handle("/snippet/{id:[0-9]+}/attachments/{aid:[0-9]+}/delete", s.accessOnlyAuth(http.HandlerFunc(s.deleteAttachment))).Methods("POST")
+	r.Handle("/snippet/{id:[0-9]+}/embed", s.withSecurityPolicy(embedSecurityPolicy, http.HandlerFunc(s.embedSnippet))).Methods("GET")
 	r.Handle("/user/signup", s.accessOnlyNotAuth(http.HandlerFunc(s.signUpPOST))).Methods("POST")
 	r.Handle("/user/signup", s.accessOnlyNotAuth(http.HandlerFunc(s.signUp))).Methods("GET")
 	r.Handle("/user/login", s.accessOnlyNotAuth(http.HandlerFunc(s.showLogin))).Methods("GET")
 	r.Handle("/user/login", s.accessOnlyNotAuth(http.HandlerFunc(s.loginPOST))).Methods("POST")
 	r.Handle("/user/logout", s.accessOnlyAuth(http.HandlerFunc(s.logout))).Methods("GET")
-	return s.loggerMiddleware(s.authUser(CSRF(r)))
+	r.Handle("/user/activity", s.accessOnlyAuth(http.HandlerFunc(s.userActivity))).Methods("GET")
+	r.Handle("/admin/audit", s.accessOnlyAdmin(http.HandlerFunc(s.adminAudit))).Methods("GET")
+	r.Handle("/admin/audit/export", s.accessOnlyAdmin(http.HandlerFunc(s.adminAuditExport))).Methods("GET")
+	r.HandleFunc("/api/snippets", s.apiSnippets).Methods("GET")
+	r.HandleFunc("/api/snippets/{id:[0-9]+}/forks", s.apiSnippetForks).Methods("GET")
+	r.HandleFunc("/api/snippets/{id:[0-9]+}/fork", s.apiForkSnippet).Methods("POST")
+
+	if s.metricsAddr == "" {
+		r.Handle("/debug/db", s.accessOnlyAdmin(http.HandlerFunc(s.debugDB))).Methods("GET")
+		r.Handle("/metrics", s.metrics.handler()).Methods("GET")
+	}
+
+	r.Use(s.handlerSpan)
+
+	chain := s.metricsMiddleware(r, s.compress(s.securityHeaders(s.recoverPanic(r, s.hsts(s.traced("authUser", s.authUser(s.limitUploads(s.secureSwitch(csrfSecure(r), csrfPlain(r))))))))))
+
+	return s.requestID(s.tracing(r, s.loggerMiddleware(r, chain)))
+}
+
+//adminRoutes return handler for separate admin listener
+func (s *Server) adminRoutes() http.Handler {
+	r := mux.NewRouter()
+	r.Handle("/metrics", s.metrics.handler()).Methods("GET")
+	r.HandleFunc("/debug/db", s.debugDB).Methods("GET")
+	return r
 }
 
-//Start listen and serve
-func (s *Server) Start() error {
+//Start listen and serve until ctx is done, then gracefully shutdown
+func (s *Server) Start(ctx context.Context) error {
+
+	if err := s.reloadTemplates(); err != nil {
+		if !s.devMode {
+			return err
+		}
+		s.log.Errorf("Templates are broken: %v", err)
+	}
 
-	templateCache, err := newTemplateCache("./ui/html")
+	ln, err := net.Listen("tcp", s.addr)
 
 	if err != nil {
 		return err
 	}
 
-	s.templateCache = templateCache
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+
+	if s.sweeper.interval > 0 {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			s.sweeper.run(jobsCtx)
+		}()
+	}
+
+	if s.devMode {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			if err := s.watchTemplates(jobsCtx, filepath.Join(uiDir, "html")); err != nil {
+				s.log.Errorf("Templates aren't watched: %v", err)
+			}
+		}()
+	}
+
+	if s.reminder.interval > 0 {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			s.reminder.run(jobsCtx)
+		}()
+	}
+
+	var tlsConfig *tls.Config
+
+	if s.certs != nil {
+		tlsConfig = s.certs.tlsConfig()
+
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			s.certs.watch(jobsCtx, certCheckInterval)
+		}()
+	}
+
+	if s.redirectAddr != "" {
+		redirectLn, err := net.Listen("tcp", s.redirectAddr)
+		if err != nil {
+			cancelJobs()
+			ln.Close()
+			return err
+		}
 
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			s.log.Infof("Redirect to HTTPS server start at addr %s\n", s.redirectAddr)
+			if err := s.serve(ctx, redirectLn, http.HandlerFunc(s.redirectToHTTPS), nil); err != nil {
+				s.log.Errorf("Error while serve redirect server: %v", err)
+			}
+		}()
+	}
+
+	if s.metricsAddr != "" {
+		adminLn, err := net.Listen("tcp", s.metricsAddr)
+		if err != nil {
+			cancelJobs()
+			ln.Close()
+			return err
+		}
+
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			s.log.Infof("Admin server start at addr %s\n", s.metricsAddr)
+			if err := s.serve(ctx, adminLn, s.adminRoutes(), nil); err != nil {
+				s.log.Errorf("Error while serve admin server: %v", err)
+			}
+		}()
+	}
+
+	s.log.Infof("Server start at addr %s, TLS: %t\n", s.addr, tlsConfig != nil)
+
+	err = s.serve(ctx, ln, s.routes(), tlsConfig)
+
+	cancelJobs()
+	jobs.Wait()
+
+	return err
+}
+
+//serve handler on ln until ctx is done, then wait active requests at most shutdownTimeout.
+//Connections are TLS if tlsConfig is not nil
+func (s *Server) serve(ctx context.Context, ln net.Listener, handler http.Handler, tlsConfig *tls.Config) error {
 	srv := &http.Server{
-		Handler:      s.routes(),
-		Addr:         s.addr,
+		Handler:      handler,
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
+		TLSConfig:    tlsConfig,
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		if tlsConfig != nil {
+			serveErr <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
 	}
 
-	s.log.Infof("Server start at addr %s\n", s.addr)
+	s.log.Infof("Shutdown server at addr %s, timeout %s\n", ln.Addr(), s.shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-serveErr; err != http.ErrServerClosed {
+		return err
+	}
 
-	return srv.ListenAndServe()
+	return nil
 }
 
 //New return new Server instance
 func New(
 	config *Config,
+	m *metrics,
+	db database,
 	ur models.UserRepository,
 	sr models.SnippetRepository,
+	ar models.AuditRepository,
+	atr models.AttachmentRepository,
 ) *Server {
 
 	return &Server{
-		addr:         config.addr,
-		log:          config.log,
-		userStore:    ur,
-		snippetStore: sr,
-		session:      config.sessionStore,
-		csrfKey:      config.csrfKey,
+		addr:            config.addr,
+		shutdownTimeout: config.shutdownTimeout,
+		db:              db,
+		log:             config.log,
+		ui:              config.ui,
+		static:          config.static,
+		devMode:         config.devMode,
+		userStore:       ur,
+		snippetStore:    sr,
+		auditStore:      ar,
+		attachmentStore: atr,
+		attachments:     config.attachments,
+		session:         config.sessionStore,
+		csrfKey:         config.csrfKey,
+		metrics:         m,
+		metricsAddr:     config.metricsAddr,
+		certs:           config.certs,
+		redirectAddr:    config.redirectAddr,
+		hstsMaxAge:      config.hstsMaxAge,
+		hstsSubdomains:  config.hstsSubdomains,
+		trustedProxies:  config.trustedProxies,
+		sweeper: &sweeper{
+			store:       sr,
+			attachments: atr,
+			blobs:       config.attachments.store,
+			log:         config.log,
+			metrics:     m,
+			interval:    config.purge.interval,
+			batchSize:   config.purge.batchSize,
+			grace:       config.purge.grace,
+		},
+		reminder: &reminder{
+			store:    sr,
+			mailer:   config.mailer,
+			log:      config.log,
+			interval: config.remindEvery,
+			baseURL:  config.baseURL,
+		},
 	}
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+func TestProbes(t *testing.T) {
+	tests := map[string]struct {
+		path      string
+		pingErr   error
+		templates bool
+		wantCode  int
+	}{
+		"Healthz":             {"/healthz", errors.New("connection refused"), false, http.StatusOK},
+		"Ready":               {"/readyz", nil, true, http.StatusOK},
+		"Database is down":    {"/readyz", errors.New("connection refused"), true, http.StatusServiceUnavailable},
+		"Templates not ready": {"/readyz", nil, false, http.StatusServiceUnavailable},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{}, &mock.UsersStore{})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s.db = &fakeDB{err: test.pingErr}
+
+			if !test.templates {
+				s.templateCache = nil
+			}
+
+			var logs bytes.Buffer
+			s.log.SetOutput(&logs)
+
+			srv := NewHttptestServer(t, s.routes())
+			defer srv.Close()
+
+			code, _, _ := get(fmt.Sprintf("%s%s", srv.URL, test.path), t, srv)
+
+			if code != test.wantCode {
+				t.Fatalf("Want: %d, Get: %d", test.wantCode, code)
+			}
+
+			if strings.Contains(logs.String(), test.path) {
+				t.Fatalf("Probe %s written to access log: %s", test.path, logs.String())
+			}
+		})
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+	s.shutdownTimeout = 5 * time.Second
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- s.serve(ctx, ln, handler, nil)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+
+	go func() {
+		rs, err := http.Get(fmt.Sprintf("http://%s/", ln.Addr()))
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer rs.Body.Close()
+		body, err := ioutil.ReadAll(rs.Body)
+		resCh <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-resCh
+
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("In-flight request was dropped: %q, %v", res.body, res.err)
+	}
+
+	if err := <-serveErr; err != nil {
+		t.Fatalf("Unexpected error from serve: %v", err)
+	}
+
+	if _, err := http.Get(fmt.Sprintf("http://%s/", ln.Addr())); err == nil {
+		t.Fatal("Server accepts connections after shutdown")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+	s.shutdownTimeout = 50 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- s.serve(ctx, ln, handler, nil)
+	}()
+
+	go http.Get(fmt.Sprintf("http://%s/", ln.Addr()))
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-serveErr:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("Want: %v, Get: %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve didn't return after shutdown timeout")
+	}
+}
+
+func TestDebugDB(t *testing.T) {
+	um := getTestUserData()
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.db = &fakeDB{stats: sql.DBStats{MaxOpenConnections: 25, OpenConnections: 3, InUse: 1, Idle: 2, WaitDuration: time.Second}}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	code, _, _ := get(fmt.Sprintf("%s/debug/db", srv.URL), t, srv)
+
+	if code != http.StatusForbidden {
+		t.Fatalf("Want: %d, Get: %d", http.StatusForbidden, code)
+	}
+
+	setClearCookieJar(t, srv)
+	login(t, srv, "admin@mail.com", "12345678")
+
+	code, _, body := get(fmt.Sprintf("%s/debug/db", srv.URL), t, srv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Want: %d, Get: %d", http.StatusOK, code)
+	}
+
+	stats := dbStats{}
+
+	if err := json.Unmarshal(body, &stats); err != nil {
+		t.Fatal(err)
+	}
+
+	want := dbStats{MaxOpenConnections: 25, OpenConnections: 3, InUse: 1, Idle: 2, WaitDuration: "1s"}
+
+	if stats != want {
+		t.Fatalf("Want: %+v, Get: %+v", want, stats)
+	}
+
+	s.metricsAddr = "127.0.0.1:0"
+	adminSrv := httptest.NewServer(s.adminRoutes())
+	defer adminSrv.Close()
+
+	code, _, _ = get(fmt.Sprintf("%s/debug/db", adminSrv.URL), t, adminSrv)
+
+	if code != http.StatusOK {
+		t.Fatalf("Want: %d, Get: %d", http.StatusOK, code)
+	}
+}
+
+func TestCSRFCookiePath(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest("GET", "/user/login", nil))
+
+	cookies := w.Result().Cookies()
+
+	if len(cookies) == 0 {
+		t.Fatal("No CSRF cookie")
+	}
+
+	for _, c := range cookies {
+		if c.Path != "/" {
+			t.Fatalf("Cookie %s has path %q, token of one page must be valid for forms of others", c.Name, c.Path)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+//setting is one configuration value. It's set by flag --lower-dashed-key, env variable KEY or
+//config file key lower_key (or nested sections, e.g. purge: {interval: 1h} for PURGE_INTERVAL)
+type setting struct {
+	key    string
+	def    string
+	usage  string
+	secret bool
+}
+
+var settings = []setting{
+	{key: "ENV", def: "development", usage: "development or production, production refuses default secrets"},
+	{key: "ADDR", def: "0.0.0.0", usage: "listen address"},
+	{key: "PORT", def: "8080", usage: "listen port"},
+	{key: "LOG_LEVEL", def: "INFO", usage: "log level"},
+	{key: "LOG_FORMAT", def: "text", usage: "log format: text or json"},
+	{key: "DEV_MODE", def: "false", usage: "read templates and static files from ./ui instead of embedded copy, reload templates on change"},
+	{key: "SESSION_KEY", def: "session_key", usage: "session cookie signing key", secret: true},
+	{key: "CSRF_KEY", def: "csrf_key", usage: "CSRF token signing key", secret: true},
+	{key: "DSN", def: "root:123@/snippetbox?parseTime=true", usage: "MySQL data source name", secret: true},
+	{key: "DB_MAX_OPEN_CONNS", def: "25", usage: "max open database connections, 0 is unlimited"},
+	{key: "DB_MAX_IDLE_CONNS", def: "25", usage: "max idle database connections"},
+	{key: "DB_CONN_MAX_LIFETIME", def: "5m", usage: "max lifetime of database connection"},
+	{key: "DB_CONN_MAX_IDLE_TIME", def: "5m", usage: "max idle time of database connection"},
+	{key: "DB_CONNECT_TIMEOUT", def: "30s", usage: "how long to wait for database on start"},
+	{key: "CACHE_BACKEND", def: "memory", usage: "snippets cache: none, memory or redis"},
+	{key: "CACHE_SIZE", def: "1000", usage: "max entries of memory cache"},
+	{key: "CACHE_TTL", def: "1m", usage: "how long cached snippets and lists live"},
+	{key: "REDIS_URL", def: "", usage: "Redis URL for redis cache backend, e.g. redis://:password@localhost:6379/0", secret: true},
+	{key: "ATTACHMENT_MAX_SIZE", def: "10485760", usage: "max size of snippet attachment in bytes"},
+	{key: "ATTACHMENT_TYPES", def: "text/plain,application/pdf,application/zip,application/x-gzip,image/png,image/jpeg,image/gif,application/octet-stream", usage: "comma separated allowed types of attachments, type is detected from content"},
+	{key: "BLOB_BACKEND", def: "local", usage: "attachments storage: local or s3"},
+	{key: "BLOB_DIR", def: "data/attachments", usage: "directory of local attachments storage"},
+	{key: "S3_ENDPOINT", def: "", usage: "host:port of S3 compatible storage for s3 backend, e.g. s3.amazonaws.com"},
+	{key: "S3_REGION", def: "", usage: "region of S3 bucket"},
+	{key: "S3_BUCKET", def: "", usage: "bucket of attachments for s3 backend"},
+	{key: "S3_ACCESS_KEY", def: "", usage: "access key of S3 compatible storage", secret: true},
+	{key: "S3_SECRET_KEY", def: "", usage: "secret key of S3 compatible storage", secret: true},
+	{key: "S3_USE_SSL", def: "true", usage: "connect to S3 compatible storage with HTTPS"},
+	{key: "QUERY_TIMEOUT", def: "5s", usage: "timeout of one database query"},
+	{key: "PURGE_INTERVAL", def: "1h", usage: "interval of expired snippets purge, 0 disables it"},
+	{key: "PURGE_BATCH_SIZE", def: "500", usage: "max snippets removed by one query"},
+	{key: "PURGE_GRACE_PERIOD", def: "24h", usage: "remove snippets expired longer than grace period"},
+	{key: "REMINDER_INTERVAL", def: "10m", usage: "interval of expiry reminders check, 0 disables it"},
+	{key: "SMTP_ADDR", def: "", usage: "SMTP server host:port, reminders are logged if empty"},
+	{key: "SMTP_FROM", def: "snippetbox@localhost", usage: "sender of emails"},
+	{key: "SMTP_USERNAME", def: "", usage: "SMTP username"},
+	{key: "SMTP_PASSWORD", def: "", usage: "SMTP password", secret: true},
+	{key: "BASE_URL", def: "", usage: "public URL of app for links in emails, http://localhost:PORT if empty"},
+	{key: "TLS_CERT_FILE", def: "", usage: "TLS certificate file, server speaks HTTPS if set, reloaded on change"},
+	{key: "TLS_KEY_FILE", def: "", usage: "TLS private key file"},
+	{key: "TLS_REDIRECT_ADDR", def: "", usage: "plain HTTP listener redirecting to HTTPS, e.g. 0.0.0.0:80"},
+	{key: "HSTS_MAX_AGE", def: "8760h", usage: "Strict-Transport-Security max-age for HTTPS requests, 0 disables it"},
+	{key: "HSTS_INCLUDE_SUBDOMAINS", def: "false", usage: "apply Strict-Transport-Security to all subdomains, enable only if all of them serve HTTPS"},
+	{key: "TRUSTED_PROXIES", def: "", usage: "comma separated IPs or CIDRs of proxies whose X-Forwarded-Proto and X-Forwarded-For are trusted"},
+	{key: "METRICS_ADDR", def: "", usage: "separate listener for metrics and debug endpoints"},
+	{key: "SHUTDOWN_TIMEOUT", def: "15s", usage: "how long to wait active requests on shutdown"},
+	{key: "TRACE_EXPORTER", def: "none", usage: "trace exporter: none, stdout or otlp"},
+}
+
+func findSetting(key string) (setting, bool) {
+	for _, val := range settings {
+		if val.key == key {
+			return val, true
+		}
+	}
+
+	return setting{}, false
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+//configSource resolve settings: flags override env variables, env variables override config file,
+//config file overrides conf.env
+type configSource struct {
+	flags  map[string]string
+	file   map[string]string
+	dotenv map[string]string
+}
+
+//loadConfigSource parse global flags, config file and dotenv file, return rest of args
+func loadConfigSource(args []string, dotenvPath string, out io.Writer) (*configSource, []string, error) {
+	fs := flag.NewFlagSet("snippetbox", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: snippetbox [flags] [purge|config print]\n\nPrecedence: flags > env variables > config file > conf.env > defaults\n\n")
+		fs.PrintDefaults()
+	}
+
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file (env CONFIG_FILE)")
+	keys := map[string]string{}
+
+	for _, val := range settings {
+		fs.String(flagName(val.key), val.def, fmt.Sprintf("%s (env %s)", val.usage, val.key))
+		keys[flagName(val.key)] = val.key
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	src := &configSource{flags: map[string]string{}, file: map[string]string{}, dotenv: map[string]string{}}
+
+	fs.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok {
+			src.flags[key] = f.Value.String()
+		}
+	})
+
+	if *configPath != "" {
+		var err error
+		if src.file, err = readConfigFile(*configPath); err != nil {
+			return nil, nil, fmt.Errorf("config file %s: %v", *configPath, err)
+		}
+	}
+
+	if dotenv, err := godotenv.Read(dotenvPath); err == nil {
+		src.dotenv = dotenv
+	} else if !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%s: %v", dotenvPath, err)
+	}
+
+	return src, fs.Args(), nil
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	res := map[string]string{}
+
+	if err = flattenConfig("", raw, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+//flattenConfig convert nested sections to setting keys, unknown keys are errors
+func flattenConfig(prefix string, raw map[string]interface{}, res map[string]string) error {
+	for name, value := range raw {
+		key := strings.ToUpper(name)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flattenConfig(key, v, res); err != nil {
+				return err
+			}
+		case []interface{}:
+			return fmt.Errorf("%s: lists are not supported", strings.ToLower(key))
+		default:
+			if _, ok := findSetting(key); !ok {
+				return fmt.Errorf("unknown setting %s", strings.ToLower(key))
+			}
+			if v == nil {
+				res[key] = ""
+			} else {
+				res[key] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	return nil
+}
+
+//lookup return value of setting and where it is taken from
+func (cs *configSource) lookup(key string) (string, string) {
+	st, ok := findSetting(key)
+	if !ok {
+		panic(fmt.Sprintf("setting %s is not declared", key))
+	}
+
+	if val, ok := cs.flags[key]; ok {
+		return val, "flag"
+	}
+
+	if val := os.Getenv(key); val != "" {
+		return val, "env"
+	}
+
+	if val, ok := cs.file[key]; ok {
+		return val, "file"
+	}
+
+	if val := cs.dotenv[key]; val != "" {
+		return val, "conf.env"
+	}
+
+	return st.def, "default"
+}
+
+func (cs *configSource) String(key string) string {
+	val, _ := cs.lookup(key)
+	return val
+}
+
+func (cs *configSource) Int(key string) (int, error) {
+	res, err := strconv.Atoi(cs.String(key))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", key, err)
+	}
+	return res, nil
+}
+
+func (cs *configSource) Bool(key string) (bool, error) {
+	res, err := strconv.ParseBool(cs.String(key))
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", key, err)
+	}
+	return res, nil
+}
+
+func (cs *configSource) Duration(key string) (time.Duration, error) {
+	res, err := time.ParseDuration(cs.String(key))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", key, err)
+	}
+	return res, nil
+}
+
+//isDefault report if setting is not changed by any source
+func (cs *configSource) isDefault(key string) bool {
+	_, from := cs.lookup(key)
+	return from == "default"
+}
+
+//print write effective settings with their sources, secrets are redacted
+func (cs *configSource) print(out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+
+	sorted := append([]setting{}, settings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].key < sorted[j].key })
+
+	for _, st := range sorted {
+		val, from := cs.lookup(st.key)
+		if st.secret && val != "" {
+			val = "[redacted]"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", st.key, val, from)
+	}
+
+	return tw.Flush()
+}
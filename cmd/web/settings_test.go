@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	configPath := writeTestFile(t, "config.yaml", `
+port: 9000
+addr: 127.0.0.1
+log_level: debug
+purge:
+  interval: 2h
+  batch_size: 100
+`)
+	dotenvPath := writeTestFile(t, "conf.env", "PORT=7000\nADDR=10.0.0.1\nSMTP_FROM=dotenv@mail.com\nPURGE_BATCH_SIZE=50")
+
+	t.Setenv("PORT", "")
+	t.Setenv("ADDR", "")
+	t.Setenv("SMTP_FROM", "")
+	t.Setenv("PURGE_BATCH_SIZE", "")
+	t.Setenv("PURGE_INTERVAL", "")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	src, rest, err := loadConfigSource(
+		[]string{"--config", configPath, "--port", "9100", "purge", "--dry-run"},
+		dotenvPath,
+		ioutil.Discard,
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rest) != 2 || rest[0] != "purge" {
+		t.Fatalf("Wrong rest of args: %v", rest)
+	}
+
+	tests := map[string]struct {
+		key      string
+		wantVal  string
+		wantFrom string
+	}{
+		"Flag over file and dotenv": {"PORT", "9100", "flag"},
+		"Env over file":             {"LOG_LEVEL", "warn", "env"},
+		"File over dotenv":          {"ADDR", "127.0.0.1", "file"},
+		"Nested file section":       {"PURGE_INTERVAL", "2h", "file"},
+		"Nested over dotenv":        {"PURGE_BATCH_SIZE", "100", "file"},
+		"Dotenv over default":       {"SMTP_FROM", "dotenv@mail.com", "conf.env"},
+		"Default":                   {"QUERY_TIMEOUT", "5s", "default"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			val, from := src.lookup(test.key)
+
+			if val != test.wantVal || from != test.wantFrom {
+				t.Fatalf("Want: %s from %s, Get: %s from %s", test.wantVal, test.wantFrom, val, from)
+			}
+		})
+	}
+
+	config, err := NewConfig(src)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.addr != "127.0.0.1:9100" || config.purge.batchSize != 100 || config.baseURL != "http://localhost:9100" {
+		t.Fatalf("Wrong config: %s %d %s", config.addr, config.purge.batchSize, config.baseURL)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	secret := strings.Repeat("s", minSecretLength)
+
+	tests := map[string]struct {
+		args    []string
+		file    string
+		env     map[string]string
+		wantErr string
+	}{
+		"Unknown file key":          {file: "sesion_key: abc", wantErr: "unknown setting sesion_key"},
+		"Unknown nested key":        {file: "purge:\n  intervl: 1h", wantErr: "unknown setting purge_intervl"},
+		"List in file":              {file: "addr: [a, b]", wantErr: "lists are not supported"},
+		"Unknown flag":              {args: []string{"--sesion-key", "abc"}, wantErr: "flag provided but not defined"},
+		"Wrong duration":            {args: []string{"--query-timeout", "5"}, wantErr: "QUERY_TIMEOUT"},
+		"Wrong port":                {args: []string{"--port", "70000"}, wantErr: "PORT"},
+		"Unknown env":               {args: []string{"--env", "staging"}, wantErr: "ENV"},
+		"Production default key":    {args: []string{"--env", "production"}, wantErr: "SESSION_KEY has default value"},
+		"Production short key":      {args: []string{"--env", "production", "--session-key", "short", "--csrf-key", secret}, wantErr: "SESSION_KEY is shorter"},
+		"Production default DSN":    {args: []string{"--env", "production", "--session-key", secret, "--csrf-key", secret}, wantErr: "DSN has default value"},
+		"Production example key":    {file: "env: production\ncsrf_key: change-me-to-random-string-at-least-32-bytes", args: []string{"--session-key", secret}, wantErr: "CSRF_KEY is a placeholder"},
+		"Production secrets in env": {args: []string{"--env", "production"}, env: map[string]string{"SESSION_KEY": secret, "CSRF_KEY": secret, "DSN": "app:pass@/snippetbox"}},
+		"Unknown cache backend":     {args: []string{"--cache-backend", "memcached"}, wantErr: "CACHE_BACKEND"},
+		"Redis without URL":         {args: []string{"--cache-backend", "redis"}, wantErr: "REDIS_URL must be set"},
+		"Wrong Redis URL":           {args: []string{"--cache-backend", "redis", "--redis-url", "localhost:6379"}, wantErr: "REDIS_URL"},
+		"Unknown blob backend":      {args: []string{"--blob-backend", "ftp"}, wantErr: "BLOB_BACKEND"},
+		"S3 without bucket":         {args: []string{"--blob-backend", "s3", "--s3-endpoint", "localhost:9000"}, wantErr: "S3_BUCKET must be set"},
+		"S3 backend":                {args: []string{"--blob-backend", "s3", "--s3-endpoint", "localhost:9000", "--s3-bucket", "attachments"}},
+		"Zero attachment size":      {args: []string{"--attachment-max-size", "0"}, wantErr: "ATTACHMENT_MAX_SIZE"},
+		"Development default keys":  {args: []string{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"SESSION_KEY", "CSRF_KEY", "DSN", "ENV", "PORT"} {
+				t.Setenv(key, test.env[key])
+			}
+
+			args := test.args
+			if test.file != "" {
+				args = append([]string{"--config", writeTestFile(t, "config.yaml", test.file)}, args...)
+			}
+
+			src, _, err := loadConfigSource(args, "not-exists.env", ioutil.Discard)
+
+			if err == nil {
+				_, err = NewConfig(src)
+			}
+
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("Want error: %s, Get: %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProductionDevMode(t *testing.T) {
+	secret := strings.Repeat("s", minSecretLength)
+	t.Setenv("DEV_MODE", "true")
+	t.Setenv("SESSION_KEY", secret)
+	t.Setenv("CSRF_KEY", secret)
+	t.Setenv("DSN", "app:pass@/snippetbox")
+
+	for env, want := range map[string]bool{"development": true, "production": false} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv("ENV", env)
+
+			src, _, err := loadConfigSource(nil, "not-exists.env", ioutil.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := NewConfig(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if config.devMode != want {
+				t.Fatalf("Want DEV_MODE: %t, Get: %t", want, config.devMode)
+			}
+		})
+	}
+}
+
+func TestConfigPrint(t *testing.T) {
+	t.Setenv("SMTP_PASSWORD", "smtp-secret")
+	t.Setenv("SESSION_KEY", "")
+
+	src, _, err := loadConfigSource([]string{"--session-key", "session-secret", "--port", "8081"}, "not-exists.env", ioutil.Discard)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+
+	if err := configCommand([]string{"print"}, out, src); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"smtp-secret", "session-secret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Fatalf("Secret %s is printed: %s", secret, out.String())
+		}
+	}
+
+	wantLines := [][]string{
+		{"PORT", "8081", "flag"},
+		{"SMTP_PASSWORD", "[redacted]", "env"},
+		{"SESSION_KEY", "[redacted]", "flag"},
+		{"SMTP_USERNAME", "default"},
+	}
+
+	for _, fields := range wantLines {
+		found := false
+		for _, line := range strings.Split(out.String(), "\n") {
+			if strings.Join(strings.Fields(line), " ") == strings.Join(fields, " ") {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("No line %v in output:\n%s", fields, out.String())
+		}
+	}
+
+	if err := configCommand([]string{"show"}, out, src); err == nil {
+		t.Fatal("Want usage error for unknown subcommand")
+	}
+}
+
+func TestMain(m *testing.M) {
+	for _, st := range settings {
+		os.Unsetenv(st.key)
+	}
+
+	os.Exit(m.Run())
+}
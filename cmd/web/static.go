@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/ui"
+)
+
+//uiDir is read instead of embedded files in DEV_MODE
+const uiDir = "./ui"
+
+const hashLength = 12
+
+//hashedNameRX match name.<hash>.ext
+var hashedNameRX = regexp.MustCompile(`^(.+)\.([0-9a-f]{12})(\.[^./]+)$`)
+
+//uiFiles return filesystem with html and static directories
+func uiFiles(devMode bool) fs.FS {
+	if devMode {
+		return os.DirFS(uiDir)
+	}
+	return ui.Files
+}
+
+//staticFiles serve static assets, URLs of assets contain hash of content and are cached forever
+type staticFiles struct {
+	fsys fs.FS
+	//hashes by file name, nil means files are changed on disk and URLs aren't hashed
+	hashes map[string]string
+}
+
+//newStaticFiles create static files from directory static of ui, hashed is false in DEV_MODE
+func newStaticFiles(uiFS fs.FS, hashed bool) (*staticFiles, error) {
+	fsys, err := fs.Sub(uiFS, "static")
+
+	if err != nil {
+		return nil, err
+	}
+
+	sf := &staticFiles{fsys: fsys}
+
+	if !hashed {
+		return sf, nil
+	}
+
+	sf.hashes = map[string]string{}
+
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		sf.hashes[name] = hex.EncodeToString(sum[:])[:hashLength]
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sf, nil
+}
+
+//url return URL of asset, template function static
+func (sf *staticFiles) url(name string) string {
+	hash, ok := sf.hashes[name]
+
+	if !ok {
+		return "/static/" + name
+	}
+
+	ext := path.Ext(name)
+	return "/static/" + strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+//handler serve files without /static/ prefix, hashed URL gets immutable cache headers,
+//missing files are answered by notFound like other pages
+func (sf *staticFiles) handler(notFound http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			sf.serve(w, r, notFound)
+		},
+	)
+}
+
+func (sf *staticFiles) serve(w http.ResponseWriter, r *http.Request, notFound http.HandlerFunc) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	immutable := false
+
+	if m := hashedNameRX.FindStringSubmatch(name); m != nil {
+		if orig := m[1] + m[3]; sf.hashes[orig] == m[2] {
+			name = orig
+			immutable = true
+		}
+	}
+
+	if !fs.ValidPath(name) {
+		notFound(w, r)
+		return
+	}
+
+	data, err := fs.ReadFile(sf.fsys, name)
+
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+
+	if immutable {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if hash, ok := sf.hashes[name]; ok {
+		w.Header().Set("ETag", `"`+hash+`"`)
+	}
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+)
+
+func TestStaticFiles(t *testing.T) {
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	css, err := os.ReadFile("../../ui/static/css/main.css")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashed := s.static.url("css/main.css")
+
+	if !regexp.MustCompile(`^/static/css/main\.[0-9a-f]{12}\.css$`).MatchString(hashed) {
+		t.Fatalf("Bad hashed URL %s", hashed)
+	}
+
+	tests := map[string]struct {
+		path      string
+		wantCode  int
+		wantCache string
+	}{
+		"Hashed":      {hashed, 200, "public, max-age=31536000, immutable"},
+		"Plain":       {"/static/css/main.css", 200, "no-cache"},
+		"Font":        {"/static/fonts/Go-Mono.ttf", 200, "no-cache"},
+		"Stale hash":  {"/static/css/main.000000000000.css", 404, ""},
+		"Directory":   {"/static/css/", 404, ""},
+		"Not existed": {"/static/css/none.css", 404, ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			code, header, body := get(srv.URL+test.path, t, srv)
+
+			if code != test.wantCode {
+				t.Fatalf("Wait %d, get %d", test.wantCode, code)
+			}
+
+			if val := header.Get("Cache-Control"); code == 200 && val != test.wantCache {
+				t.Errorf("Cache-Control: wait %q, get %q", test.wantCache, val)
+			}
+
+			if strings.HasSuffix(test.path, ".css") && code == 200 && string(body) != string(css) {
+				t.Errorf("Body differs from ui/static/css/main.css")
+			}
+		})
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+hashed, nil)
+	req.Header.Set("If-None-Match", `"`+strings.Split(hashed, ".")[1]+`"`)
+	resp, err := srv.Client().Do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Wait %d for matched ETag, get %d", http.StatusNotModified, resp.StatusCode)
+	}
+}
+
+func TestHashedURLsInPage(t *testing.T) {
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{}, &mock.UsersStore{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	_, _, body := get(srv.URL+"/", t, srv)
+
+	for _, name := range []string{"css/main.css", "img/favicon.ico", "js/main.js"} {
+		if !strings.Contains(string(body), s.static.url(name)) {
+			t.Errorf("%s not found in page", s.static.url(name))
+		}
+	}
+}
+
+func TestDevModeStaticURL(t *testing.T) {
+	sf, err := newStaticFiles(fstest.MapFS{"static/css/main.css": {Data: []byte(`body {}`)}}, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if url := sf.url("css/main.css"); url != "/static/css/main.css" {
+		t.Errorf("URL isn't hashed in DEV_MODE, get %s", url)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/blob"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+//sweeper periodically removes snippets expired longer than grace period with content of their attachments
+type sweeper struct {
+	store       models.SnippetRepository
+	attachments models.AttachmentRepository
+	blobs       blob.BlobStore
+	log         *logrus.Logger
+	metrics     *metrics
+	interval    time.Duration
+	batchSize   int
+	grace       time.Duration
+}
+
+//sweep delete expired snippets by batches, return count of removed rows
+func (sw *sweeper) sweep(ctx context.Context) (int64, error) {
+	var total int64
+	before := time.Now().UTC().Add(-sw.grace)
+
+	for {
+		//attachments rows are removed by cascade, so keys of blobs are read before
+		attachments, err := sw.attachments.ListExpired(ctx, before, sw.batchSize)
+
+		if err != nil {
+			return total, err
+		}
+
+		removed, err := sw.store.PurgeExpired(ctx, before, sw.batchSize)
+		total += removed
+		sw.metrics.purgeRemoved.Add(float64(removed))
+
+		if err != nil {
+			return total, err
+		}
+
+		for _, a := range attachments {
+			if err := sw.blobs.Delete(ctx, a.Key); err != nil {
+				sw.log.Errorf("Error while delete blob %s of attachment %d: %v", a.Key, a.ID, err)
+			}
+		}
+
+		if removed < int64(sw.batchSize) {
+			return total, nil
+		}
+	}
+}
+
+//run sweep every interval until ctx is done
+func (sw *sweeper) run(ctx context.Context) {
+	runPeriodically(ctx, sw.interval, func() {
+		sw.metrics.purgeRuns.Inc()
+		removed, err := sw.sweep(ctx)
+		if err != nil {
+			sw.metrics.purgeErrors.Inc()
+			sw.log.Errorf("Error while purge expired snippets: %v", err)
+		}
+		if removed != 0 {
+			sw.log.Infof("Purged %d expired snippets", removed)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	blobmock "githib.com/VladimirStepanov/snippetbox/pkg/blob/mock"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/sirupsen/logrus"
+)
+
+func getExpiredSnippets(count int, expires time.Time) []*models.Snippet {
+	ss := getTestSnippetData(1, count, true, 1)
+	for _, val := range ss {
+		val.Expires = expires
+	}
+
+	return ss
+}
+
+func TestSweep(t *testing.T) {
+	tests := map[string]struct {
+		Snippets    []*models.Snippet
+		BatchSize   int
+		Grace       time.Duration
+		WantRemoved int64
+		WantLeft    int
+	}{
+		"Nothing to remove": {
+			Snippets:    getTestSnippetData(1, 3, true, 1),
+			BatchSize:   2,
+			WantRemoved: 0,
+			WantLeft:    3,
+		},
+		"Remove by several batches": {
+			Snippets:    append(getExpiredSnippets(5, time.Now().Add(-time.Hour)), getTestSnippetData(6, 2, true, 1)...),
+			BatchSize:   2,
+			WantRemoved: 5,
+			WantLeft:    2,
+		},
+		"Keep snippets in grace period": {
+			Snippets:    append(getExpiredSnippets(3, time.Now().Add(-time.Hour)), getExpiredSnippets(2, time.Now().Add(-3*time.Hour))...),
+			BatchSize:   10,
+			Grace:       2 * time.Hour,
+			WantRemoved: 2,
+			WantLeft:    3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &mock.SnippetStore{DB: test.Snippets}
+			sw := &sweeper{
+				store:       store,
+				attachments: &mock.AttachmentStore{Snippets: store},
+				blobs:       &blobmock.BlobStore{},
+				log:         logrus.New(),
+				metrics:     newMetrics(),
+				batchSize:   test.BatchSize,
+				grace:       test.Grace,
+			}
+
+			removed, err := sw.sweep(context.Background())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if removed != test.WantRemoved {
+				t.Fatalf("Want removed: %d, Get: %d", test.WantRemoved, removed)
+			}
+
+			if len(store.DB) != test.WantLeft {
+				t.Fatalf("Want left: %d, Get: %d", test.WantLeft, len(store.DB))
+			}
+		})
+	}
+}
+
+func TestSweepRemovesBlobs(t *testing.T) {
+	ss := append(getExpiredSnippets(3, time.Now().Add(-time.Hour)), getTestSnippetData(4, 1, true, 1)...)
+	store := &mock.SnippetStore{DB: ss}
+	blobs := &blobmock.BlobStore{Blobs: map[string][]byte{}}
+	attachments := &mock.AttachmentStore{Snippets: store}
+
+	for _, snippet := range ss {
+		key := fmt.Sprintf("key%d", snippet.ID)
+		blobs.Blobs[key] = []byte("content")
+		attachments.Insert(context.Background(), &models.Attachment{SnippetID: snippet.ID, Key: key})
+	}
+
+	sw := &sweeper{store: store, attachments: attachments, blobs: blobs, log: logrus.New(), metrics: newMetrics(), batchSize: 2}
+
+	if _, err := sw.sweep(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]byte{fmt.Sprintf("key%d", ss[3].ID): []byte("content")}
+
+	if !reflect.DeepEqual(blobs.Blobs, want) {
+		t.Errorf("Want blobs: %v, Get: %v", want, blobs.Blobs)
+	}
+}
+
+func TestPurgeCommand(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	config := &Config{log: logger, purge: purgeConfig{batchSize: 10}, attachments: attachmentConfig{store: &blobmock.BlobStore{}}}
+
+	tests := map[string]struct {
+		Args       []string
+		WantOutput string
+		WantLeft   int
+		WantError  bool
+	}{
+		"Dry run": {
+			Args:       []string{"--dry-run"},
+			WantOutput: "3 expired snippets would be removed",
+			WantLeft:   5,
+		},
+		"Purge": {
+			Args:       []string{},
+			WantOutput: "3 expired snippets removed",
+			WantLeft:   2,
+		},
+		"Purge with grace": {
+			Args:       []string{"--grace", "2h"},
+			WantOutput: "1 expired snippets removed",
+			WantLeft:   4,
+		},
+		"Bad batch size": {
+			Args:      []string{"--batch-size", "0"},
+			WantLeft:  5,
+			WantError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ss := append(getExpiredSnippets(2, time.Now().Add(-time.Hour)), getExpiredSnippets(1, time.Now().Add(-3*time.Hour))...)
+			store := &mock.SnippetStore{DB: append(ss, getTestSnippetData(4, 2, true, 1)...)}
+			out := &bytes.Buffer{}
+
+			err := purgeCommand(context.Background(), test.Args, out, config, store, &mock.AttachmentStore{Snippets: store})
+
+			if (err != nil) != test.WantError {
+				t.Fatalf("Want error: %v, Get: %v", test.WantError, err)
+			}
+
+			if !strings.Contains(out.String(), test.WantOutput) {
+				t.Fatalf("Want output: %s, Get: %s", test.WantOutput, out.String())
+			}
+
+			if len(store.DB) != test.WantLeft {
+				t.Fatalf("Want left: %d, Get: %d", test.WantLeft, len(store.DB))
+			}
+		})
+	}
+}
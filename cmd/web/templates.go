@@ -4,8 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"net/http"
-	"path/filepath"
+	"net/url"
 	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
@@ -17,21 +18,87 @@ type snippetForm struct {
 	Content string
 	Expire  string
 	Type    string
+	Format  string
+	Remind  bool
+	Files   []*fileForm
 }
 
+type auditForm struct {
+	Actor  string
+	Action string
+	From   string
+	To     string
+}
+
+//listForm filter and order of snippets list
+type listForm struct {
+	Sort       string
+	Order      string
+	Visibility string
+	Expiring   bool
+	From       string
+	To         string
+}
+
+const dateLayout = "2006-01-02"
+
 type templateData struct {
-	Snippets    []*models.Snippet
-	Snippet     *models.Snippet
-	User        *models.User
-	FormUser    *models.User
-	FormSnippet *snippetForm
-	Errors      validation.Errors
-	Flashes     []interface{}
-	CSRFField   template.HTML
-	IsEdit      bool
-	FormAction  string //form action for create and edit
-	Title       string
-	Year        int
+	Snippets     []*models.Snippet
+	Snippet      *models.Snippet
+	Parent       *models.Snippet // parent of forked snippet, nil if current user can't see it
+	Attachments  []*models.Attachment
+	User         *models.User
+	FormUser     *models.User
+	FormSnippet  *snippetForm
+	FormAudit    *auditForm
+	AuditEvents  []*models.AuditEvent
+	AuditActions []string
+	Presets      []expirePreset
+	Errors       validation.Errors
+	Flashes      []interface{}
+	CSRFField    template.HTML
+	IsEdit       bool
+	FormAction   string //form action for create and edit
+	Title        string
+	Year         int
+	CSPNonce     string
+	Error        *errorInfo
+	Page         *pageNav
+	FormList     *listForm
+	SortFields   []string
+	Visibilities []string // visibility filter is shown only in lists of own snippets
+}
+
+//snippetsPerPage size of snippets lists
+const snippetsPerPage = 10
+
+//pageNav links to neighbour pages of snippets list
+type pageNav struct {
+	Total   int64
+	NextURL string
+	PrevURL string
+}
+
+//pageURL return URL of list page with the same filter and order as current
+func pageURL(current *url.URL, key string, c *models.Cursor) string {
+	if c == nil {
+		return ""
+	}
+
+	query := current.Query()
+	query.Del("after")
+	query.Del("before")
+	query.Set(key, c.String())
+
+	return current.Path + "?" + query.Encode()
+}
+
+func newPageNav(current *url.URL, page *models.SnippetPage, sort string) *pageNav {
+	return &pageNav{
+		Total:   page.Total,
+		NextURL: pageURL(current, "after", page.NextCursor(sort)),
+		PrevURL: pageURL(current, "before", page.PrevCursor(sort)),
+	}
 }
 
 func getError(errMap validation.Errors, key string) string {
@@ -42,6 +109,39 @@ func getError(errMap validation.Errors, key string) string {
 	return ""
 }
 
+func humanDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("02 Jan 2006 at 15:04")
+}
+
+//humanSize format size in bytes with binary unit, e.g. 1.5 KB
+func humanSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	unit := ""
+
+	for _, unit = range []string{"KB", "MB", "GB"} {
+		value /= 1024
+		if value < 1024 {
+			break
+		}
+	}
+
+	return fmt.Sprintf("%.1f %s", value, unit)
+}
+
+func humanExpires(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return humanDateTime(t)
+}
+
 func humanDate(t time.Time) string {
 	if t.IsZero() {
 		return ""
@@ -55,15 +155,23 @@ func (s *Server) addDefaultData(t *templateData) *templateData {
 	}
 
 	t.Year = time.Now().Year()
+	t.Presets = expirePresets
 	return t
 }
 
 func (s *Server) render(w http.ResponseWriter, r *http.Request, templateName string, td *templateData) {
 	var err error
 	key := fmt.Sprintf("%s.page.html", templateName)
-	val, ok := s.templateCache[key]
+	cache, err := s.getTemplates()
+
+	if err != nil {
+		s.templateError(w, r, err)
+		return
+	}
+
+	val, ok := cache[key]
 	if !ok {
-		s.serverError(w, fmt.Errorf("Template  %s not found", key))
+		s.serverError(w, r, fmt.Errorf("Template  %s not found", key))
 		return
 	}
 
@@ -71,9 +179,10 @@ func (s *Server) render(w http.ResponseWriter, r *http.Request, templateName str
 
 	td.Flashes, err = s.getFlashes(w, r)
 	td.User = getAuthUserFromRequest(r)
+	td.CSPNonce = getCSPNonce(r)
 
 	if err != nil {
-		s.serverError(w, err)
+		s.serverError(w, r, err)
 		return
 	}
 
@@ -81,31 +190,48 @@ func (s *Server) render(w http.ResponseWriter, r *http.Request, templateName str
 	err = val.ExecuteTemplate(buf, key, td)
 
 	if err != nil {
-		s.serverError(w, err)
+		s.templateError(w, r, err)
 		return
 	}
 
 	buf.WriteTo(w)
 }
 
-func newTemplateCache(dir string) (map[string]*template.Template, error) {
+//loadTemplates parse templates from html directory of ui files
+func (s *Server) loadTemplates() (map[string]*template.Template, error) {
+	html, err := fs.Sub(s.ui, "html")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newTemplateCache(html, s.static)
+}
+
+func newTemplateCache(fsys fs.FS, static *staticFiles) (map[string]*template.Template, error) {
 
 	funcMap := template.FuncMap{
-		"humanDate": humanDate,
-		"getError":  getError,
+		"humanDate":     humanDate,
+		"humanDateTime": humanDateTime,
+		"humanExpires":  humanExpires,
+		"getError":      getError,
+		"static":        static.url,
+		"markdown":      renderMarkdown,
+		"highlight":     highlight,
+		"humanSize":     humanSize,
+		"fileLanguages": func() []string { return fileLanguages },
 	}
 
 	res := map[string]*template.Template{}
 
-	files, err := filepath.Glob(filepath.Join(dir, "*.page.html"))
+	files, err := fs.Glob(fsys, "*.page.html")
 
 	if err != nil {
 		return nil, err
 	}
 
-	for _, f := range files {
-		name := filepath.Base(f)
-		tmpl, err := template.New(name).Funcs(funcMap).ParseFiles(f, filepath.Join(dir, "footer.partial.html"), filepath.Join(dir, "base.layout.html"))
+	for _, name := range files {
+		tmpl, err := template.New(name).Funcs(funcMap).ParseFS(fsys, name, "*.partial.html", "base.layout.html")
 
 		if err != nil {
 			return nil, err
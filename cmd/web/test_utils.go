@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"html"
 	"io/ioutil"
@@ -8,12 +10,15 @@ import (
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	blobmock "githib.com/VladimirStepanov/snippetbox/pkg/blob/mock"
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
 	"github.com/gorilla/sessions"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
@@ -26,7 +31,9 @@ type showSnippetsData struct {
 	WantCode int
 	WantSee  []*models.Snippet
 	WantHide []*models.Snippet
-	WantPage string
+	WantNext bool // link to older page
+	WantPrev bool // link to newer page
+	Query    string
 }
 
 type showSnippetData struct {
@@ -40,6 +47,7 @@ func getTestUserData() map[int64]*models.User {
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("12345678"), 14)
 	um[1] = &models.User{ID: 1, Firstname: "Ivan", Lastname: "Doe", Email: "vova@mail.com", HashedPassword: hashedPassword}
 	um[2] = &models.User{ID: 2, Firstname: "Conor", Lastname: "Ivanov", Email: "conor@mail.com", HashedPassword: hashedPassword}
+	um[3] = &models.User{ID: 3, Firstname: "Admin", Lastname: "Root", Email: "admin@mail.com", HashedPassword: hashedPassword, IsAdmin: true}
 
 	return um
 }
@@ -52,6 +60,7 @@ func getTestSnippetData(startID, count int, isPub bool, oID int64) []*models.Sni
 			Title:    fmt.Sprintf("%dtitle%d", i, i),
 			Content:  fmt.Sprintf("%content%d", i, i),
 			Created:  time.Now(),
+			Updated:  time.Now(),
 			Expires:  time.Now().Add(time.Hour),
 			OwnerID:  oID,
 			IsPublic: isPub,
@@ -78,19 +87,37 @@ func NewHttptestServer(t *testing.T, routes http.Handler) *httptest.Server {
 	return srv
 }
 
+//fakeDB is database stub for probes and debug endpoint
+type fakeDB struct {
+	err   error
+	stats sql.DBStats
+}
+
+func (db *fakeDB) PingContext(ctx context.Context) error {
+	return db.err
+}
+
+func (db *fakeDB) Stats() sql.DBStats {
+	return db.stats
+}
+
 //NewTestServer return *Server test object
 func NewTestServer(sr models.SnippetRepository, ur models.UserRepository) *Server {
 	logger := logrus.New()
 	logger.SetOutput(ioutil.Discard)
 	testConfig := &Config{addr: ":8080", log: logger, sessionStore: sessions.NewCookieStore([]byte("123")), csrfKey: "123"}
-	return New(testConfig, ur, sr)
+	testConfig.ui = uiFiles(false)
+	testConfig.static, _ = newStaticFiles(testConfig.ui, true)
+	types, _ := findSetting("ATTACHMENT_TYPES")
+	testConfig.attachments = attachmentConfig{maxSize: 1 << 20, types: strings.Split(types.def, ","), store: &blobmock.BlobStore{}}
+	return New(testConfig, newMetrics(), &fakeDB{}, ur, sr, &mock.AuditStore{}, &mock.AttachmentStore{})
 }
 
 //NewTestServerWithUI return *Server object with templateCache
 func NewTestServerWithUI(dir string, sr models.SnippetRepository, ur models.UserRepository) (*Server, error) {
 	s := NewTestServer(sr, ur)
 	var err error
-	s.templateCache, err = newTemplateCache(dir)
+	s.templateCache, err = newTemplateCache(os.DirFS(dir), s.static)
 
 	if err != nil {
 		return nil, err
@@ -184,7 +211,7 @@ func login(t *testing.T, srv *httptest.Server, email, password string) {
 func testSnippetsPage(srv *httptest.Server, t *testing.T, tests map[string]showSnippetsData, path string) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			code, _, data := get(fmt.Sprintf("%s%s?page=%s", srv.URL, path, test.WantPage), t, srv)
+			code, _, data := get(fmt.Sprintf("%s%s?%s", srv.URL, path, test.Query), t, srv)
 
 			if test.WantCode != code {
 				t.Fatalf("Want code: %d, Get code: %d", test.WantCode, code)
@@ -192,15 +219,21 @@ func testSnippetsPage(srv *httptest.Server, t *testing.T, tests map[string]showS
 
 			if test.WantCode == http.StatusOK {
 				for _, val := range test.WantSee {
-					if !strings.Contains(string(data), val.Title) {
+					if !strings.Contains(string(data), ">"+val.Title+"</a>") {
 						t.Fatalf("Want see: %v", val)
 					}
 				}
 				for _, val := range test.WantHide {
-					if strings.Contains(string(data), val.Title) {
+					if strings.Contains(string(data), ">"+val.Title+"</a>") {
 						t.Fatalf("Want hide:  %v", val)
 					}
 				}
+				if strings.Contains(string(data), path+"?after=") != test.WantNext {
+					t.Fatalf("Want link to older page: %v", test.WantNext)
+				}
+				if strings.Contains(string(data), path+"?before=") != test.WantPrev {
+					t.Fatalf("Want link to newer page: %v", test.WantPrev)
+				}
 			}
 		})
 	}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//certCheckInterval is how often TLS certificate files are checked for changes
+const certCheckInterval = 10 * time.Second
+
+//certReloader serve TLS certificate and reload it when cert or key file is changed
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      *logrus.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string, log *logrus.Logger) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile, log: log}
+
+	if _, err := cr.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+//lastModified return latest modification time of cert and key files
+func (cr *certReloader) lastModified() (time.Time, error) {
+	var res time.Time
+
+	for _, path := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return res, err
+		}
+		if info.ModTime().After(res) {
+			res = info.ModTime()
+		}
+	}
+
+	return res, nil
+}
+
+//reloadIfChanged load key pair if files are changed since last load, return true if certificate is replaced
+func (cr *certReloader) reloadIfChanged() (bool, error) {
+	modTime, err := cr.lastModified()
+	if err != nil {
+		return false, err
+	}
+
+	cr.mu.RLock()
+	changed := !modTime.Equal(cr.modTime)
+	cr.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.mu.Unlock()
+
+	return true, nil
+}
+
+//GetCertificate is tls.Config.GetCertificate callback
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+//watch check files every interval until ctx is done, broken files keep previous certificate
+func (cr *certReloader) watch(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, interval, func() {
+		reloaded, err := cr.reloadIfChanged()
+		if err != nil {
+			cr.log.Errorf("Error while reload TLS certificate, keep previous one: %v", err)
+		} else if reloaded {
+			cr.log.Infof("TLS certificate reloaded from %s", cr.certFile)
+		}
+	})
+}
+
+func (cr *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+}
+
+//parseTrustedProxies parse comma separated IPs and CIDRs
+func parseTrustedProxies(value string) ([]*net.IPNet, error) {
+	res := []*net.IPNet{}
+
+	for _, val := range strings.Split(value, ",") {
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+
+		if !strings.Contains(val, "/") {
+			if ip := net.ParseIP(val); ip != nil && ip.To4() != nil {
+				val += "/32"
+			} else {
+				val += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(val)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %v", err)
+		}
+
+		res = append(res, ipNet)
+	}
+
+	return res, nil
+}
+
+//isTrustedProxy report if address is one of trusted proxies
+func (s *Server) isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range s.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//fromTrustedProxy report if request came from one of trusted proxies
+func (s *Server) fromTrustedProxy(r *http.Request) bool {
+	return s.isTrustedProxy(clientIP(r))
+}
+
+//realIP return address of client, X-Forwarded-For is read from the right while hops are trusted proxies,
+//so client can't forge address by sending the header itself
+func (s *Server) realIP(r *http.Request) string {
+	ip := clientIP(r)
+	if !s.isTrustedProxy(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		ip = hop
+		if !s.isTrustedProxy(hop) {
+			break
+		}
+	}
+
+	return ip
+}
+
+//isSecure report if client uses HTTPS: directly or through trusted proxy
+func (s *Server) isSecure(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	return s.fromTrustedProxy(r) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+//hsts add Strict-Transport-Security header to responses of secure requests,
+//subdomains are included only by config, otherwise HTTP-only subdomains would become unreachable
+func (s *Server) hsts(next http.Handler) http.Handler {
+	value := fmt.Sprintf("max-age=%d", int64(s.hstsMaxAge.Seconds()))
+	if s.hstsSubdomains {
+		value += "; includeSubDomains"
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if s.hstsMaxAge > 0 && s.isSecure(r) {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+			next.ServeHTTP(w, r)
+		})
+}
+
+//secureSwitch pass secure requests to secure handler and others to plain one
+func (s *Server) secureSwitch(secure, plain http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if s.isSecure(r) {
+				secure.ServeHTTP(w, r)
+				return
+			}
+			plain.ServeHTTP(w, r)
+		})
+}
+
+//redirectToHTTPS is handler of plain HTTP listener which redirects to HTTPS address of server
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+
+	if _, port, err := net.SplitHostPort(s.addr); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("https://%s%s", host, r.URL.RequestURI()), http.StatusPermanentRedirect)
+}
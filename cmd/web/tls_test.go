@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/sirupsen/logrus"
+)
+
+//writeTestCert write self-signed certificate for commonName and its key to dir
+func writeTestCert(t *testing.T, dir, commonName string, modTime time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	files := map[string][]byte{
+		certFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyFile:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+
+	for path, data := range files {
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return certFile, keyFile
+}
+
+func certCommonName(t *testing.T, cr *certReloader) string {
+	cert, err := cr.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed.Subject.CommonName
+}
+
+func TestCertReload(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute)
+	certFile, keyFile := writeTestCert(t, dir, "first.example.com", start)
+
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	cr, err := newCertReloader(certFile, keyFile, log)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := certCommonName(t, cr); name != "first.example.com" {
+		t.Fatalf("Want: first.example.com, Get: %s", name)
+	}
+
+	if reloaded, err := cr.reloadIfChanged(); reloaded || err != nil {
+		t.Fatalf("Unchanged files are reloaded: %v", err)
+	}
+
+	writeTestCert(t, dir, "second.example.com", start.Add(time.Second))
+
+	if reloaded, err := cr.reloadIfChanged(); !reloaded || err != nil {
+		t.Fatalf("Changed files are not reloaded: %v", err)
+	}
+
+	if name := certCommonName(t, cr); name != "second.example.com" {
+		t.Fatalf("Want: second.example.com, Get: %s", name)
+	}
+
+	if err := ioutil.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cr.reloadIfChanged(); err == nil {
+		t.Fatal("Want error for broken certificate")
+	}
+
+	if name := certCommonName(t, cr); name != "second.example.com" {
+		t.Fatalf("Broken certificate replaced previous one: %s", name)
+	}
+
+	if _, err := newCertReloader(filepath.Join(dir, "none.pem"), keyFile, log); err == nil {
+		t.Fatal("Want error for missing certificate")
+	}
+}
+
+func findCookie(rs *http.Response, name string) *http.Cookie {
+	for _, cookie := range rs.Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+
+	return nil
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir(), "localhost", time.Now())
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{}, &mock.UsersStore{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.hstsMaxAge = time.Hour
+	s.shutdownTimeout = time.Second
+
+	if s.certs, err = newCertReloader(certFile, keyFile, s.log); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.serve(ctx, ln, s.routes(), s.certs.tlsConfig())
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	rs, err := client.Get(fmt.Sprintf("https://%s/user/login", ln.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	if rs.StatusCode != http.StatusOK || rs.TLS == nil {
+		t.Fatalf("Want TLS response %d, Get: %d", http.StatusOK, rs.StatusCode)
+	}
+
+	if hsts := rs.Header.Get("Strict-Transport-Security"); hsts != "max-age=3600" {
+		t.Fatalf("Wrong HSTS header: %q", hsts)
+	}
+
+	if cookie := findCookie(rs, "_gorilla_csrf"); cookie == nil || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("CSRF cookie is not secure: %v", cookie)
+	}
+}
+
+func TestTrustedProxy(t *testing.T) {
+	tests := map[string]struct {
+		proxies    string
+		proto      string
+		wantSecure bool
+	}{
+		"Plain HTTP":         {"", "", false},
+		"Untrusted proxy":    {"10.0.0.0/8", "https", false},
+		"Trusted proxy":      {"10.0.0.1, 127.0.0.1", "https", true},
+		"Trusted proxy CIDR": {"127.0.0.0/8", "HTTPS", true},
+		"Trusted HTTP":       {"127.0.0.1", "http", false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{}, &mock.UsersStore{})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s.hstsMaxAge = time.Hour
+
+			if s.trustedProxies, err = parseTrustedProxies(test.proxies); err != nil {
+				t.Fatal(err)
+			}
+
+			srv := NewHttptestServer(t, s.routes())
+			defer srv.Close()
+
+			req, err := http.NewRequest("GET", srv.URL+"/user/login", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-Proto", test.proto)
+
+			rs, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			if gotHSTS := rs.Header.Get("Strict-Transport-Security") != ""; gotHSTS != test.wantSecure {
+				t.Fatalf("Want HSTS: %t, Get: %t", test.wantSecure, gotHSTS)
+			}
+
+			if cookie := findCookie(rs, "_gorilla_csrf"); cookie == nil || cookie.Secure != test.wantSecure {
+				t.Fatalf("Want secure CSRF cookie: %t, Get: %v", test.wantSecure, cookie)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/user/login", nil)
+			r.RemoteAddr = "127.0.0.1:40000"
+			r.Header.Set("X-Forwarded-Proto", test.proto)
+
+			if err := s.addNewUserSession(w, r, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			if cookie := findCookie(w.Result(), "SID"); cookie == nil || cookie.Secure != test.wantSecure {
+				t.Fatalf("Want secure session cookie: %t, Get: %v", test.wantSecure, cookie)
+			}
+		})
+	}
+
+	if _, err := parseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Fatal("Want error for wrong CIDR")
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	tests := map[string]struct {
+		remoteAddr string
+		forwarded  []string
+		wantIP     string
+	}{
+		"Direct":             {"203.0.113.7:40000", nil, "203.0.113.7"},
+		"Untrusted peer":     {"203.0.113.7:40000", []string{"198.51.100.1"}, "203.0.113.7"},
+		"Trusted proxy":      {"10.0.0.1:40000", []string{"198.51.100.1"}, "198.51.100.1"},
+		"Forged by client":   {"10.0.0.1:40000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		"Chain of proxies":   {"10.0.0.1:40000", []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"}, "198.51.100.1"},
+		"Proxy without hops": {"10.0.0.1:40000", nil, "10.0.0.1"},
+		"Malformed hop":      {"10.0.0.1:40000", []string{"198.51.100.1, unknown"}, "10.0.0.1"},
+	}
+
+	s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+	s.trustedProxies, _ = parseTrustedProxies("10.0.0.0/8")
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/user/login", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, val := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", val)
+			}
+
+			if ip := s.realIP(r); ip != test.wantIP {
+				t.Fatalf("Want %s, Get: %s", test.wantIP, ip)
+			}
+		})
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := map[string]struct {
+		addr         string
+		host         string
+		wantLocation string
+	}{
+		"Custom port":  {":8443", "example.com:8080", "https://example.com:8443/snippet/1?page=2"},
+		"Default port": {"0.0.0.0:443", "example.com", "https://example.com/snippet/1?page=2"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+			s.addr = test.addr
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/snippet/1?page=2", nil)
+			r.Host = test.host
+
+			s.redirectToHTTPS(w, r)
+
+			if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != test.wantLocation {
+				t.Fatalf("Want: %s, Get: %d %s", test.wantLocation, w.Code, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir(), "localhost", time.Now())
+
+	tests := map[string]struct {
+		args    []string
+		wantErr string
+	}{
+		"Only cert":          {[]string{"--tls-cert-file", certFile}, "must be set together"},
+		"Redirect no TLS":    {[]string{"--tls-redirect-addr", ":80"}, "TLS_REDIRECT_ADDR requires"},
+		"Missing cert file":  {[]string{"--tls-cert-file", certFile + ".none", "--tls-key-file", keyFile}, "TLS_CERT_FILE"},
+		"Wrong proxies":      {[]string{"--trusted-proxies", "proxy.local"}, "TRUSTED_PROXIES"},
+		"Valid TLS settings": {[]string{"--tls-cert-file", certFile, "--tls-key-file", keyFile, "--tls-redirect-addr", ":80"}, ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := append([]string{"--log-level", "error"}, test.args...)
+			src, _, err := loadConfigSource(args, "not-exists.env", ioutil.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = NewConfig(src)
+
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("Want error: %s, Get: %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHSTSHeader(t *testing.T) {
+	tests := map[string]struct {
+		maxAge     time.Duration
+		subdomains bool
+		want       string
+	}{
+		"Default":    {time.Hour, false, "max-age=3600"},
+		"Subdomains": {time.Hour, true, "max-age=3600; includeSubDomains"},
+		"Disabled":   {0, true, ""},
+		"Day":        {24 * time.Hour, false, "max-age=86400"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewTestServer(&mock.SnippetStore{}, &mock.UsersStore{})
+			s.hstsMaxAge = test.maxAge
+			s.hstsSubdomains = test.subdomains
+
+			w := httptest.NewRecorder()
+			s.hsts(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, httptest.NewRequest("GET", "https://localhost/", nil))
+
+			if got := w.Header().Get("Strict-Transport-Security"); got != test.want {
+				t.Fatalf("Want: %q, Get: %q", test.want, got)
+			}
+		})
+	}
+}
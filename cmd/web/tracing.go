@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "githib.com/VladimirStepanov/snippetbox/cmd/web"
+
+var traceExporters = []string{"none", "stdout", "otlp"}
+
+//setupTracing set global tracer provider with exporter by name, returned func flush spans and stop provider
+func setupTracing(exporter string, out io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exp sdktrace.SpanExporter
+	var err error
+
+	switch exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(out))
+	case "otlp":
+		//endpoint and headers are taken from standard OTEL_EXPORTER_OTLP_* variables
+		exp, err = otlptracehttp.New(context.Background())
+	default:
+		err = fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("snippetbox")),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+//tracing start root span of request named by route, incoming trace context is continued
+func (s *Server) tracing(router *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			route := routeTemplate(router, r)
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := otel.Tracer(tracerName).Start(
+				ctx,
+				fmt.Sprintf("%s %s", r.Method, route),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", route),
+					attribute.String("url.path", r.URL.Path),
+					attribute.String("request_id", getRequestInfo(r).id),
+				),
+			)
+			defer span.End()
+
+			lwr := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(lwr, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", lwr.statusCode))
+
+			if userID := getRequestInfo(r).userID; userID != 0 {
+				span.SetAttributes(attribute.Int64("enduser.id", userID))
+			}
+
+			if lwr.statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(lwr.statusCode))
+			}
+		})
+}
+
+//traced run next inside of child span with name
+func (s *Server) traced(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := otel.Tracer(tracerName).Start(r.Context(), name)
+			defer span.End()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+}
+
+//handlerSpan is router middleware which start span of matched route handler
+func (s *Server) handlerSpan(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			route, _ := mux.CurrentRoute(r).GetPathTemplate()
+			s.traced(fmt.Sprintf("handler %s", route), next).ServeHTTP(w, r)
+		})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setTestTracer(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return recorder
+}
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	um := getTestUserData()
+	ss := getTestSnippetData(1, 1, true, 2)
+
+	s, err := NewTestServerWithUI("../../ui/html", &mock.SnippetStore{DB: ss, UsersMap: um}, &mock.UsersStore{DB: um})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewHttptestServer(t, s.routes())
+	defer srv.Close()
+
+	login(t, srv, "conor@mail.com", "12345678")
+
+	recorder := setTestTracer(t)
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/snippet/%d", srv.URL, ss[0].ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-00f067aa0ba902b7-01", traceID))
+
+	rs, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	route := "/snippet/{id:[0-9]+}"
+	root, ok := spans["GET "+route]
+
+	if !ok {
+		t.Fatalf("No root span in %v", spans)
+	}
+
+	if root.SpanContext().TraceID().String() != traceID {
+		t.Fatalf("Incoming trace is not continued, Get: %s", root.SpanContext().TraceID())
+	}
+
+	if spanAttr(root, "http.route").AsString() != route ||
+		spanAttr(root, "http.response.status_code").AsInt64() != http.StatusOK ||
+		spanAttr(root, "enduser.id").AsInt64() != 2 {
+		t.Fatalf("Wrong attributes of root span: %v", root.Attributes())
+	}
+
+	for _, name := range []string{"authUser", "handler " + route} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("No span %s in %v", name, spans)
+		}
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Fatalf("Span %s is not in request trace", name)
+		}
+	}
+}
+
+func TestSetupTracing(t *testing.T) {
+	prevProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prevProvider)
+
+	if _, err := setupTracing("jaeger", nil); err == nil {
+		t.Fatal("Want error for unknown exporter")
+	}
+
+	var out bytes.Buffer
+	shutdown, err := setupTracing("stdout", &out)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := otel.Tracer(tracerName).Start(context.Background(), "test-span")
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "test-span") || !strings.Contains(out.String(), "snippetbox") {
+		t.Fatalf("Span is not exported: %s", out.String())
+	}
+}
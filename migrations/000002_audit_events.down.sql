@@ -0,0 +1,2 @@
+drop table audit_events;
+alter table users drop column is_admin;
@@ -0,0 +1,15 @@
+alter table users add is_admin BOOLEAN not null default 0;
+
+create table audit_events (
+    id int primary key auto_increment,
+    actor_id int,
+    action varchar(50) not null,
+    target_type varchar(50) not null default '',
+    target_id int,
+    ip varchar(45) not null default '',
+    user_agent varchar(255) not null default '',
+    details varchar(500) not null default '',
+    create_date datetime not null,
+    INDEX (actor_id, create_date),
+    INDEX (action, create_date)
+);
@@ -0,0 +1,3 @@
+alter table snippets
+    modify create_date date not null,
+    modify expiration_date date not null;
@@ -0,0 +1,3 @@
+alter table snippets
+    modify create_date datetime not null,
+    modify expiration_date datetime not null;
@@ -0,0 +1,6 @@
+update snippets set expiration_date = '9999-12-31 00:00:00' where expiration_date is null;
+
+alter table snippets
+    modify expiration_date datetime not null,
+    drop column remind_expiry,
+    drop column reminder_sent;
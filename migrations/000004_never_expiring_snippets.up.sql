@@ -0,0 +1,4 @@
+alter table snippets
+    modify expiration_date datetime null,
+    add remind_expiry BOOLEAN not null default 0,
+    add reminder_sent BOOLEAN not null default 0;
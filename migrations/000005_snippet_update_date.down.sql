@@ -0,0 +1 @@
+alter table snippets drop column update_date;
\ No newline at end of file
@@ -0,0 +1,5 @@
+alter table snippets add update_date datetime null;
+
+update snippets set update_date = create_date;
+
+alter table snippets modify update_date datetime not null;
\ No newline at end of file
@@ -0,0 +1,3 @@
+drop index snippets_public_latest on snippets;
+
+drop index snippets_owner_latest on snippets;
\ No newline at end of file
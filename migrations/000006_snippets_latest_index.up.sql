@@ -0,0 +1,3 @@
+create index snippets_public_latest on snippets (is_public, create_date, id);
+
+create index snippets_owner_latest on snippets (owner_id, create_date, id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+alter table snippets
+    drop column unlisted,
+    drop column views;
\ No newline at end of file
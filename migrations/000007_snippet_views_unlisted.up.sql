@@ -0,0 +1,3 @@
+alter table snippets
+    add unlisted BOOLEAN not null default 0,
+    add views BIGINT not null default 0;
\ No newline at end of file
@@ -0,0 +1 @@
+alter table snippets drop column format;
\ No newline at end of file
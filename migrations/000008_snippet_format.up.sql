@@ -0,0 +1 @@
+alter table snippets add format varchar(16) not null default 'text';
\ No newline at end of file
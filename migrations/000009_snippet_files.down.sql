@@ -0,0 +1 @@
+drop table snippet_files;
\ No newline at end of file
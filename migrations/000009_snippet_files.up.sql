@@ -0,0 +1,10 @@
+create table snippet_files (
+    id int primary key auto_increment,
+    snippet_id int not null,
+    position int not null,
+    filename varchar(255) not null,
+    language varchar(32) not null default '',
+    content MEDIUMTEXT not null,
+    UNIQUE (snippet_id, filename),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE
+);
\ No newline at end of file
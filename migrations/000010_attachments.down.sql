@@ -0,0 +1 @@
+drop table attachments;
\ No newline at end of file
@@ -0,0 +1,12 @@
+create table attachments (
+    id int primary key auto_increment,
+    snippet_id int not null,
+    filename varchar(255) not null,
+    content_type varchar(100) not null,
+    size bigint not null,
+    blob_key varchar(255) not null,
+    create_date DATETIME not null,
+    UNIQUE (blob_key),
+    INDEX (snippet_id, id),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE
+);
\ No newline at end of file
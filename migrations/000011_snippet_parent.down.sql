@@ -0,0 +1,5 @@
+alter table snippets drop foreign key snippets_parent_fk;
+
+drop index snippets_parent_latest on snippets;
+
+alter table snippets drop column parent_id;
\ No newline at end of file
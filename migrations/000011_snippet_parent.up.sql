@@ -0,0 +1,5 @@
+alter table snippets add parent_id int null;
+
+create index snippets_parent_latest on snippets (parent_id, create_date, id);
+
+alter table snippets add constraint snippets_parent_fk foreign key (parent_id) references snippets (id) on delete set null;
\ No newline at end of file
@@ -0,0 +1 @@
+drop index audit_events_target on audit_events;
\ No newline at end of file
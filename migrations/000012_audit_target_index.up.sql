@@ -0,0 +1 @@
+create index audit_events_target on audit_events (target_type, target_id, create_date);
\ No newline at end of file
@@ -0,0 +1,3 @@
+alter table snippets
+    drop column reminder_attempts,
+    drop column reminder_retry_date;
\ No newline at end of file
@@ -0,0 +1,3 @@
+alter table snippets
+    add reminder_attempts int not null default 0,
+    add reminder_retry_date datetime;
\ No newline at end of file
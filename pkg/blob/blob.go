@@ -0,0 +1,28 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+var (
+	//ErrNotFound returned when there is no blob with key
+	ErrNotFound = errors.New("blob: not found")
+	//ErrBadKey returned for key which isn't clean slash separated relative path
+	ErrBadKey = errors.New("blob: bad key")
+)
+
+//BlobStore interface for storing content of attachments, keys are slash separated relative paths
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+//ValidKey report whether key can be used in any BlobStore
+func ValidKey(key string) bool {
+	return key != "." && fs.ValidPath(key) && !strings.Contains(key, `\`)
+}
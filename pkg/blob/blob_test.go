@@ -0,0 +1,74 @@
+package blob
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := map[string]struct {
+		Key  string
+		Want bool
+	}{
+		"Nested":         {"snippets/1/abc", true},
+		"Empty":          {"", false},
+		"Dot":            {".", false},
+		"Parent":         {"snippets/../../etc/passwd", false},
+		"Absolute":       {"/etc/passwd", false},
+		"Backslash":      {`snippets\..\a`, false},
+		"Trailing slash": {"snippets/1/", false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := ValidKey(test.Key); got != test.Want {
+				t.Fatalf("Want %v, Get: %v", test.Want, got)
+			}
+		})
+	}
+}
+
+//testBlobStore check behaviour which is common for all BlobStore implementations
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	key := "snippets/1/log"
+	content := "line 1\nline 2\n"
+
+	if _, err := store.Get(ctx, key); err != ErrNotFound {
+		t.Fatalf("Want ErrNotFound before Put, Get: %v", err)
+	}
+
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+
+	if err != nil || string(data) != content {
+		t.Fatalf("Want %q, Get: %q %v", content, data, err)
+	}
+
+	if err := store.Put(ctx, "../outside", strings.NewReader(content), int64(len(content)), "text/plain"); err != ErrBadKey {
+		t.Fatalf("Want ErrBadKey, Get: %v", err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get(ctx, key); err != ErrNotFound {
+		t.Fatalf("Want ErrNotFound after Delete, Get: %v", err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Want no error for Delete of missing blob, Get: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//LocalStore keep blobs as files in Dir, key is path of file relative to Dir
+type LocalStore struct {
+	Dir string
+}
+
+func (ls *LocalStore) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrBadKey
+	}
+	return filepath.Join(ls.Dir, filepath.FromSlash(key)), nil
+}
+
+//Put write blob to temporary file and rename it, so readers never see partial content
+func (ls *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	name, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+
+	if err == nil && size >= 0 && n != size {
+		err = fmt.Errorf("blob: written %d bytes, want %d", n, size)
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+//Get open file of blob
+func (ls *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return f, err
+}
+
+//Delete remove file of blob, missing blob isn't error
+func (ls *LocalStore) Delete(ctx context.Context, key string) error {
+	name, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
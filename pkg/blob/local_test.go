@@ -0,0 +1,30 @@
+package blob
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	testBlobStore(t, &LocalStore{Dir: t.TempDir()})
+}
+
+func TestLocalStoreSizeMismatch(t *testing.T) {
+	store := &LocalStore{Dir: t.TempDir()}
+
+	if err := store.Put(context.Background(), "a/b", strings.NewReader("short"), 100, ""); err == nil {
+		t.Fatal("Want error for size mismatch")
+	}
+
+	if _, err := os.Stat(filepath.Join(store.Dir, "a", "b")); !os.IsNotExist(err) {
+		t.Fatalf("Want no file after failed Put, Get: %v", err)
+	}
+
+	files, _ := os.ReadDir(filepath.Join(store.Dir, "a"))
+	if len(files) != 0 {
+		t.Fatalf("Want no temporary files, Get: %v", files)
+	}
+}
@@ -0,0 +1,56 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/blob"
+)
+
+//ErrPut returned by BlobStore when FailPut is set
+var ErrPut = errors.New("mock: Put failed")
+
+//BlobStore mock keeps blobs in map
+type BlobStore struct {
+	Blobs   map[string][]byte
+	FailPut bool
+}
+
+//Put save blob to map
+func (bs *BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if bs.FailPut {
+		return ErrPut
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if bs.Blobs == nil {
+		bs.Blobs = map[string][]byte{}
+	}
+
+	bs.Blobs[key] = data
+
+	return nil
+}
+
+//Get return reader of blob from map
+func (bs *BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := bs.Blobs[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+//Delete remove blob from map
+func (bs *BlobStore) Delete(ctx context.Context, key string) error {
+	delete(bs.Blobs, key)
+	return nil
+}
@@ -0,0 +1,35 @@
+package blob
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+)
+
+//newFakeS3Store return S3Store working with in-memory S3 compatible server
+func newFakeS3Store(t *testing.T, bucket string) *S3Store {
+	srv := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	t.Cleanup(srv.Close)
+
+	store, err := NewS3Store(strings.TrimPrefix(srv.URL, "http://"), "us-east-1", "key", "secret", bucket, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func TestS3Store(t *testing.T) {
+	store := newFakeS3Store(t, "attachments")
+
+	if err := store.Client.MakeBucket(context.Background(), store.Bucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	testBlobStore(t, store)
+}
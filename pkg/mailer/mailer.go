@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+//Mailer interface for sending emails
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+//SMTPMailer send emails through SMTP server
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+//Send plain text email
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	msg := strings.Join([]string{
+		fmt.Sprintf("From: %s", m.From),
+		fmt.Sprintf("To: %s", to),
+		fmt.Sprintf("Subject: %s", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, []byte(msg))
+}
+
+//LogMailer write emails to log, useful for development
+type LogMailer struct {
+	Log *logrus.Logger
+}
+
+//Send write email to log
+func (m *LogMailer) Send(to, subject, body string) error {
+	m.Log.Infof("Email to %s with subject %q:\n%s", to, subject, body)
+	return nil
+}
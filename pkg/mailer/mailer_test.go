@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+//serveSMTP accept one connection and reply like SMTP server, return received data
+func serveSMTP(t *testing.T, l net.Listener) <-chan string {
+	res := make(chan string, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			res <- ""
+			return
+		}
+		defer conn.Close()
+
+		var data strings.Builder
+		rd := bufio.NewReader(conn)
+		write := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		write("220 localhost ESMTP")
+		inData := false
+
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				break
+			}
+
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					write("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				write("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				write("354 Start mail input")
+			case cmd == "QUIT":
+				write("221 Bye")
+				res <- data.String()
+				return
+			default:
+				write("250 OK")
+			}
+		}
+		res <- data.String()
+	}()
+
+	return res
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := serveSMTP(t, l)
+
+	m := &SMTPMailer{Addr: l.Addr().String(), From: "noreply@snippetbox"}
+
+	if err = m.Send("user@mail.com", "Test subject", "Hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	data := <-received
+
+	for _, want := range []string{"To: user@mail.com", "Subject: Test subject", "Hello"} {
+		if !strings.Contains(data, want) {
+			t.Fatalf("Want %q in message: %s", want, data)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package mock
+
+import "errors"
+
+//ErrSend returned by Mailer when FailSend is set
+var ErrSend = errors.New("mock: Send failed")
+
+//Message sent by mock Mailer
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+//Mailer mock saves sent messages
+type Mailer struct {
+	Sent     []*Message
+	FailSend bool
+}
+
+//Send save message
+func (m *Mailer) Send(to, subject, body string) error {
+	if m.FailSend {
+		return ErrSend
+	}
+
+	m.Sent = append(m.Sent, &Message{To: to, Subject: subject, Body: body})
+
+	return nil
+}
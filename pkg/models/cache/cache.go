@@ -0,0 +1,26 @@
+//Package cache contains read-through cache for repositories
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+//Backend stores encoded values by key
+type Backend interface {
+	//Get return value and true if key exists and isn't expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	//Set value of key, zero ttl means value doesn't expire
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+//Observer is called after every cache lookup with store operation and result: hit, miss or error
+type Observer func(op, result string)
+
+//Lookup results
+const (
+	Hit   = "hit"
+	Miss  = "miss"
+	Error = "error"
+)
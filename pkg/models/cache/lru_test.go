@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), 0)
+
+	tests := map[string]struct {
+		key       string
+		after     time.Duration
+		wantValue string
+		wantOK    bool
+	}{
+		"Recently used is kept": {"a", 0, "1", true},
+		"Least used is evicted": {"b", 0, "", false},
+		"New entry":             {"c", 0, "3", true},
+		"Without TTL":           {"a", time.Hour, "1", true},
+		"Unknown":               {"d", 0, "", false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c.now = func() time.Time { return now.Add(test.after) }
+
+			val, ok, err := c.Get(ctx, test.key)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok != test.wantOK || string(val) != test.wantValue {
+				t.Errorf("Want: %q %v, Get: %q %v", test.wantValue, test.wantOK, val, ok)
+			}
+		})
+	}
+}
+
+func TestLRUExpiration(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	c := NewLRU(10)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Fatal("Entry is not found before TTL")
+	}
+
+	c.now = func() time.Time { return now.Add(time.Minute) }
+
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Fatal("Entry is found after TTL")
+	}
+
+	if c.Len() != 0 {
+		t.Fatalf("Expired entry is not removed, len %d", c.Len())
+	}
+}
+
+func TestLRUSize(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(100)
+
+	for i := 0; i < 1000; i++ {
+		c.Set(ctx, fmt.Sprint(i), []byte("v"), 0)
+	}
+
+	c.Delete(ctx, "999", "unknown")
+
+	if c.Len() != 99 {
+		t.Fatalf("Want: 99, Get: %d", c.Len())
+	}
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//Redis is Backend shared by all instances of application
+type Redis struct {
+	Client *redis.Client
+	//Prefix is added to every key, so one Redis database can be shared with other applications
+	Prefix string
+}
+
+//Get value of key
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, err := r.Client.Get(ctx, r.Prefix+key).Bytes()
+
+	if err == redis.Nil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+//Set value of key with ttl
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.Client.Set(ctx, r.Prefix+key, value, ttl).Err()
+}
+
+//Delete keys
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.Prefix + key
+	}
+
+	return r.Client.Del(ctx, prefixed...).Err()
+}
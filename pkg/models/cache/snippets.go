@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//listVersionKey hold version of cached snippet lists, lists of old version are never read again
+const listVersionKey = "snippets:lists:version"
+
+//SnippetStore is read-through cache of Get and List in front of Store.
+//Cached snippet is removed on Update, Delete (with its forks) and SetExpiration,
+//all lists are dropped on these changes, other instances sharing Backend see changes immediately,
+//value read concurrently with change may live until TTL, so do view counts.
+type SnippetStore struct {
+	Store    models.SnippetRepository
+	Backend  Backend
+	TTL      time.Duration
+	Observer Observer
+}
+
+func snippetKey(id int64) string {
+	return fmt.Sprintf("snippets:%d", id)
+}
+
+func isExpired(snippet *models.Snippet, now time.Time) bool {
+	return !snippet.NeverExpires() && !snippet.Expires.After(now)
+}
+
+func (s *SnippetStore) observe(op, result string) {
+	if s.Observer != nil {
+		s.Observer(op, result)
+	}
+}
+
+//load decode cached value of key to dest, false is returned on miss or backend error
+func (s *SnippetStore) load(ctx context.Context, op, key string, dest interface{}) bool {
+	data, ok, err := s.Backend.Get(ctx, key)
+
+	if err == nil && ok {
+		err = json.Unmarshal(data, dest)
+	}
+
+	switch {
+	case err != nil:
+		s.observe(op, Error)
+	case !ok:
+		s.observe(op, Miss)
+	default:
+		s.observe(op, Hit)
+	}
+
+	return err == nil && ok
+}
+
+//store save value, failure only means next lookup is a miss
+func (s *SnippetStore) store(ctx context.Context, key string, value interface{}) {
+	if data, err := json.Marshal(value); err == nil {
+		s.Backend.Set(ctx, key, data, s.TTL)
+	}
+}
+
+//listVersion return current version of lists, new version is created if it is missing
+func (s *SnippetStore) listVersion(ctx context.Context) (string, error) {
+	data, ok, err := s.Backend.Get(ctx, listVersionKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	if ok {
+		return string(data), nil
+	}
+
+	return s.bumpListVersion(ctx)
+}
+
+//bumpListVersion make all cached lists unreachable
+func (s *SnippetStore) bumpListVersion(ctx context.Context) (string, error) {
+	b := make([]byte, 8)
+	rand.Read(b)
+	version := hex.EncodeToString(b)
+
+	return version, s.Backend.Set(ctx, listVersionKey, []byte(version), 0)
+}
+
+//invalidate drop cached snippets and all lists after change
+func (s *SnippetStore) invalidate(ctx context.Context, snippetIDs ...int64) {
+	keys := make([]string, len(snippetIDs))
+	for i, id := range snippetIDs {
+		keys[i] = snippetKey(id)
+	}
+
+	s.Backend.Delete(ctx, keys...)
+	s.bumpListVersion(ctx)
+}
+
+//Get snippet from cache or store
+func (s *SnippetStore) Get(ctx context.Context, snippetID int64) (*models.Snippet, error) {
+	key := snippetKey(snippetID)
+	snippet := &models.Snippet{}
+
+	if s.load(ctx, "snippets.get", key, snippet) {
+		if isExpired(snippet, time.Now()) {
+			return nil, models.ErrNoRecord
+		}
+		return snippet, nil
+	}
+
+	snippet, err := s.Store.Get(ctx, snippetID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.store(ctx, key, snippet)
+	return snippet, nil
+}
+
+func cursorKey(c *models.Cursor) string {
+	if c == nil {
+		return ""
+	}
+	return c.String()
+}
+
+//listKey identify page of list with options
+func listKey(version string, opts models.ListOptions, req models.PageRequest) string {
+	return fmt.Sprintf(
+		"snippets:lists:%s:%d:%s:%t:%s:%d:%d:%d:%d:%d:%s:%s",
+		version, opts.OwnerID, opts.SortBy(), opts.Asc, opts.Visibility,
+		opts.ExpiresBefore.Unix(), opts.CreatedFrom.Unix(), opts.CreatedTo.Unix(), opts.ParentID,
+		req.Count, cursorKey(req.After), cursorKey(req.Before),
+	)
+}
+
+//List return page from cache or store, snippets expired after page is cached are skipped
+func (s *SnippetStore) List(ctx context.Context, opts models.ListOptions, req models.PageRequest) (*models.SnippetPage, error) {
+	version, err := s.listVersion(ctx)
+
+	if err != nil {
+		s.observe("snippets.list", Error)
+		return s.Store.List(ctx, opts, req)
+	}
+
+	key := listKey(version, opts, req)
+	cached := &models.SnippetPage{}
+
+	if s.load(ctx, "snippets.list", key, cached) {
+		now := time.Now()
+		snippets := make([]*models.Snippet, 0, len(cached.Snippets))
+
+		for _, snippet := range cached.Snippets {
+			if !isExpired(snippet, now) {
+				snippets = append(snippets, snippet)
+			}
+		}
+
+		cached.Snippets = snippets
+		return cached, nil
+	}
+
+	page, err := s.Store.List(ctx, opts, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.store(ctx, key, page)
+	return page, nil
+}
+
+//Insert snippet, lists are invalidated
+func (s *SnippetStore) Insert(ctx context.Context, snippet *models.Snippet) (int64, error) {
+	id, err := s.Store.Insert(ctx, snippet)
+
+	if err == nil {
+		s.invalidate(ctx)
+	}
+
+	return id, err
+}
+
+//Update snippet and invalidate it
+func (s *SnippetStore) Update(ctx context.Context, snippet *models.Snippet, ownerID int64) error {
+	err := s.Store.Update(ctx, snippet, ownerID)
+
+	if err == nil {
+		s.invalidate(ctx, snippet.ID)
+	}
+
+	return err
+}
+
+//Delete snippet and invalidate it with forks, they lose reference to parent
+func (s *SnippetStore) Delete(ctx context.Context, snippetID, userID int64) error {
+	//forks can't be found by parent after delete
+	forks, err := s.Store.ListForkIDs(ctx, snippetID)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.Store.Delete(ctx, snippetID, userID)
+
+	if err == nil {
+		s.invalidate(ctx, append(forks, snippetID)...)
+	}
+
+	return err
+}
+
+//ListForkIDs is not cached
+func (s *SnippetStore) ListForkIDs(ctx context.Context, parentID int64) ([]int64, error) {
+	return s.Store.ListForkIDs(ctx, parentID)
+}
+
+//IncrementViews doesn't invalidate, views of cached snippets and lists are stale until TTL
+func (s *SnippetStore) IncrementViews(ctx context.Context, snippetID int64) error {
+	return s.Store.IncrementViews(ctx, snippetID)
+}
+
+//SetExpiration of snippet and invalidate it
+func (s *SnippetStore) SetExpiration(ctx context.Context, snippetID, ownerID int64, expires time.Time) error {
+	err := s.Store.SetExpiration(ctx, snippetID, ownerID, expires)
+
+	if err == nil {
+		s.invalidate(ctx, snippetID)
+	}
+
+	return err
+}
+
+//CountExpired is not cached
+func (s *SnippetStore) CountExpired(ctx context.Context, before time.Time) (int64, error) {
+	return s.Store.CountExpired(ctx, before)
+}
+
+//PurgeExpired snippets, cached expired snippets are already hidden, so only lists are invalidated,
+//cached forks of purged snippets keep parent ID until TTL
+func (s *SnippetStore) PurgeExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	n, err := s.Store.PurgeExpired(ctx, before, limit)
+
+	if err == nil && n > 0 {
+		s.invalidate(ctx)
+	}
+
+	return n, err
+}
+
+//ExpiryReminders is not cached
+func (s *SnippetStore) ExpiryReminders(ctx context.Context, until time.Time, limit int) ([]*models.ExpiryReminder, error) {
+	return s.Store.ExpiryReminders(ctx, until, limit)
+}
+
+//MarkReminderSent is not cached, reminder state isn't part of cached snippet
+func (s *SnippetStore) MarkReminderSent(ctx context.Context, snippetID int64) error {
+	return s.Store.MarkReminderSent(ctx, snippetID)
+}
+
+//MarkReminderFailed is not cached, reminder state isn't part of cached snippet
+func (s *SnippetStore) MarkReminderFailed(ctx context.Context, snippetID int64, retryAt time.Time) error {
+	return s.Store.MarkReminderFailed(ctx, snippetID, retryAt)
+}
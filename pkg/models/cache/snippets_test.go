@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"githib.com/VladimirStepanov/snippetbox/pkg/models/mock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+//countingStore count calls which reach store
+type countingStore struct {
+	*mock.SnippetStore
+	gets  int
+	lists int
+}
+
+func (s *countingStore) Get(ctx context.Context, snippetID int64) (*models.Snippet, error) {
+	s.gets++
+	return s.SnippetStore.Get(ctx, snippetID)
+}
+
+func (s *countingStore) List(ctx context.Context, opts models.ListOptions, req models.PageRequest) (*models.SnippetPage, error) {
+	s.lists++
+	return s.SnippetStore.List(ctx, opts, req)
+}
+
+//backends return every Backend implementation, Redis is served by miniredis
+func backends(t *testing.T) map[string]Backend {
+	mr := miniredis.RunT(t)
+
+	return map[string]Backend{
+		"LRU":   NewLRU(100),
+		"Redis": &Redis{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()}), Prefix: "test:"},
+	}
+}
+
+func newCachedStore(backend Backend) (*SnippetStore, *countingStore, map[string]int) {
+	users := map[int64]*models.User{1: {ID: 1}}
+	store := &countingStore{SnippetStore: &mock.SnippetStore{UsersMap: users}}
+	results := map[string]int{}
+
+	cached := &SnippetStore{
+		Store:   store,
+		Backend: backend,
+		TTL:     time.Minute,
+		Observer: func(op, result string) {
+			results[op+" "+result]++
+		},
+	}
+
+	return cached, store, results
+}
+
+func TestCachedGet(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			cached, store, results := newCachedStore(backend)
+
+			id, err := cached.Insert(ctx, &models.Snippet{Title: "Title", Content: "Content", OwnerID: 1, IsPublic: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 3; i++ {
+				snippet, err := cached.Get(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if snippet.Title != "Title" {
+					t.Fatalf("Want: Title, Get: %s", snippet.Title)
+				}
+			}
+
+			if store.gets != 1 || results["snippets.get hit"] != 2 || results["snippets.get miss"] != 1 {
+				t.Fatalf("Want 1 store call, 2 hits and 1 miss, Get: %d %v", store.gets, results)
+			}
+
+			err = cached.Update(ctx, &models.Snippet{ID: id, Title: "New", Content: "Content", IsPublic: true}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			snippet, err := cached.Get(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snippet.Title != "New" {
+				t.Fatalf("Updated snippet is not invalidated, Get: %s", snippet.Title)
+			}
+
+			if err := cached.Delete(ctx, id, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cached.Get(ctx, id); err != models.ErrNoRecord {
+				t.Fatalf("Deleted snippet is not invalidated, Get: %v", err)
+			}
+		})
+	}
+}
+
+func TestCachedGetExpired(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			cached, store, _ := newCachedStore(backend)
+
+			expires := time.Now().Add(50 * time.Millisecond)
+			id, err := cached.Insert(ctx, &models.Snippet{Title: "Title", OwnerID: 1, Expires: expires})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cached.Get(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+
+			time.Sleep(time.Until(expires))
+
+			if _, err := cached.Get(ctx, id); err != models.ErrNoRecord {
+				t.Fatalf("Want: %v, Get: %v", models.ErrNoRecord, err)
+			}
+
+			if store.gets != 1 {
+				t.Fatalf("Want 1 store call, Get: %d", store.gets)
+			}
+		})
+	}
+}
+
+func TestCachedList(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			cached, store, results := newCachedStore(backend)
+
+			latest := func(ownerID int64) []*models.Snippet {
+				page, err := cached.List(ctx, models.ListOptions{OwnerID: ownerID}, models.PageRequest{Count: 10})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return page.Snippets
+			}
+
+			id, _ := cached.Insert(ctx, &models.Snippet{Title: "First", OwnerID: 1, IsPublic: true})
+
+			if len(latest(-1)) != 1 || len(latest(-1)) != 1 || len(latest(1)) != 1 {
+				t.Fatal("Want 1 snippet")
+			}
+
+			if store.lists != 2 || results["snippets.list hit"] != 1 {
+				t.Fatalf("Want 2 store calls and 1 hit, Get: %d %v", store.lists, results)
+			}
+
+			if _, err := cached.List(ctx, models.ListOptions{OwnerID: -1, Sort: models.SortTitle}, models.PageRequest{Count: 10}); err != nil || store.lists != 3 {
+				t.Fatalf("List with other options is read from cache: %v %d", err, store.lists)
+			}
+
+			steps := map[string]func() error{
+				"Insert": func() error {
+					_, err := cached.Insert(ctx, &models.Snippet{Title: "Second", OwnerID: 1, IsPublic: true})
+					return err
+				},
+				"SetExpiration": func() error {
+					return cached.SetExpiration(ctx, id, 1, time.Now().Add(-time.Second))
+				},
+			}
+
+			for _, step := range []string{"Insert", "SetExpiration"} {
+				before := store.lists
+				if err := steps[step](); err != nil {
+					t.Fatal(err)
+				}
+
+				latest(-1)
+
+				if store.lists != before+1 {
+					t.Fatalf("Lists are not invalidated after %s", step)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedViewsAndForks(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			cached, store, _ := newCachedStore(backend)
+
+			parentID, _ := cached.Insert(ctx, &models.Snippet{Title: "Parent", OwnerID: 1, IsPublic: true})
+			forkID, err := cached.Insert(ctx, &models.Snippet{Title: "Fork", OwnerID: 1, IsPublic: true, ParentID: parentID})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cached.Get(ctx, parentID); err != nil {
+				t.Fatal(err)
+			}
+
+			page := models.PageRequest{Count: 10}
+			if _, err := cached.List(ctx, models.ListOptions{OwnerID: -1}, page); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := cached.IncrementViews(ctx, parentID); err != nil {
+				t.Fatal(err)
+			}
+
+			if snippet, _ := cached.Get(ctx, parentID); snippet.Views != 0 || store.gets != 1 {
+				t.Fatalf("Viewed snippet is not read from cache, views: %d, store calls: %d", snippet.Views, store.gets)
+			}
+
+			if _, err := cached.List(ctx, models.ListOptions{OwnerID: -1}, page); err != nil || store.lists != 1 {
+				t.Fatalf("Lists are invalidated after view: %v %d", err, store.lists)
+			}
+
+			if fork, _ := cached.Get(ctx, forkID); fork.ParentID != parentID {
+				t.Fatalf("Want parent: %d, Get: %d", parentID, fork.ParentID)
+			}
+
+			if err := cached.Delete(ctx, parentID, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			if fork, _ := cached.Get(ctx, forkID); fork.ParentID != 0 {
+				t.Fatalf("Fork of deleted snippet is not invalidated, parent: %d", fork.ParentID)
+			}
+		})
+	}
+}
+
+//brokenBackend fail every operation
+type brokenBackend struct{}
+
+func (brokenBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (brokenBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (brokenBackend) Delete(ctx context.Context, keys ...string) error {
+	return errors.New("connection refused")
+}
+
+func TestBrokenBackend(t *testing.T) {
+	ctx := context.Background()
+	cached, store, results := newCachedStore(brokenBackend{})
+
+	id, err := cached.Insert(ctx, &models.Snippet{Title: "Title", OwnerID: 1, IsPublic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cached.Get(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if page, err := cached.List(ctx, models.ListOptions{OwnerID: -1}, models.PageRequest{Count: 10}); err != nil || len(page.Snippets) != 1 {
+		t.Fatalf("Want 1 snippet, Get: %v %v", page, err)
+	}
+
+	if store.gets != 1 || store.lists != 1 || results["snippets.get error"] != 1 || results["snippets.list error"] != 1 {
+		t.Fatalf("Store is not called on backend error: %d %d %v", store.gets, store.lists, results)
+	}
+}
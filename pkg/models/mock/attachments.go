@@ -0,0 +1,91 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//AttachmentStore mock for attachments metadata, Snippets is used to find attachments of expired snippets
+type AttachmentStore struct {
+	DB       []*models.Attachment
+	Snippets *SnippetStore
+}
+
+//Insert attachment to slice
+func (as *AttachmentStore) Insert(ctx context.Context, attachment *models.Attachment) (int64, error) {
+	res := &models.Attachment{}
+	*res = *attachment
+
+	res.ID = 1
+	if len(as.DB) > 0 {
+		res.ID = as.DB[len(as.DB)-1].ID + 1
+	}
+
+	if res.Created.IsZero() {
+		res.Created = time.Now()
+	}
+
+	as.DB = append(as.DB, res)
+
+	return res.ID, nil
+}
+
+//Get attachment by id
+func (as *AttachmentStore) Get(ctx context.Context, id int64) (*models.Attachment, error) {
+	for _, a := range as.DB {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+//List return attachments of snippet in upload order
+func (as *AttachmentStore) List(ctx context.Context, snippetID int64) ([]*models.Attachment, error) {
+	res := []*models.Attachment{}
+
+	for _, a := range as.DB {
+		if a.SnippetID == snippetID {
+			res = append(res, a)
+		}
+	}
+
+	return res, nil
+}
+
+//ListExpired return attachments of snippets which next PurgeExpired of Snippets removes
+func (as *AttachmentStore) ListExpired(ctx context.Context, before time.Time, limit int) ([]*models.Attachment, error) {
+	res := []*models.Attachment{}
+
+	if as.Snippets == nil {
+		return res, nil
+	}
+
+	ids := map[int64]bool{}
+	for snippet := range as.Snippets.expiredBatch(before, limit) {
+		ids[snippet.ID] = true
+	}
+
+	for _, a := range as.DB {
+		if ids[a.SnippetID] {
+			res = append(res, a)
+		}
+	}
+
+	return res, nil
+}
+
+//Delete attachment from slice
+func (as *AttachmentStore) Delete(ctx context.Context, id int64) error {
+	for i, a := range as.DB {
+		if a.ID == id {
+			as.DB = append(as.DB[:i], as.DB[i+1:]...)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
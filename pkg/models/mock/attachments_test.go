@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+func TestAttachments(t *testing.T) {
+	as := &AttachmentStore{}
+	ctx := context.Background()
+
+	for _, a := range []*models.Attachment{
+		{SnippetID: 1, Filename: "app.log", Key: "snippets/1/a"},
+		{SnippetID: 2, Filename: "core.gz", Key: "snippets/2/b"},
+		{SnippetID: 1, Filename: "trace.txt", Key: "snippets/1/c"},
+	} {
+		if _, err := as.Insert(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := as.List(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 || list[0].Filename != "app.log" || list[1].Filename != "trace.txt" {
+		t.Fatalf("Bad attachments of snippet: %v", list)
+	}
+
+	if err := as.Delete(ctx, list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := as.Get(ctx, list[0].ID); err != models.ErrNoRecord {
+		t.Fatalf("Want ErrNoRecord after Delete, Get: %v", err)
+	}
+
+	if err := as.Delete(ctx, 100); err != models.ErrNoRecord {
+		t.Fatalf("Want ErrNoRecord for unknown attachment, Get: %v", err)
+	}
+
+	id, _ := as.Insert(ctx, &models.Attachment{SnippetID: 1, Filename: "new.log"})
+
+	if a, err := as.Get(ctx, id); err != nil || a.Filename != "new.log" || a.Created.IsZero() {
+		t.Fatalf("Bad inserted attachment: %v %v", a, err)
+	}
+}
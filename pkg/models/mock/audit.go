@@ -0,0 +1,87 @@
+package mock
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//AuditStore mock for audit events
+type AuditStore struct {
+	DB []*models.AuditEvent
+}
+
+//Insert audit event to slice
+func (as *AuditStore) Insert(ctx context.Context, event *models.AuditEvent) (int64, error) {
+	res := &models.AuditEvent{}
+	*res = *event
+
+	res.ID = int64(len(as.DB) + 1)
+	if res.Created.IsZero() {
+		res.Created = time.Now()
+	}
+
+	as.DB = append(as.DB, res)
+
+	return res.ID, nil
+}
+
+func matchAuditFilter(event *models.AuditEvent, filter *models.AuditFilter) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.ActorID != 0 && filter.ActorID != event.ActorID {
+		return false
+	}
+
+	if filter.UserID != 0 && filter.UserID != event.ActorID && (event.TargetType != "user" || filter.UserID != event.TargetID) {
+		return false
+	}
+
+	if filter.Action != "" && filter.Action != event.Action {
+		return false
+	}
+
+	if !filter.From.IsZero() && event.Created.Before(filter.From) {
+		return false
+	}
+
+	if !filter.To.IsZero() && !event.Created.Before(filter.To) {
+		return false
+	}
+
+	return true
+}
+
+//List return audit events matching filter sorted by create date
+func (as *AuditStore) List(ctx context.Context, filter *models.AuditFilter, count, page int) ([]*models.AuditEvent, error) {
+	found := []*models.AuditEvent{}
+
+	for _, val := range as.DB {
+		if matchAuditFilter(val, filter) {
+			found = append(found, val)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Created.Equal(found[j].Created) {
+			return found[i].ID > found[j].ID
+		}
+		return found[i].Created.After(found[j].Created)
+	})
+
+	start := count*page - count
+	if start >= len(found) {
+		return []*models.AuditEvent{}, nil
+	}
+
+	end := start + count
+	if end > len(found) {
+		end = len(found)
+	}
+
+	return found[start:end], nil
+}
@@ -0,0 +1,99 @@
+package mock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+func getPreparedAuditStore(t *testing.T) *AuditStore {
+	as := &AuditStore{}
+	now := time.Now()
+
+	events := []*models.AuditEvent{
+		{ActorID: 1, Action: models.AuditLogin, Created: now.Add(-3 * time.Hour)},
+		{ActorID: 1, Action: models.AuditSnippetCreate, TargetType: "snippet", TargetID: 5, Created: now.Add(-2 * time.Hour)},
+		{ActorID: 2, Action: models.AuditLogin, Created: now.Add(-time.Hour)},
+		{Action: models.AuditLoginFailed, Details: "email=test@mail.com", Created: now},
+	}
+
+	for _, e := range events {
+		if _, err := as.Insert(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return as
+}
+
+func TestAuditList(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		Filter      *models.AuditFilter
+		Count       int
+		Page        int
+		WantActions []string
+	}{
+		"All events": {
+			Filter:      nil,
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditLoginFailed, models.AuditLogin, models.AuditSnippetCreate, models.AuditLogin},
+		},
+		"Filter by actor": {
+			Filter:      &models.AuditFilter{ActorID: 1},
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditSnippetCreate, models.AuditLogin},
+		},
+		"Filter by action": {
+			Filter:      &models.AuditFilter{Action: models.AuditLogin},
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditLogin, models.AuditLogin},
+		},
+		"Filter by date range": {
+			Filter:      &models.AuditFilter{From: now.Add(-150 * time.Minute), To: now.Add(-time.Minute)},
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditLogin, models.AuditSnippetCreate},
+		},
+		"Second page": {
+			Filter:      nil,
+			Count:       3,
+			Page:        2,
+			WantActions: []string{models.AuditLogin},
+		},
+		"Page out of range": {
+			Filter:      nil,
+			Count:       10,
+			Page:        2,
+			WantActions: []string{},
+		},
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			as := getPreparedAuditStore(t)
+
+			events, err := as.List(context.Background(), value.Filter, value.Count, value.Page)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(events) != len(value.WantActions) {
+				t.Fatalf("Want len: %d, Get len: %d", len(value.WantActions), len(events))
+			}
+
+			for i, e := range events {
+				if e.Action != value.WantActions[i] {
+					t.Fatalf("Want action: %s, Get action: %s", value.WantActions[i], e.Action)
+				}
+			}
+		})
+	}
+}
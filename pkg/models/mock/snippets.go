@@ -1,8 +1,12 @@
 package mock
 
 import (
+	"cmp"
+	"context"
 	"math/rand"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
@@ -31,35 +35,74 @@ func getRandSnippetID(slice []*models.Snippet) int64 {
 
 //SnippetStore mock for snippets
 type SnippetStore struct {
-	DB       []*models.Snippet
-	UsersMap map[int64]*models.User
+	DB               []*models.Snippet
+	UsersMap         map[int64]*models.User
+	RemindersSent    map[int64]bool
+	ReminderAttempts map[int64]int
+	ReminderRetry    map[int64]time.Time
+}
+
+func isAlive(snippet *models.Snippet) bool {
+	return snippet.NeverExpires() || snippet.Expires.After(time.Now())
+}
+
+func isExpiredBefore(snippet *models.Snippet, before time.Time) bool {
+	return !snippet.NeverExpires() && snippet.Expires.Before(before)
+}
+
+//copyFiles return files of snippet as they are saved in database
+func copyFiles(snippetID int64, files []*models.SnippetFile) []*models.SnippetFile {
+	res := make([]*models.SnippetFile, len(files))
+
+	for i, file := range files {
+		res[i] = &models.SnippetFile{
+			ID:        int64(i + 1),
+			SnippetID: snippetID,
+			Filename:  file.Filename,
+			Language:  file.Language,
+			Content:   file.Content,
+		}
+	}
+
+	return res
 }
 
 //Insert snippet to map
-func (s *SnippetStore) Insert(title, content string, expire int, isPublic bool, ownerID int64) (int64, error) {
-	if _, ok := s.UsersMap[ownerID]; !ok {
+func (s *SnippetStore) Insert(ctx context.Context, snippet *models.Snippet) (int64, error) {
+	if _, ok := s.UsersMap[snippet.OwnerID]; !ok {
 		return 0, models.ErrUnknownOwnerID
 	}
 
+	if snippet.ParentID != 0 && !slices.ContainsFunc(s.DB, func(value *models.Snippet) bool { return value.ID == snippet.ParentID }) {
+		return 0, models.ErrUnknownParent
+	}
+
 	id := getRandSnippetID(s.DB)
+	now := time.Now()
 
 	s.DB = append(s.DB, &models.Snippet{
-		ID:       id,
-		Title:    title,
-		Content:  content,
-		Created:  time.Now(),
-		Expires:  time.Now().AddDate(0, 0, expire),
-		OwnerID:  ownerID,
-		IsPublic: isPublic,
+		ID:           id,
+		Title:        snippet.Title,
+		Content:      snippet.Content,
+		Created:      now,
+		Updated:      now,
+		Expires:      snippet.Expires,
+		OwnerID:      snippet.OwnerID,
+		IsPublic:     snippet.IsPublic,
+		Unlisted:     snippet.Unlisted,
+		RemindExpiry: snippet.RemindExpiry,
+		Format:       snippet.Format,
+		Files:        copyFiles(id, snippet.Files),
+		ParentID:     snippet.ParentID,
 	})
 
 	return id, nil
 }
 
 //Get specific snippet
-func (s *SnippetStore) Get(snippetID int64) (*models.Snippet, error) {
+func (s *SnippetStore) Get(ctx context.Context, snippetID int64) (*models.Snippet, error) {
 	for _, value := range s.DB {
-		if value.ID == snippetID && value.Expires.After(time.Now()) {
+		if value.ID == snippetID && isAlive(value) {
 			return value, nil
 		}
 	}
@@ -68,10 +111,17 @@ func (s *SnippetStore) Get(snippetID int64) (*models.Snippet, error) {
 }
 
 //Delete from snippets
-func (s *SnippetStore) Delete(snippetID, userID int64) error {
+func (s *SnippetStore) Delete(ctx context.Context, snippetID, userID int64) error {
 	for i, value := range s.DB {
-		if value.ID == snippetID && value.OwnerID == userID && value.Expires.After(time.Now()) {
-			remove(s.DB, i)
+		if value.ID == snippetID && value.OwnerID == userID && isAlive(value) {
+			s.DB = remove(s.DB, i)
+
+			//forks stay, but lose reference like with ON DELETE SET NULL
+			for _, fork := range s.DB {
+				if fork.ParentID == snippetID {
+					fork.ParentID = 0
+				}
+			}
 			return nil
 		}
 	}
@@ -80,12 +130,21 @@ func (s *SnippetStore) Delete(snippetID, userID int64) error {
 }
 
 //Update from snippets
-func (s *SnippetStore) Update(snippet *models.Snippet, ownerID int64) error {
+func (s *SnippetStore) Update(ctx context.Context, snippet *models.Snippet, ownerID int64) error {
 	for _, value := range s.DB {
-		if value.ID == snippet.ID && value.OwnerID == ownerID && value.Expires.After(time.Now()) {
+		if value.ID == snippet.ID && value.OwnerID == ownerID && isAlive(value) {
 			value.Title = snippet.Title
 			value.Content = snippet.Content
 			value.IsPublic = snippet.IsPublic
+			value.Unlisted = snippet.Unlisted
+			value.Format = snippet.Format
+			value.Files = copyFiles(value.ID, snippet.Files)
+			if !value.Expires.Equal(snippet.Expires) {
+				s.resetReminder(value.ID)
+			}
+			value.Expires = snippet.Expires
+			value.RemindExpiry = snippet.RemindExpiry
+			value.Updated = time.Now()
 			return nil
 		}
 	}
@@ -93,31 +152,261 @@ func (s *SnippetStore) Update(snippet *models.Snippet, ownerID int64) error {
 	return models.ErrNoRecord
 }
 
-//LatestAll return latest snippets sorted by create_date
-func (s *SnippetStore) LatestAll(ownerID int64, count, page int) ([]*models.Snippet, error) {
-	start := page*count - count + 1
-	if page == 1 {
-		start = 0
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case time.Time:
+		return av.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(av, b.(string))
+	case int64:
+		return cmp.Compare(av, b.(int64))
+	}
+	return 0
+}
+
+//compareToCursor return negative number if snippet goes before cursor in list, positive if after
+func compareToCursor(snippet *models.Snippet, c *models.Cursor, asc bool) int {
+	res := compareValues(snippet.SortValue(c.Sort), c.Value)
+
+	if res == 0 {
+		res = cmp.Compare(snippet.ID, c.ID)
+	}
+
+	if !asc {
+		res = -res
+	}
+
+	return res
+}
+
+//isListed report if alive snippet matches list options
+func isListed(snippet *models.Snippet, opts models.ListOptions) bool {
+	if opts.OwnerID == -1 {
+		if snippet.Visibility() != models.VisibilityPublic {
+			return false
+		}
+	} else if snippet.OwnerID != opts.OwnerID || (opts.Visibility != "" && snippet.Visibility() != opts.Visibility) {
+		return false
+	}
+
+	if !opts.ExpiresBefore.IsZero() && (snippet.NeverExpires() || snippet.Expires.After(opts.ExpiresBefore)) {
+		return false
+	}
+
+	if !opts.CreatedFrom.IsZero() && snippet.Created.Before(opts.CreatedFrom) {
+		return false
+	}
+
+	if opts.ParentID != 0 && snippet.ParentID != opts.ParentID {
+		return false
+	}
+
+	return opts.CreatedTo.IsZero() || snippet.Created.Before(opts.CreatedTo)
+}
+
+//List return page of snippets filtered and sorted by options
+func (s *SnippetStore) List(ctx context.Context, opts models.ListOptions, req models.PageRequest) (*models.SnippetPage, error) {
+	sortBy := opts.SortBy()
+
+	if !slices.Contains(models.SortFields, sortBy) {
+		return nil, models.ErrUnknownSort
+	}
+
+	for _, c := range []*models.Cursor{req.After, req.Before} {
+		if c != nil && c.Sort != sortBy {
+			return nil, models.ErrBadCursor
+		}
+	}
+
+	all := []*models.Snippet{}
+
+	for _, val := range s.DB {
+		if isAlive(val) && isListed(val, opts) {
+			all = append(all, val)
+		}
 	}
-	sort.SliceStable(s.DB, func(i, j int) bool {
-		return s.DB[i].Expires.Before(s.DB[j].Expires)
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return compareToCursor(all[i], models.CursorOf(all[j], sortBy), opts.Asc) < 0
 	})
+
+	page := &models.SnippetPage{Total: int64(len(all))}
+	start, end := 0, len(all)
+
+	switch {
+	case req.After != nil:
+		for start < len(all) && compareToCursor(all[start], req.After, opts.Asc) <= 0 {
+			start++
+		}
+		page.HasPrev = true
+	case req.Before != nil:
+		for end > 0 && compareToCursor(all[end-1], req.Before, opts.Asc) >= 0 {
+			end--
+		}
+		page.HasNext = true
+	}
+
+	if req.Before != nil {
+		page.HasPrev = end-start > req.Count
+		if page.HasPrev {
+			start = end - req.Count
+		}
+	} else {
+		page.HasNext = end-start > req.Count
+		if page.HasNext {
+			end = start + req.Count
+		}
+	}
+
+	page.Snippets = all[start:end]
+
+	return page, nil
+}
+
+//ListForkIDs return IDs of all forks of snippet
+func (s *SnippetStore) ListForkIDs(ctx context.Context, parentID int64) ([]int64, error) {
+	res := []int64{}
+
+	for _, value := range s.DB {
+		if value.ParentID == parentID {
+			res = append(res, value.ID)
+		}
+	}
+
+	return res, nil
+}
+
+//IncrementViews count one more view of snippet
+func (s *SnippetStore) IncrementViews(ctx context.Context, snippetID int64) error {
+	for _, value := range s.DB {
+		if value.ID == snippetID {
+			value.Views++
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+//CountExpired return count of snippets expired before specified time
+func (s *SnippetStore) CountExpired(ctx context.Context, before time.Time) (int64, error) {
+	var count int64
+
+	for _, val := range s.DB {
+		if isExpiredBefore(val, before) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+//expiredBatch return at most limit snippets expired before specified time, which PurgeExpired removes
+func (s *SnippetStore) expiredBatch(before time.Time, limit int) map[*models.Snippet]bool {
+	res := map[*models.Snippet]bool{}
+
+	for _, val := range s.DB {
+		if isExpiredBefore(val, before) && len(res) < limit {
+			res[val] = true
+		}
+	}
+
+	return res
+}
+
+//PurgeExpired delete at most limit snippets expired before specified time
+func (s *SnippetStore) PurgeExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	batch := s.expiredBatch(before, limit)
 	res := []*models.Snippet{}
 
-	for _, val := range s.DB[start:] {
-		if val.Expires.After(time.Now()) {
-			if ownerID == -1 && val.IsPublic {
-				res = append(res, val)
-			} else if ownerID != -1 && ownerID == val.OwnerID {
-				res = append(res, val)
-			}
+	for _, val := range s.DB {
+		if !batch[val] {
+			res = append(res, val)
+		}
+	}
 
-			if len(res) == count {
-				break
-			}
+	s.DB = res
+
+	return int64(len(batch)), nil
+}
+
+//SetExpiration change expiration date of not expired snippet
+func (s *SnippetStore) SetExpiration(ctx context.Context, snippetID, ownerID int64, expires time.Time) error {
+	for _, value := range s.DB {
+		if value.ID == snippetID && value.OwnerID == ownerID && isAlive(value) {
+			value.Expires = expires
+			value.Updated = time.Now()
+			s.resetReminder(value.ID)
+			return nil
 		}
 	}
 
+	return models.ErrNoRecord
+}
+
+//resetReminder send reminder again for new expiration date
+func (s *SnippetStore) resetReminder(snippetID int64) {
+	delete(s.RemindersSent, snippetID)
+	delete(s.ReminderAttempts, snippetID)
+	delete(s.ReminderRetry, snippetID)
+}
+
+//ExpiryReminders return not reminded snippets which expire before until, failed reminders wait for retry date
+func (s *SnippetStore) ExpiryReminders(ctx context.Context, until time.Time, limit int) ([]*models.ExpiryReminder, error) {
+	res := []*models.ExpiryReminder{}
+
+	for _, val := range s.DB {
+		if len(res) == limit {
+			break
+		}
+
+		if !val.RemindExpiry || s.RemindersSent[val.ID] || !isAlive(val) || val.NeverExpires() || val.Expires.After(until) {
+			continue
+		}
+
+		if s.ReminderRetry[val.ID].After(time.Now()) {
+			continue
+		}
+
+		u, ok := s.UsersMap[val.OwnerID]
+		if !ok {
+			continue
+		}
+
+		res = append(res, &models.ExpiryReminder{Snippet: val, Email: u.Email, Firstname: u.Firstname, Attempts: s.ReminderAttempts[val.ID]})
+	}
+
 	return res, nil
+}
+
+//MarkReminderSent ...
+func (s *SnippetStore) MarkReminderSent(ctx context.Context, snippetID int64) error {
+	for _, val := range s.DB {
+		if val.ID == snippetID {
+			if s.RemindersSent == nil {
+				s.RemindersSent = map[int64]bool{}
+			}
+			s.RemindersSent[snippetID] = true
+			return nil
+		}
+	}
 
+	return models.ErrNoRecord
+}
+
+//MarkReminderFailed count failed send of reminder, it isn't returned by ExpiryReminders until retryAt
+func (s *SnippetStore) MarkReminderFailed(ctx context.Context, snippetID int64, retryAt time.Time) error {
+	for _, val := range s.DB {
+		if val.ID == snippetID {
+			if s.ReminderAttempts == nil {
+				s.ReminderAttempts = map[int64]int{}
+				s.ReminderRetry = map[int64]time.Time{}
+			}
+			s.ReminderAttempts[snippetID]++
+			s.ReminderRetry[snippetID] = retryAt
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
 }
@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"context"
 	"math/rand"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
@@ -23,7 +24,7 @@ func getRandUserID(m map[int64]*models.User) int64 {
 }
 
 //Insert user in map
-func (us *UsersStore) Insert(firstname, lastname, mail, password string) (int64, error) {
+func (us *UsersStore) Insert(ctx context.Context, firstname, lastname, mail, password string) (int64, error) {
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 
@@ -45,7 +46,7 @@ func (us *UsersStore) Insert(firstname, lastname, mail, password string) (int64,
 }
 
 //Get User from map
-func (us *UsersStore) Get(id int64) (*models.User, error) {
+func (us *UsersStore) Get(ctx context.Context, id int64) (*models.User, error) {
 	if val, ok := us.DB[id]; ok {
 		retVal := &models.User{}
 		*retVal = *val
@@ -56,8 +57,19 @@ func (us *UsersStore) Get(id int64) (*models.User, error) {
 	return nil, models.ErrNoRecord
 }
 
+//GetByEmail return user with specified email from map
+func (us *UsersStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for id, value := range us.DB {
+		if value.Email == email {
+			return us.Get(ctx, id)
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
 //Authenticate ...
-func (us *UsersStore) Authenticate(email, password string) (int64, error) {
+func (us *UsersStore) Authenticate(ctx context.Context, email, password string) (int64, error) {
 	for id, value := range us.DB {
 		if value.Email == email {
 			err := bcrypt.CompareHashAndPassword([]byte(value.HashedPassword), []byte(password))
@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -10,7 +11,7 @@ import (
 func TestInsertUser(t *testing.T) {
 	us := UsersStore{DB: map[int64]*models.User{}}
 
-	_, err := us.Insert("test", "test", "test", "test")
+	_, err := us.Insert(context.Background(), "test", "test", "test", "test")
 
 	if err != nil {
 		t.Fatal(err)
@@ -21,13 +22,13 @@ func TestInsertUser(t *testing.T) {
 func TestDuplicateEmail(t *testing.T) {
 	us := UsersStore{DB: map[int64]*models.User{}}
 
-	_, err := us.Insert("test", "test", "test", "test")
+	_, err := us.Insert(context.Background(), "test", "test", "test", "test")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = us.Insert("test", "test", "test", "test")
+	_, err = us.Insert(context.Background(), "test", "test", "test", "test")
 
 	if err != models.ErrDuplicateEmail {
 		t.Fatalf("get: %v, want: %v", err, models.ErrDuplicateEmail)
@@ -59,7 +60,7 @@ func TestGetUser(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			us := UsersStore{DB: map[int64]*models.User{}}
 			if value.WantUser != nil {
-				id, err := us.Insert(value.WantUser.Firstname, value.WantUser.Lastname, value.WantUser.Email, "1234")
+				id, err := us.Insert(context.Background(), value.WantUser.Firstname, value.WantUser.Lastname, value.WantUser.Email, "1234")
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -67,7 +68,7 @@ func TestGetUser(t *testing.T) {
 				value.UserID = id
 			}
 
-			resUser, err := us.Get(value.UserID)
+			resUser, err := us.Get(context.Background(), value.UserID)
 
 			if value.WantError != nil && value.WantError != err {
 				t.Fatalf("Error %v != %v", value.WantError, err)
@@ -115,13 +116,13 @@ func TestAuthentication(t *testing.T) {
 
 			if value.Data.WantAdd {
 				var err error
-				value.WantID, err = us.Insert("1", "2", value.Data.Email, value.Data.Password)
+				value.WantID, err = us.Insert(context.Background(), "1", "2", value.Data.Email, value.Data.Password)
 				if err != nil {
 					t.Fatal(err)
 				}
 			}
 
-			authID, err := us.Authenticate(value.Data.Email, value.Data.WantPassword)
+			authID, err := us.Authenticate(context.Background(), value.Data.Email, value.Data.WantPassword)
 
 			if value.WantError != nil && value.WantError != err {
 				t.Fatalf("want: %v, get: %v", value.WantError, err)
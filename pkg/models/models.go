@@ -1,16 +1,23 @@
 package models
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 //Custom errors
 var (
 	ErrNoRecord       = errors.New("models: Record not found")
+	ErrUnknownParent  = errors.New("models: Unknown parent snippet")
 	ErrDuplicateEmail = errors.New("models: Duplicate email")
 	ErrAuth           = errors.New("models: Can't find user in database")
 	ErrUnknownOwnerID = errors.New("models: Unknown snippet owner ID ")
+	ErrBadCursor      = errors.New("models: Bad page cursor")
+	ErrUnknownSort    = errors.New("models: Unknown sort field")
 )
 
 //User model for users table
@@ -22,15 +29,295 @@ type User struct {
 	Password       string
 	HashedPassword []byte
 	LogoutHash     string
+	IsAdmin        bool
 }
 
 //Snippet model for snippets table
 type Snippet struct {
-	ID       int64
-	Title    string
-	Content  string
-	Created  time.Time
-	Expires  time.Time
-	OwnerID  int64
-	IsPublic bool
+	ID           int64
+	Title        string
+	Content      string
+	Created      time.Time
+	Updated      time.Time // changed by edit and expiration change, used for HTTP caching
+	Expires      time.Time // zero value means snippet never expires
+	OwnerID      int64
+	IsPublic     bool
+	Unlisted     bool // public snippet which is available by link, but not shown in public lists
+	RemindExpiry bool
+	Views        int64
+	Format       string         // one of Formats, content is shown as is for FormatText
+	Files        []*SnippetFile // loaded by Get only, saved by Insert and Update
+	ParentID     int64          // snippet this one is forked from, 0 if it isn't fork or parent is deleted
+}
+
+//SnippetFile model for snippet_files table, file of multi-file snippet
+type SnippetFile struct {
+	ID        int64
+	SnippetID int64
+	Filename  string // unique in snippet
+	Language  string // used for highlighting, empty for plain text
+	Content   string
+}
+
+//Attachment model for attachments table, uploaded file of snippet. Content is kept in blob store by Key
+type Attachment struct {
+	ID          int64
+	SnippetID   int64
+	Filename    string // name of uploaded file, it's used only for download
+	ContentType string // detected from content on upload
+	Size        int64
+	Key         string
+	Created     time.Time
+}
+
+//File return snippet file by name or nil
+func (s *Snippet) File(filename string) *SnippetFile {
+	for _, file := range s.Files {
+		if file.Filename == filename {
+			return file
+		}
+	}
+	return nil
+}
+
+//Snippet content formats
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+)
+
+//Formats list of all snippet content formats
+var Formats = []string{FormatText, FormatMarkdown}
+
+//Snippet visibility, see Snippet.Visibility
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+//Visibilities list of all snippet visibilities
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+//IsMarkdown report if content is rendered as markdown
+func (s *Snippet) IsMarkdown() bool {
+	return s.Format == FormatMarkdown
+}
+
+//Visibility return who can find snippet
+func (s *Snippet) Visibility() string {
+	switch {
+	case !s.IsPublic:
+		return VisibilityPrivate
+	case s.Unlisted:
+		return VisibilityUnlisted
+	default:
+		return VisibilityPublic
+	}
+}
+
+//Fields of snippets lists sorting
+const (
+	SortCreated = "created"
+	SortExpires = "expires"
+	SortTitle   = "title"
+	SortViews   = "views"
+)
+
+//SortFields list of all fields of snippets lists sorting
+var SortFields = []string{SortCreated, SortExpires, SortTitle, SortViews}
+
+//NeverExpiresSortValue is used instead of zero Expires in sorting, never expiring snippets go after all others
+var NeverExpiresSortValue = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+//SortValue return value of snippet field by which lists are sorted: time.Time, string or int64
+func (s *Snippet) SortValue(sort string) interface{} {
+	switch sort {
+	case SortExpires:
+		if s.NeverExpires() {
+			return NeverExpiresSortValue
+		}
+		return s.Expires
+	case SortTitle:
+		return s.Title
+	case SortViews:
+		return s.Views
+	default:
+		return s.Created
+	}
+}
+
+//ListOptions filter and order of snippets list, zero fields are ignored
+type ListOptions struct {
+	OwnerID       int64  // -1 means public listed snippets of all users
+	Sort          string // one of SortFields, SortCreated if empty
+	Asc           bool   // ascending order, by default greatest values go first
+	Visibility    string // one of Visibilities, only for lists of owner
+	ExpiresBefore time.Time
+	CreatedFrom   time.Time
+	CreatedTo     time.Time // exclusive
+	ParentID      int64     // only forks of snippet, 0 means any snippets
+}
+
+//SortBy return sort field with default applied
+func (o *ListOptions) SortBy() string {
+	if o.Sort == "" {
+		return SortCreated
+	}
+	return o.Sort
+}
+
+//Cursor is position in snippets list: sort value and ID of snippet, ID orders snippets with equal values
+type Cursor struct {
+	Sort  string
+	Value interface{}
+	ID    int64
+}
+
+//CursorOf return position of snippet in list sorted by field
+func CursorOf(s *Snippet, sort string) *Cursor {
+	return &Cursor{Sort: sort, Value: s.SortValue(sort), ID: s.ID}
+}
+
+//String encode cursor for URLs
+func (c *Cursor) String() string {
+	var value string
+
+	switch v := c.Value.(type) {
+	case time.Time:
+		value = v.UTC().Format(time.RFC3339Nano)
+	default:
+		value = fmt.Sprint(v)
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s_%d_%s", c.Sort, c.ID, value)))
+}
+
+//ParseCursor decode cursor from String
+func ParseCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+
+	parts := strings.SplitN(string(data), "_", 3)
+	if len(parts) != 3 {
+		return nil, ErrBadCursor
+	}
+
+	c := &Cursor{Sort: parts[0]}
+
+	c.ID, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || c.ID < 1 {
+		return nil, ErrBadCursor
+	}
+
+	switch c.Sort {
+	case SortCreated, SortExpires:
+		c.Value, err = time.Parse(time.RFC3339Nano, parts[2])
+	case SortTitle:
+		c.Value = parts[2]
+	case SortViews:
+		c.Value, err = strconv.ParseInt(parts[2], 10, 64)
+	default:
+		err = ErrBadCursor
+	}
+
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+
+	return c, nil
+}
+
+//PageRequest select page of list: first page, snippets after cursor or before cursor
+type PageRequest struct {
+	Count  int
+	After  *Cursor
+	Before *Cursor
+}
+
+//SnippetPage is part of snippets list with positions for neighbour pages
+type SnippetPage struct {
+	Snippets []*Snippet
+	HasNext  bool
+	HasPrev  bool
+	Total    int64
+}
+
+//NextCursor return cursor of next page or nil, sort is the field list is sorted by
+func (p *SnippetPage) NextCursor(sort string) *Cursor {
+	if !p.HasNext || len(p.Snippets) == 0 {
+		return nil
+	}
+	return CursorOf(p.Snippets[len(p.Snippets)-1], sort)
+}
+
+//PrevCursor return cursor of previous page or nil, sort is the field list is sorted by
+func (p *SnippetPage) PrevCursor(sort string) *Cursor {
+	if !p.HasPrev || len(p.Snippets) == 0 {
+		return nil
+	}
+	return CursorOf(p.Snippets[0], sort)
+}
+
+//NeverExpires ...
+func (s *Snippet) NeverExpires() bool {
+	return s.Expires.IsZero()
+}
+
+//ExpiryReminder snippet which owner should be reminded about expiration
+type ExpiryReminder struct {
+	Snippet   *Snippet
+	Email     string
+	Firstname string
+	Attempts  int // failed sends of reminder
+}
+
+//Audit event actions
+const (
+	AuditLogin             = "login"
+	AuditLoginFailed       = "login_failed"
+	AuditLogout            = "logout"
+	AuditSnippetCreate     = "snippet_create"
+	AuditSnippetUpdate     = "snippet_update"
+	AuditSnippetDelete     = "snippet_delete"
+	AuditSnippetVisibility = "snippet_visibility"
+	AuditAttachmentUpload  = "attachment_upload"
+	AuditAttachmentDelete  = "attachment_delete"
+)
+
+//AuditActions list of all known audit actions
+var AuditActions = []string{
+	AuditLogin,
+	AuditLoginFailed,
+	AuditLogout,
+	AuditSnippetCreate,
+	AuditSnippetUpdate,
+	AuditSnippetDelete,
+	AuditSnippetVisibility,
+	AuditAttachmentUpload,
+	AuditAttachmentDelete,
+}
+
+//AuditEvent model for audit_events table
+type AuditEvent struct {
+	ID         int64     `json:"id"`
+	ActorID    int64     `json:"actor_id"` // 0 for anonymous actor, e.g. failed login
+	Action     string    `json:"action"`
+	TargetType string    `json:"target_type"`
+	TargetID   int64     `json:"target_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Details    string    `json:"details"`
+	Created    time.Time `json:"created"`
+}
+
+//AuditFilter conditions for audit events list, zero fields are ignored
+type AuditFilter struct {
+	ActorID int64
+	UserID  int64 // events done by user or targeting user, e.g. failed logins into account
+	Action  string
+	From    time.Time
+	To      time.Time
 }
@@ -0,0 +1,151 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//AttachmentStore struct for working with attachments table
+type AttachmentStore struct {
+	DB           *sql.DB
+	Observer     QueryObserver
+	QueryTimeout time.Duration
+}
+
+//Insert metadata of attachment
+func (as *AttachmentStore) Insert(ctx context.Context, attachment *models.Attachment) (_ int64, err error) {
+	ctx, q := startQuery(ctx, as.Observer, as.QueryTimeout, "attachments.insert")
+	defer q.end(&err)
+
+	created := attachment.Created
+	if created.IsZero() {
+		created = time.Now().UTC()
+	}
+
+	res, err := as.DB.ExecContext(
+		ctx,
+		`INSERT INTO attachments (snippet_id, filename, content_type, size, blob_key, create_date)
+		VALUES(?, ?, ?, ?, ?, ?)`,
+		attachment.SnippetID,
+		attachment.Filename,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.Key,
+		created,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+//Get attachment by id
+func (as *AttachmentStore) Get(ctx context.Context, id int64) (_ *models.Attachment, err error) {
+	ctx, q := startQuery(ctx, as.Observer, as.QueryTimeout, "attachments.get")
+	defer q.end(&err)
+
+	a := &models.Attachment{}
+	row := as.DB.QueryRowContext(
+		ctx,
+		"SELECT id, snippet_id, filename, content_type, size, blob_key, create_date FROM attachments WHERE id = ?",
+		id,
+	)
+
+	err = row.Scan(&a.ID, &a.SnippetID, &a.Filename, &a.ContentType, &a.Size, &a.Key, &a.Created)
+
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+//List return attachments of snippet in upload order
+func (as *AttachmentStore) List(ctx context.Context, snippetID int64) (_ []*models.Attachment, err error) {
+	ctx, q := startQuery(ctx, as.Observer, as.QueryTimeout, "attachments.list")
+	defer q.end(&err)
+
+	rows, err := as.DB.QueryContext(
+		ctx,
+		`SELECT id, snippet_id, filename, content_type, size, blob_key, create_date FROM attachments
+		WHERE snippet_id = ? ORDER BY id`,
+		snippetID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	res := []*models.Attachment{}
+
+	for rows.Next() {
+		a := &models.Attachment{}
+
+		if err := rows.Scan(&a.ID, &a.SnippetID, &a.Filename, &a.ContentType, &a.Size, &a.Key, &a.Created); err != nil {
+			return nil, err
+		}
+
+		res = append(res, a)
+	}
+
+	return res, rows.Err()
+}
+
+//ListExpired return attachments of snippets which next PurgeExpired with the same arguments removes,
+//blobs of them must be listed before rows are removed by cascade
+func (as *AttachmentStore) ListExpired(ctx context.Context, before time.Time, limit int) (_ []*models.Attachment, err error) {
+	ctx, q := startQuery(ctx, as.Observer, as.QueryTimeout, "attachments.list_expired")
+	defer q.end(&err)
+
+	rows, err := as.DB.QueryContext(
+		ctx,
+		`SELECT a.id, a.snippet_id, a.filename, a.content_type, a.size, a.blob_key, a.create_date FROM attachments a
+		JOIN (SELECT id FROM snippets WHERE expiration_date < ? ORDER BY expiration_date, id LIMIT ?) s ON s.id = a.snippet_id
+		ORDER BY a.id`,
+		before.UTC(),
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	res := []*models.Attachment{}
+
+	for rows.Next() {
+		a := &models.Attachment{}
+
+		if err := rows.Scan(&a.ID, &a.SnippetID, &a.Filename, &a.ContentType, &a.Size, &a.Key, &a.Created); err != nil {
+			return nil, err
+		}
+
+		res = append(res, a)
+	}
+
+	return res, rows.Err()
+}
+
+//Delete metadata of attachment, blob should be removed by caller
+func (as *AttachmentStore) Delete(ctx context.Context, id int64) (err error) {
+	ctx, q := startQuery(ctx, as.Observer, as.QueryTimeout, "attachments.delete")
+	defer q.end(&err)
+
+	res, err := as.DB.ExecContext(ctx, "DELETE FROM attachments WHERE id = ?", id)
+
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(q, res)
+}
@@ -0,0 +1,103 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+func TestAttachments(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("attachments", "snippets", "users")
+
+	snippetID, err := ss.Insert(context.Background(), (&SnippetData{"crash", "Stack trace", 1, true}).toModel(ownerID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	as := &AttachmentStore{DB: db}
+
+	for _, a := range []*models.Attachment{
+		{SnippetID: snippetID, Filename: "app.log", ContentType: "text/plain", Size: 10, Key: "snippets/1/a"},
+		{SnippetID: snippetID, Filename: "core.gz", ContentType: "application/x-gzip", Size: 20, Key: "snippets/1/b"},
+	} {
+		if _, err := as.Insert(context.Background(), a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := as.List(context.Background(), snippetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 || list[0].Filename != "app.log" || list[1].Size != 20 || list[1].Key != "snippets/1/b" {
+		t.Fatalf("Bad attachments: %v", list)
+	}
+
+	if err := as.Delete(context.Background(), list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := as.Get(context.Background(), list[0].ID); err != models.ErrNoRecord {
+		t.Fatalf("Want ErrNoRecord after Delete, Get: %v", err)
+	}
+
+	if err := ss.Delete(context.Background(), snippetID, ownerID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := as.Get(context.Background(), list[1].ID); err != models.ErrNoRecord {
+		t.Fatalf("Want attachments removed with snippet, Get: %v", err)
+	}
+}
+
+func TestAttachmentsListExpired(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("attachments", "snippets", "users")
+
+	as := &AttachmentStore{DB: db}
+
+	for _, data := range []*SnippetData{{"alive", "alive", 1, true}, {"exp1", "exp1", -1, true}, {"exp5", "exp5", -5, true}} {
+		snippetID, err := ss.Insert(context.Background(), data.toModel(ownerID))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := as.Insert(context.Background(), &models.Attachment{SnippetID: snippetID, Filename: "a.log", ContentType: "text/plain", Size: 1, Key: "snippets/" + data.Title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now().UTC()
+
+	list, err := as.ListExpired(context.Background(), now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Key != "snippets/exp5" {
+		t.Fatalf("Want attachment of the oldest expired snippet, Get: %v", list)
+	}
+
+	if _, err := ss.PurgeExpired(context.Background(), now, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := as.Get(context.Background(), list[0].ID); err != models.ErrNoRecord {
+		t.Fatalf("Want listed attachment removed by purge, Get: %v", err)
+	}
+
+	list, err = as.ListExpired(context.Background(), now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Key != "snippets/exp1" {
+		t.Fatalf("Want attachment of the rest expired snippet, Get: %v", list)
+	}
+}
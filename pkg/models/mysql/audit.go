@@ -0,0 +1,153 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+//AuditStore struct for working with audit_events table
+type AuditStore struct {
+	DB           *sql.DB
+	Observer     QueryObserver
+	QueryTimeout time.Duration
+}
+
+//sizes of audit_events columns filled from request
+const (
+	maxUserAgentLen = 255
+	maxDetailsLen   = 500
+)
+
+func nullInt64(value int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: value, Valid: value != 0}
+}
+
+//truncateChars cut string to n characters, multibyte characters are not split
+func truncateChars(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+//Insert audit event into database, too long user agent and details are truncated to column size
+func (as *AuditStore) Insert(ctx context.Context, event *models.AuditEvent) (_ int64, err error) {
+	ctx, q := startQuery(ctx, as.Observer, as.QueryTimeout, "audit.insert")
+	defer q.end(&err)
+
+	created := event.Created
+	if created.IsZero() {
+		created = time.Now().UTC()
+	}
+
+	res, err := as.DB.ExecContext(
+		ctx,
+		`INSERT INTO audit_events (actor_id, action, target_type, target_id, ip, user_agent, details, create_date)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+		nullInt64(event.ActorID),
+		event.Action,
+		event.TargetType,
+		nullInt64(event.TargetID),
+		event.IP,
+		truncateChars(event.UserAgent, maxUserAgentLen),
+		truncateChars(event.Details, maxDetailsLen),
+		created,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+//List return audit events matching filter sorted by create_date
+func (as *AuditStore) List(ctx context.Context, filter *models.AuditFilter, count, page int) (_ []*models.AuditEvent, err error) {
+	ctx, q := startQuery(ctx, as.Observer, as.QueryTimeout, "audit.list")
+	defer q.end(&err)
+
+	conds := []string{}
+	args := []interface{}{}
+
+	if filter != nil {
+		if filter.ActorID != 0 {
+			conds = append(conds, "actor_id = ?")
+			args = append(args, filter.ActorID)
+		}
+		if filter.UserID != 0 {
+			conds = append(conds, "(actor_id = ? OR (target_type = 'user' AND target_id = ?))")
+			args = append(args, filter.UserID, filter.UserID)
+		}
+		if filter.Action != "" {
+			conds = append(conds, "action = ?")
+			args = append(args, filter.Action)
+		}
+		if !filter.From.IsZero() {
+			conds = append(conds, "create_date >= ?")
+			args = append(args, filter.From)
+		}
+		if !filter.To.IsZero() {
+			conds = append(conds, "create_date < ?")
+			args = append(args, filter.To)
+		}
+	}
+
+	where := ""
+	if len(conds) != 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	query := fmt.Sprintf(
+		`SELECT id, actor_id, action, target_type, target_id, ip, user_agent, details, create_date from audit_events
+		%s ORDER BY create_date DESC, id DESC LIMIT %d, %d`,
+		where,
+		count*page-count,
+		count,
+	)
+
+	rows, err := as.DB.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []*models.AuditEvent{}
+
+	for rows.Next() {
+		var actorID, targetID sql.NullInt64
+		res := &models.AuditEvent{}
+
+		err := rows.Scan(&res.ID, &actorID, &res.Action, &res.TargetType, &targetID, &res.IP, &res.UserAgent, &res.Details, &res.Created)
+
+		if err != nil {
+			return nil, err
+		}
+
+		res.ActorID = actorID.Int64
+		res.TargetID = targetID.Int64
+
+		events = append(events, res)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,135 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+)
+
+func TestInsertAuditEvent(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	defer truncate("audit_events")
+
+	as := &AuditStore{DB: db}
+
+	_, err := as.Insert(context.Background(), &models.AuditEvent{
+		ActorID:    1,
+		Action:     models.AuditSnippetCreate,
+		TargetType: "snippet",
+		TargetID:   10,
+		IP:         "127.0.0.1",
+		UserAgent:  "test",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditList(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tests := map[string]struct {
+		Filter      *models.AuditFilter
+		Count       int
+		Page        int
+		WantActions []string
+	}{
+		"All events": {
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditLoginFailed, models.AuditLogin, models.AuditSnippetCreate, models.AuditLogin},
+		},
+		"Filter by actor": {
+			Filter:      &models.AuditFilter{ActorID: 1},
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditSnippetCreate, models.AuditLogin},
+		},
+		"Filter by user": {
+			Filter:      &models.AuditFilter{UserID: 1},
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditLoginFailed, models.AuditSnippetCreate, models.AuditLogin},
+		},
+		"Filter by action": {
+			Filter:      &models.AuditFilter{Action: models.AuditLogin},
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditLogin, models.AuditLogin},
+		},
+		"Filter by date range": {
+			Filter:      &models.AuditFilter{From: now.Add(-150 * time.Minute), To: now.Add(-time.Minute)},
+			Count:       10,
+			Page:        1,
+			WantActions: []string{models.AuditLogin, models.AuditSnippetCreate},
+		},
+		"Second page": {
+			Count:       3,
+			Page:        2,
+			WantActions: []string{models.AuditLogin},
+		},
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, truncate := GetDB(t, dsnString)
+			defer truncate("audit_events")
+
+			as := &AuditStore{DB: db}
+
+			events := []*models.AuditEvent{
+				{ActorID: 1, Action: models.AuditLogin, Created: now.Add(-3 * time.Hour)},
+				{ActorID: 1, Action: models.AuditSnippetCreate, TargetType: "snippet", TargetID: 5, Created: now.Add(-2 * time.Hour)},
+				{ActorID: 2, Action: models.AuditLogin, Created: now.Add(-time.Hour)},
+				{Action: models.AuditLoginFailed, TargetType: "user", TargetID: 1, Details: "email=test@mail.com", Created: now},
+			}
+
+			for _, e := range events {
+				if _, err := as.Insert(context.Background(), e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			res, err := as.List(context.Background(), value.Filter, value.Count, value.Page)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(res) != len(value.WantActions) {
+				t.Fatalf("Want len: %d, Get len: %d", len(value.WantActions), len(res))
+			}
+
+			for i, e := range res {
+				if e.Action != value.WantActions[i] {
+					t.Fatalf("Want action: %s, Get action: %s", value.WantActions[i], e.Action)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateChars(t *testing.T) {
+	tests := map[string]struct {
+		Value string
+		N     int
+		Want  string
+	}{
+		"Short":     {"agent", 10, "agent"},
+		"Exact":     {"agent", 5, "agent"},
+		"Long":      {"agent", 3, "age"},
+		"Multibyte": {"абвгд", 2, "аб"},
+		"Empty":     {"", 3, ""},
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := truncateChars(value.Value, value.N); got != value.Want {
+				t.Fatalf("Want: %q, Get: %q", value.Want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "githib.com/VladimirStepanov/snippetbox/pkg/models/mysql"
+
+//QueryObserver is called after every store operation with its name, duration and error
+type QueryObserver func(op string, duration time.Duration, err error)
+
+//isFailure report if err is database failure, not expected model error
+func isFailure(err error) bool {
+	switch err {
+	case nil, models.ErrNoRecord, models.ErrDuplicateEmail, models.ErrAuth, models.ErrUnknownOwnerID,
+		models.ErrBadCursor, models.ErrUnknownSort, models.ErrUnknownParent:
+		return false
+	}
+	return true
+}
+
+//query is store operation traced by span and reported to QueryObserver when ended
+type query struct {
+	op       string
+	start    time.Time
+	span     trace.Span
+	observer QueryObserver
+	cancel   context.CancelFunc
+}
+
+//startQuery start span of store operation op, returned context is canceled after timeout or when query is ended
+func startQuery(ctx context.Context, o QueryObserver, timeout time.Duration, op string) (context.Context, *query) {
+	var cancel context.CancelFunc = func() {}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(
+		ctx,
+		op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "mysql"),
+			attribute.String("db.operation.name", op),
+		),
+	)
+
+	return ctx, &query{op: op, start: time.Now(), span: span, observer: o, cancel: cancel}
+}
+
+//rowsAffected add count of changed rows to span
+func (q *query) rowsAffected(count int64) {
+	q.span.SetAttributes(attribute.Int64("db.rows_affected", count))
+}
+
+//end finish span and call observer, model errors are not failures
+func (q *query) end(err *error) {
+	q.cancel()
+
+	var res error
+	if isFailure(*err) {
+		res = *err
+		q.span.RecordError(res)
+		q.span.SetStatus(codes.Error, res.Error())
+	}
+
+	q.span.End()
+
+	if q.observer != nil {
+		q.observer(q.op, time.Since(q.start), res)
+	}
+}
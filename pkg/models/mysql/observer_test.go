@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"githib.com/VladimirStepanov/snippetbox/pkg/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQuery(t *testing.T) {
+	dbErr := errors.New("connection lost")
+
+	tests := map[string]struct {
+		Err        error
+		WantErr    error
+		WantStatus codes.Code
+	}{
+		"Success":        {Err: nil, WantErr: nil, WantStatus: codes.Unset},
+		"No record":      {Err: models.ErrNoRecord, WantErr: nil, WantStatus: codes.Unset},
+		"Duplicate":      {Err: models.ErrDuplicateEmail, WantErr: nil, WantStatus: codes.Unset},
+		"Bad cursor":     {Err: models.ErrBadCursor, WantErr: nil, WantStatus: codes.Unset},
+		"Unknown sort":   {Err: models.ErrUnknownSort, WantErr: nil, WantStatus: codes.Unset},
+		"Unknown parent": {Err: models.ErrUnknownParent, WantErr: nil, WantStatus: codes.Unset},
+		"Database error": {Err: dbErr, WantErr: dbErr, WantStatus: codes.Error},
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotOp string
+			var gotErr error
+
+			o := func(op string, d time.Duration, err error) {
+				gotOp = op
+				gotErr = err
+			}
+
+			_, q := startQuery(context.Background(), o, 0, "snippets.delete")
+			q.rowsAffected(3)
+			q.end(&value.Err)
+
+			if gotOp != "snippets.delete" || gotErr != value.WantErr {
+				t.Fatalf("Want: %v, Get: %s %v", value.WantErr, gotOp, gotErr)
+			}
+
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+
+			if span.Name() != "snippets.delete" || span.Status().Code != value.WantStatus {
+				t.Fatalf("Want span with status %v, Get: %s %v", value.WantStatus, span.Name(), span.Status())
+			}
+
+			wantAttrs := map[attribute.Key]attribute.Value{
+				"db.system.name":    attribute.StringValue("mysql"),
+				"db.operation.name": attribute.StringValue("snippets.delete"),
+				"db.rows_affected":  attribute.Int64Value(3),
+			}
+
+			for _, attr := range span.Attributes() {
+				if want, ok := wantAttrs[attr.Key]; ok && want == attr.Value {
+					delete(wantAttrs, attr.Key)
+				}
+			}
+
+			if len(wantAttrs) != 0 {
+				t.Fatalf("No attributes in span: %v", wantAttrs)
+			}
+		})
+	}
+
+	var err error
+	_, q := startQuery(context.Background(), nil, 0, "snippets.get")
+	q.end(&err)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	ctx, q := startQuery(context.Background(), nil, 10*time.Millisecond, "snippets.get")
+
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("Query context has no deadline")
+	}
+
+	<-ctx.Done()
+
+	err := ctx.Err()
+	q.end(&err)
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Want: %v, Get: %v", context.DeadlineExceeded, err)
+	}
+
+	ctx, q = startQuery(context.Background(), nil, 0, "snippets.get")
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("Query without timeout has deadline")
+	}
+
+	q.end(&err)
+}
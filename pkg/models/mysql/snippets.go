@@ -1,40 +1,143 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
 	"github.com/go-sql-driver/mysql"
 )
 
+const (
+	snippetColumns = "id, title, content, create_date, update_date, expiration_date, is_public, unlisted, owner_id, remind_expiry, views, format, parent_id"
+	notExpired     = "(expiration_date IS NULL OR expiration_date > UTC_TIMESTAMP())"
+)
+
 //SnippetStore struct for working with snippets table
 type SnippetStore struct {
-	DB *sql.DB
-}
-
-// Insert snippet into database
-func (s *SnippetStore) Insert(title, content string, expire int, isPublic bool, ownerID int64) (int64, error) {
-	res, err := s.DB.Exec(
-		`INSERT into snippets (title, content, create_date, expiration_date, is_public, owner_id) 
-		VALUES(?, ?, CURDATE(), DATE_ADD(CURDATE(), INTERVAL ? DAY), ?, ?)`,
-		title,
-		content,
-		expire,
-		isPublic,
-		ownerID,
-	)
+	DB           *sql.DB
+	Observer     QueryObserver
+	QueryTimeout time.Duration
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//nullTime convert zero time to NULL, so it means snippet never expires
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+//formatOf return format of snippet, FormatText if it is empty
+func formatOf(snippet *models.Snippet) string {
+	if snippet.Format == "" {
+		return models.FormatText
+	}
+	return snippet.Format
+}
+
+func scanSnippet(row scanner) (*models.Snippet, error) {
+	var expires sql.NullTime
+	var parentID sql.NullInt64
+	res := &models.Snippet{}
+
+	err := row.Scan(&res.ID, &res.Title, &res.Content, &res.Created, &res.Updated, &expires, &res.IsPublic, &res.Unlisted, &res.OwnerID, &res.RemindExpiry, &res.Views, &res.Format, &parentID)
 
 	if err != nil {
-		if me, ok := err.(*mysql.MySQLError); ok {
-			if me.Number == 1452 {
-				return 0, models.ErrUnknownOwnerID
-			}
+		return nil, err
+	}
+
+	res.Expires = expires.Time
+	res.ParentID = parentID.Int64
+
+	return res, nil
+}
+
+//execer is *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+//inTx run f in transaction, transaction is rolled back if f fails
+func (s *SnippetStore) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//insertFiles save files of snippet in the same order
+func insertFiles(ctx context.Context, db execer, snippetID int64, files []*models.SnippetFile) error {
+	for i, file := range files {
+		_, err := db.ExecContext(
+			ctx,
+			`INSERT into snippet_files (snippet_id, position, filename, language, content) VALUES(?, ?, ?, ?, ?)`,
+			snippetID,
+			i,
+			file.Filename,
+			file.Language,
+			file.Content,
+		)
+
+		if err != nil {
+			return err
 		}
-		return 0, err
 	}
 
-	id, err := res.LastInsertId()
+	return nil
+}
+
+// Insert snippet with files into database, all dates are stored in UTC
+func (s *SnippetStore) Insert(ctx context.Context, snippet *models.Snippet) (_ int64, err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.insert")
+	defer q.end(&err)
+
+	var id int64
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			`INSERT into snippets (title, content, create_date, update_date, expiration_date, is_public, unlisted, owner_id, remind_expiry, format, parent_id) 
+			VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?, ?, ?, ?, ?, ?, ?)`,
+			snippet.Title,
+			snippet.Content,
+			nullTime(snippet.Expires),
+			snippet.IsPublic,
+			snippet.Unlisted,
+			snippet.OwnerID,
+			snippet.RemindExpiry,
+			formatOf(snippet),
+			nullInt64(snippet.ParentID),
+		)
+
+		if err != nil {
+			if me, ok := err.(*mysql.MySQLError); ok && me.Number == 1452 {
+				if strings.Contains(me.Message, "snippets_parent_fk") {
+					return models.ErrUnknownParent
+				}
+				return models.ErrUnknownOwnerID
+			}
+			return err
+		}
+
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+
+		return insertFiles(ctx, tx, id, snippet.Files)
+	})
 
 	if err != nil {
 		return 0, err
@@ -44,8 +147,11 @@ func (s *SnippetStore) Insert(title, content string, expire int, isPublic bool,
 }
 
 //Delete from snippets
-func (s *SnippetStore) Delete(snippetID, userID int64) error {
-	res, err := s.DB.Exec("DELETE from snippets WHERE id=? and owner_id=?", snippetID, userID)
+func (s *SnippetStore) Delete(ctx context.Context, snippetID, userID int64) (err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.delete")
+	defer q.end(&err)
+
+	res, err := s.DB.ExecContext(ctx, "DELETE from snippets WHERE id=? and owner_id=?", snippetID, userID)
 
 	if err != nil {
 		return err
@@ -57,6 +163,8 @@ func (s *SnippetStore) Delete(snippetID, userID int64) error {
 		return err
 	}
 
+	q.rowsAffected(count)
+
 	if count == 0 {
 		return models.ErrNoRecord
 	}
@@ -66,15 +174,18 @@ func (s *SnippetStore) Delete(snippetID, userID int64) error {
 }
 
 //Get specific snippet
-func (s *SnippetStore) Get(snippetID int64) (*models.Snippet, error) {
-	res := &models.Snippet{}
-	row := s.DB.QueryRow(
-		`SELECT id, title, content, create_date, expiration_date, is_public, owner_id from snippets 
-		WHERE id=? AND expiration_date > CURDATE()`,
+func (s *SnippetStore) Get(ctx context.Context, snippetID int64) (_ *models.Snippet, err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.get")
+	defer q.end(&err)
+
+	row := s.DB.QueryRowContext(
+		ctx,
+		`SELECT `+snippetColumns+` from snippets 
+		WHERE id=? AND `+notExpired,
 		snippetID,
 	)
 
-	err := row.Scan(&res.ID, &res.Title, &res.Content, &res.Created, &res.Expires, &res.IsPublic, &res.OwnerID)
+	res, err := scanSnippet(row)
 
 	if err == sql.ErrNoRows {
 		return nil, models.ErrNoRecord
@@ -82,29 +193,53 @@ func (s *SnippetStore) Get(snippetID int64) (*models.Snippet, error) {
 		return nil, err
 	}
 
+	res.Files, err = s.getFiles(ctx, res.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return res, nil
 }
 
-//Update snippet
-func (s *SnippetStore) Update(snippet *models.Snippet, ownerID int64) error {
-	res, err := s.DB.Exec(
-		"update snippets set title = ?, content = ?, is_public = ? where id = ? and owner_id = ?",
-		snippet.Title,
-		snippet.Content,
-		snippet.IsPublic,
-		snippet.ID,
-		ownerID,
+//getFiles return files of snippet in saved order
+func (s *SnippetStore) getFiles(ctx context.Context, snippetID int64) ([]*models.SnippetFile, error) {
+	rows, err := s.DB.QueryContext(
+		ctx,
+		`SELECT id, snippet_id, filename, language, content from snippet_files
+		WHERE snippet_id = ? ORDER BY position`,
+		snippetID,
 	)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	defer rows.Close()
+
+	files := []*models.SnippetFile{}
+
+	for rows.Next() {
+		file := &models.SnippetFile{}
+
+		if err := rows.Scan(&file.ID, &file.SnippetID, &file.Filename, &file.Language, &file.Content); err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+func checkAffected(q *query, res sql.Result) error {
 	ra, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	q.rowsAffected(ra)
+
 	if ra == 0 {
 		return models.ErrNoRecord
 	}
@@ -112,14 +247,80 @@ func (s *SnippetStore) Update(snippet *models.Snippet, ownerID int64) error {
 	return nil
 }
 
+//Update snippet and replace its files, reminder is sent again only if expiration date is changed
+func (s *SnippetStore) Update(ctx context.Context, snippet *models.Snippet, ownerID int64) (err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.update")
+	defer q.end(&err)
+
+	expires := nullTime(snippet.Expires)
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		//assignments see values set before them, so reminder columns compare old expiration_date
+		res, err := tx.ExecContext(
+			ctx,
+			`update snippets set reminder_sent = IF(expiration_date <=> ?, reminder_sent, 0),
+			reminder_attempts = IF(expiration_date <=> ?, reminder_attempts, 0),
+			reminder_retry_date = IF(expiration_date <=> ?, reminder_retry_date, NULL),
+			title = ?, content = ?, is_public = ?, unlisted = ?, expiration_date = ?, remind_expiry = ?, format = ?, update_date = UTC_TIMESTAMP()
+			where id = ? and owner_id = ?`,
+			expires,
+			expires,
+			expires,
+			snippet.Title,
+			snippet.Content,
+			snippet.IsPublic,
+			snippet.Unlisted,
+			expires,
+			snippet.RemindExpiry,
+			formatOf(snippet),
+			snippet.ID,
+			ownerID,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if err := checkAffected(q, res); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE from snippet_files WHERE snippet_id = ?", snippet.ID); err != nil {
+			return err
+		}
+
+		return insertFiles(ctx, tx, snippet.ID, snippet.Files)
+	})
+}
+
+//SetExpiration change expiration date of not expired snippet
+func (s *SnippetStore) SetExpiration(ctx context.Context, snippetID, ownerID int64, expires time.Time) (err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.set_expiration")
+	defer q.end(&err)
+
+	res, err := s.DB.ExecContext(
+		ctx,
+		`update snippets set expiration_date = ?, reminder_sent = 0, reminder_attempts = 0, reminder_retry_date = NULL,
+		update_date = UTC_TIMESTAMP()
+		where id = ? and owner_id = ? and `+notExpired,
+		nullTime(expires),
+		snippetID,
+		ownerID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(q, res)
+}
+
 func (s *SnippetStore) getSnippets(rows *sql.Rows) ([]*models.Snippet, error) {
 
 	snippets := []*models.Snippet{}
 
 	for rows.Next() {
-		res := &models.Snippet{}
-
-		err := rows.Scan(&res.ID, &res.Title, &res.Content, &res.Created, &res.Expires, &res.IsPublic, &res.OwnerID)
+		res, err := scanSnippet(rows)
 
 		if err != nil {
 			return nil, err
@@ -128,39 +329,311 @@ func (s *SnippetStore) getSnippets(rows *sql.Rows) ([]*models.Snippet, error) {
 		snippets = append(snippets, res)
 	}
 
-	return snippets, nil
+	return snippets, rows.Err()
 }
 
-//LatestAll return latest snippets sorted by create_date
-func (s *SnippetStore) LatestAll(ownerID int64, count, page int) ([]*models.Snippet, error) {
-	var rows *sql.Rows
-	var err error
-	var limit string
+//sortColumns expressions of sort fields, never expiring snippets are sorted as expiring in the end of time
+var sortColumns = map[string]string{
+	models.SortCreated: "create_date",
+	models.SortExpires: "COALESCE(expiration_date, '9999-12-31 23:59:59')",
+	models.SortTitle:   "title",
+	models.SortViews:   "views",
+}
+
+//visibilityFilters conditions of snippet visibilities
+var visibilityFilters = map[string]string{
+	models.VisibilityPublic:   "is_public = 1 AND unlisted = 0",
+	models.VisibilityUnlisted: "is_public = 1 AND unlisted = 1",
+	models.VisibilityPrivate:  "is_public = 0",
+}
+
+//listFilter return WHERE condition with arguments for list options
+func listFilter(opts models.ListOptions) (string, []interface{}) {
+	filter := notExpired
+	args := []interface{}{}
 
-	if page == 1 {
-		limit = fmt.Sprintf("LIMIT %d", count)
+	if opts.OwnerID == -1 {
+		filter += " AND " + visibilityFilters[models.VisibilityPublic]
 	} else {
-		limit = fmt.Sprintf("LIMIT %d, %d", count*page-count, count)
+		filter += " AND owner_id = ?"
+		args = append(args, opts.OwnerID)
+
+		if cond, ok := visibilityFilters[opts.Visibility]; ok {
+			filter += " AND " + cond
+		}
 	}
-	if ownerID == -1 {
-		rows, err = s.DB.Query(
-			`SELECT id, title, content, create_date, expiration_date, is_public, owner_id from snippets 
-			WHERE expiration_date > CURDATE() AND is_public = 1 ORDER BY create_date DESC ` + limit,
-		)
+
+	if !opts.ExpiresBefore.IsZero() {
+		filter += " AND expiration_date <= ?"
+		args = append(args, opts.ExpiresBefore.UTC())
+	}
+
+	if !opts.CreatedFrom.IsZero() {
+		filter += " AND create_date >= ?"
+		args = append(args, opts.CreatedFrom.UTC())
+	}
+
+	if !opts.CreatedTo.IsZero() {
+		filter += " AND create_date < ?"
+		args = append(args, opts.CreatedTo.UTC())
+	}
+
+	if opts.ParentID != 0 {
+		filter += " AND parent_id = ?"
+		args = append(args, opts.ParentID)
+	}
+
+	return filter, args
+}
+
+func cursorValue(c *models.Cursor) interface{} {
+	if t, ok := c.Value.(time.Time); ok {
+		return t.UTC()
+	}
+	return c.Value
+}
+
+//List return page of snippets filtered and sorted by options, ID orders snippets with equal sort values.
+//Page is selected by cursor with index range scan instead of offset, so rows before it aren't read,
+//but Total is counted over all matching rows on every call.
+func (s *SnippetStore) List(ctx context.Context, opts models.ListOptions, req models.PageRequest) (_ *models.SnippetPage, err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.list")
+	defer q.end(&err)
+
+	column, ok := sortColumns[opts.SortBy()]
+	if !ok {
+		return nil, models.ErrUnknownSort
+	}
+
+	for _, c := range []*models.Cursor{req.After, req.Before} {
+		if c != nil && c.Sort != opts.SortBy() {
+			return nil, models.ErrBadCursor
+		}
+	}
+
+	filter, args := listFilter(opts)
+	page := &models.SnippetPage{}
+
+	if err = s.DB.QueryRowContext(ctx, "SELECT COUNT(*) from snippets WHERE "+filter, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	//forward is order of the list, backward is used to read page before cursor
+	forward, backward := "DESC", "ASC"
+	next, prev := "<", ">"
+
+	if opts.Asc {
+		forward, backward = backward, forward
+		next, prev = prev, next
+	}
+
+	where, order := filter, forward
+	cursor, op := req.After, next
+
+	if req.Before != nil {
+		cursor, op, order = req.Before, prev, backward
+	}
+
+	if cursor != nil {
+		where += fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op)
+		args = append(args, cursorValue(cursor), cursorValue(cursor), cursor.ID)
+	}
+
+	//one more row tells if there is one more page
+	rows, err := s.DB.QueryContext(
+		ctx,
+		`SELECT `+snippetColumns+` from snippets
+		WHERE `+where+` ORDER BY `+column+` `+order+`, id `+order+` LIMIT ?`,
+		append(args, req.Count+1)...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snippets, err := s.getSnippets(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(snippets) > req.Count
+	if more {
+		snippets = snippets[:req.Count]
+	}
+
+	if req.Before != nil {
+		for i, j := 0, len(snippets)-1; i < j; i, j = i+1, j-1 {
+			snippets[i], snippets[j] = snippets[j], snippets[i]
+		}
+		page.HasPrev, page.HasNext = more, true
 	} else {
-		rows, err = s.DB.Query(
-			`SELECT id, title, content, create_date, expiration_date, is_public, owner_id from snippets
-			WHERE expiration_date > CURDATE() AND owner_id = ? ORDER BY create_date DESC `+limit, ownerID,
-		)
+		page.HasNext, page.HasPrev = more, req.After != nil
 	}
 
+	page.Snippets = snippets
+
+	return page, nil
+}
+
+//ListForkIDs return IDs of all forks of snippet, private and expired ones too
+func (s *SnippetStore) ListForkIDs(ctx context.Context, parentID int64) (_ []int64, err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.list_fork_ids")
+	defer q.end(&err)
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT id from snippets WHERE parent_id = ?", parentID)
+
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 
-	res, err := s.getSnippets(rows)
+	res := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		res = append(res, id)
+	}
+
+	return res, rows.Err()
+}
+
+//IncrementViews count one more view of snippet, update date is not changed
+func (s *SnippetStore) IncrementViews(ctx context.Context, snippetID int64) (err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.increment_views")
+	defer q.end(&err)
+
+	res, err := s.DB.ExecContext(ctx, "update snippets set views = views + 1 where id = ?", snippetID)
+
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(q, res)
+}
+
+//CountExpired return count of snippets expired before specified time
+func (s *SnippetStore) CountExpired(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.count_expired")
+	defer q.end(&err)
+
+	var count int64
+
+	row := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) from snippets WHERE expiration_date < ?", before.UTC())
+
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+//PurgeExpired delete at most limit snippets expired before specified time, the oldest are removed first
+//in the same order as AttachmentStore.ListExpired returns attachments of them
+func (s *SnippetStore) PurgeExpired(ctx context.Context, before time.Time, limit int) (_ int64, err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.purge_expired")
+	defer q.end(&err)
+
+	res, err := s.DB.ExecContext(ctx, "DELETE from snippets WHERE expiration_date < ? ORDER BY expiration_date, id LIMIT ?", before.UTC(), limit)
+
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, err
+	}
+
+	q.rowsAffected(count)
+
+	return count, nil
+}
+
+//ExpiryReminders return not reminded snippets which expire before until, failed reminders wait for retry date
+func (s *SnippetStore) ExpiryReminders(ctx context.Context, until time.Time, limit int) (_ []*models.ExpiryReminder, err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.expiry_reminders")
+	defer q.end(&err)
+
+	rows, err := s.DB.QueryContext(
+		ctx,
+		`SELECT s.id, s.title, s.content, s.create_date, s.expiration_date, s.is_public, s.owner_id, s.remind_expiry,
+		s.reminder_attempts, u.mail, u.firstname from snippets s JOIN users u ON u.id = s.owner_id
+		WHERE s.remind_expiry = 1 AND s.reminder_sent = 0
+		AND (s.reminder_retry_date IS NULL OR s.reminder_retry_date <= UTC_TIMESTAMP())
+		AND s.expiration_date > UTC_TIMESTAMP() AND s.expiration_date <= ?
+		ORDER BY s.expiration_date LIMIT ?`,
+		until.UTC(),
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reminders := []*models.ExpiryReminder{}
+
+	for rows.Next() {
+		var expires sql.NullTime
+		res := &models.ExpiryReminder{Snippet: &models.Snippet{}}
+		sn := res.Snippet
+
+		err := rows.Scan(
+			&sn.ID, &sn.Title, &sn.Content, &sn.Created, &expires, &sn.IsPublic, &sn.OwnerID, &sn.RemindExpiry,
+			&res.Attempts, &res.Email, &res.Firstname,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sn.Expires = expires.Time
+		reminders = append(reminders, res)
+	}
+
+	return reminders, rows.Err()
+}
+
+//MarkReminderSent ...
+func (s *SnippetStore) MarkReminderSent(ctx context.Context, snippetID int64) (err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.mark_reminder_sent")
+	defer q.end(&err)
+
+	res, err := s.DB.ExecContext(ctx, "update snippets set reminder_sent = 1 where id = ?", snippetID)
+
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(q, res)
+}
+
+//MarkReminderFailed count failed send of reminder, it isn't returned by ExpiryReminders until retryAt
+func (s *SnippetStore) MarkReminderFailed(ctx context.Context, snippetID int64, retryAt time.Time) (err error) {
+	ctx, q := startQuery(ctx, s.Observer, s.QueryTimeout, "snippets.mark_reminder_failed")
+	defer q.end(&err)
+
+	res, err := s.DB.ExecContext(
+		ctx,
+		"update snippets set reminder_attempts = reminder_attempts + 1, reminder_retry_date = ? where id = ?",
+		retryAt.UTC(),
+		snippetID,
+	)
+
+	if err != nil {
+		return err
+	}
 
-	return res, err
+	return checkAffected(q, res)
 }
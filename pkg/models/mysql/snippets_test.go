@@ -1,8 +1,11 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"strings"
 	"testing"
+	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
 )
@@ -14,10 +17,24 @@ type SnippetData struct {
 	IsPublic bool
 }
 
+func (sd *SnippetData) expires() time.Time {
+	return time.Now().AddDate(0, 0, sd.Expire)
+}
+
+func (sd *SnippetData) toModel(ownerID int64) *models.Snippet {
+	return &models.Snippet{
+		Title:    sd.Title,
+		Content:  sd.Content,
+		Expires:  sd.expires(),
+		IsPublic: sd.IsPublic,
+		OwnerID:  ownerID,
+	}
+}
+
 func getPreparedSnippetStore(t *testing.T, db *sql.DB) (*SnippetStore, int64) {
 	us := &UsersStore{DB: db}
 
-	userID, err := us.Insert("test", "test", "test", "test")
+	userID, err := us.Insert(context.Background(), "test", "test", "test", "test")
 
 	if err != nil {
 		t.Fatal(err)
@@ -83,13 +100,13 @@ func TestDeleteSnippet(t *testing.T) {
 			var err error
 
 			if value.Data != nil {
-				snippetID, err = ss.Insert(value.Data.Title, value.Data.Content, value.Data.Expire, value.Data.IsPublic, ownerID)
+				snippetID, err = ss.Insert(context.Background(), value.Data.toModel(ownerID))
 				if err != nil {
 					t.Fatal(err)
 				}
 			}
 
-			err = ss.Delete(value.GetID(snippetID), value.GetOwnerID(ownerID))
+			err = ss.Delete(context.Background(), value.GetID(snippetID), value.GetOwnerID(ownerID))
 
 			if value.WantError != nil && value.WantError != err {
 				t.Fatalf("Want: %v, Get: %v\n", value.WantError, err)
@@ -138,7 +155,7 @@ func TestInsertSnippet(t *testing.T) {
 			ss, userID := getPreparedSnippetStore(t, db)
 			defer truncate("snippets", "users")
 
-			_, err := ss.Insert(value.Data.Title, value.Data.Content, value.Data.Expire, value.Data.IsPublic, value.GetOwnerID(userID))
+			_, err := ss.Insert(context.Background(), value.Data.toModel(value.GetOwnerID(userID)))
 
 			if value.WantError != nil && value.WantError != err {
 				t.Fatalf("Want: %v, Get: %v\n", value.WantError, err)
@@ -177,6 +194,17 @@ func TestUpdate(t *testing.T) {
 				return s
 			},
 		},
+		"Update expiration": {
+			WantError: nil,
+			Data: &SnippetData{
+				Title:   "Title",
+				Content: "Content",
+				Expire:  1,
+			},
+			GetSnippetAfterUpdate: func(s *SnippetData) *SnippetData {
+				return &SnippetData{Title: s.Title, Content: s.Content, Expire: 30}
+			},
+		},
 	}
 
 	for name, value := range tests {
@@ -189,9 +217,7 @@ func TestUpdate(t *testing.T) {
 			var err error
 
 			if value.Data != nil {
-				snippetID, err = ss.Insert(
-					value.Data.Title, value.Data.Content, value.Data.Expire, value.Data.IsPublic, ownerID,
-				)
+				snippetID, err = ss.Insert(context.Background(), value.Data.toModel(ownerID))
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -199,11 +225,12 @@ func TestUpdate(t *testing.T) {
 
 			updatedSnippet := value.GetSnippetAfterUpdate(value.Data)
 
-			err = ss.Update(
+			err = ss.Update(context.Background(),
 				&models.Snippet{ID: snippetID,
 					Title:    updatedSnippet.Title,
 					Content:  updatedSnippet.Content,
 					IsPublic: updatedSnippet.IsPublic,
+					Expires:  updatedSnippet.expires(),
 				},
 				ownerID,
 			)
@@ -217,7 +244,7 @@ func TestUpdate(t *testing.T) {
 			}
 
 			if value.Data != nil && snippetID != 0 {
-				snippet, err := ss.Get(snippetID)
+				snippet, err := ss.Get(context.Background(), snippetID)
 
 				if err != nil {
 					t.Fatalf("Error while get: %v %d %d", err, snippetID, ownerID)
@@ -226,6 +253,14 @@ func TestUpdate(t *testing.T) {
 				if snippet.Content != updatedSnippet.Content || snippet.IsPublic != updatedSnippet.IsPublic || snippet.Title != updatedSnippet.Title {
 					t.Fatalf("Want: %v, Get: %v", snippet, updatedSnippet)
 				}
+
+				if diff := snippet.Expires.Sub(updatedSnippet.expires()); diff > time.Minute || diff < -time.Minute {
+					t.Fatalf("Want expires: %v, Get: %v", updatedSnippet.expires(), snippet.Expires)
+				}
+
+				if snippet.Updated.Before(snippet.Created) {
+					t.Fatalf("Updated %v is before created %v", snippet.Updated, snippet.Created)
+				}
 			}
 
 		})
@@ -270,13 +305,13 @@ func TestGetSnippet(t *testing.T) {
 			var err error
 
 			if value.Data != nil {
-				snippetID, err = ss.Insert(value.Data.Title, value.Data.Content, value.Data.Expire, value.Data.IsPublic, ownerID)
+				snippetID, err = ss.Insert(context.Background(), value.Data.toModel(ownerID))
 				if err != nil {
 					t.Fatal(err)
 				}
 			}
 
-			snippet, err := ss.Get(snippetID)
+			snippet, err := ss.Get(context.Background(), snippetID)
 
 			if value.WantError != nil && value.WantError != err {
 				t.Fatalf("Want: %v, Get: %v\n", value.WantError, err)
@@ -295,7 +330,7 @@ func TestGetSnippet(t *testing.T) {
 	}
 }
 
-func TestLatestAll(t *testing.T) {
+func TestList(t *testing.T) {
 	snippets := []*SnippetData{
 		{"1", "2", 1, true},
 		{"11", "22", 1, false},
@@ -304,65 +339,325 @@ func TestLatestAll(t *testing.T) {
 	}
 
 	tests := map[string]struct {
-		WantError  error
 		WantResult []*SnippetData
-		WantLatest int
-		WantPage   int
+		WantTotal  int64
+		WantNext   bool
+		Count      int
 		GetOwnerID func(int64) int64
 	}{
 		"All public": {
-			WantError:  nil,
-			WantResult: []*SnippetData{snippets[0], snippets[2]},
-			WantLatest: 5,
-			WantPage:   1,
+			WantResult: []*SnippetData{snippets[2], snippets[0]},
+			WantTotal:  2,
+			Count:      5,
 			GetOwnerID: func(id int64) int64 {
 				return -1
 			},
 		},
 		"All for owner ID": {
-			WantError:  nil,
-			WantResult: snippets[:3],
-			WantLatest: 5,
-			WantPage:   1,
+			WantResult: []*SnippetData{snippets[2], snippets[1], snippets[0]},
+			WantTotal:  3,
+			Count:      5,
 			GetOwnerID: func(id int64) int64 {
 				return id
 			},
 		},
 		"Empty latest": {
-			WantError:  nil,
 			WantResult: []*SnippetData{},
-			WantLatest: 5,
-			WantPage:   1,
+			WantTotal:  0,
+			Count:      5,
 			GetOwnerID: func(id int64) int64 {
 				return id + 3
 			},
 		},
 		"Test first page": {
-			WantError:  nil,
-			WantResult: snippets[:2],
-			WantLatest: 2,
-			WantPage:   1,
+			WantResult: []*SnippetData{snippets[2], snippets[1]},
+			WantTotal:  3,
+			WantNext:   true,
+			Count:      2,
 			GetOwnerID: func(id int64) int64 {
 				return id
 			},
 		},
-		"Test last page": {
-			WantError:  nil,
-			WantResult: []*SnippetData{snippets[2]},
-			WantLatest: 2,
-			WantPage:   2,
-			GetOwnerID: func(id int64) int64 {
-				return id
-			},
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, truncate := GetDB(t, dsnString)
+			ss, ownerID := getPreparedSnippetStore(t, db)
+			defer truncate("snippets", "users")
+
+			for _, snippet := range snippets {
+				_, err := ss.Insert(context.Background(), snippet.toModel(ownerID))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			page, err := ss.List(context.Background(), models.ListOptions{OwnerID: value.GetOwnerID(ownerID)}, models.PageRequest{Count: value.Count})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if page.Total != value.WantTotal || page.HasNext != value.WantNext || page.HasPrev {
+				t.Fatalf("Want total %d, next %v, prev false, Get: %d, %v, %v", value.WantTotal, value.WantNext, page.Total, page.HasNext, page.HasPrev)
+			}
+
+			if len(value.WantResult) != len(page.Snippets) {
+				t.Fatalf("Want len %d, Got len: %d", len(value.WantResult), len(page.Snippets))
+			}
+
+			for i, snippet := range page.Snippets {
+				if snippet.Content != value.WantResult[i].Content || snippet.IsPublic != value.WantResult[i].IsPublic || snippet.Title != value.WantResult[i].Title {
+					t.Fatalf("Want: %v, Get: %v", value.WantResult[i], snippet)
+				}
+			}
+		})
+	}
+}
+
+func TestListCursors(t *testing.T) {
+	titles := []string{"1", "2", "3", "4", "5"}
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("snippets", "users")
+
+	for _, title := range titles {
+		_, err := ss.Insert(context.Background(), (&SnippetData{title, title, 1, true}).toModel(ownerID))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pageTitles := func(page *models.SnippetPage) string {
+		res := ""
+		for _, snippet := range page.Snippets {
+			res += snippet.Title
+		}
+		return res
+	}
+
+	// forward from the newest page to the oldest one
+	pages := []string{}
+	req := models.PageRequest{Count: 2}
+
+	for {
+		page, err := ss.List(context.Background(), models.ListOptions{OwnerID: ownerID}, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if page.HasPrev != (len(pages) > 0) {
+			t.Fatalf("Page %d: want has prev %v", len(pages)+1, len(pages) > 0)
+		}
+
+		pages = append(pages, pageTitles(page))
+
+		if page.NextCursor(models.SortCreated) == nil {
+			break
+		}
+		req = models.PageRequest{Count: 2, After: page.NextCursor(models.SortCreated)}
+	}
+
+	if strings.Join(pages, "|") != "54|32|1" {
+		t.Fatalf("Want pages 54|32|1, Get: %s", strings.Join(pages, "|"))
+	}
+
+	// back from the oldest page to the newest one
+	last, err := ss.List(context.Background(), models.ListOptions{OwnerID: ownerID}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages = []string{}
+	req = models.PageRequest{Count: 2, Before: last.PrevCursor(models.SortCreated)}
+
+	for req.Before != nil {
+		page, err := ss.List(context.Background(), models.ListOptions{OwnerID: ownerID}, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !page.HasNext {
+			t.Fatalf("Page %s: want has next", pageTitles(page))
+		}
+
+		pages = append(pages, pageTitles(page))
+		req = models.PageRequest{Count: 2, Before: page.PrevCursor(models.SortCreated)}
+	}
+
+	if strings.Join(pages, "|") != "32|54" {
+		t.Fatalf("Want pages 32|54, Get: %s", strings.Join(pages, "|"))
+	}
+}
+
+func TestListOptions(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("snippets", "users")
+
+	snippets := []*SnippetData{
+		{"b", "public", 1, true},
+		{"a", "unlisted", 5, true},
+		{"c", "private", 10, false},
+	}
+
+	for i, snippet := range snippets {
+		model := snippet.toModel(ownerID)
+		model.Unlisted = snippet.Content == "unlisted"
+
+		id, err := ss.Insert(context.Background(), model)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for j := 0; j < i; j++ {
+			if err := ss.IncrementViews(context.Background(), id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := map[string]struct {
+		Options    models.ListOptions
+		WantTitles string
+	}{
+		"Sort by title":              {models.ListOptions{Sort: models.SortTitle, Asc: true}, "abc"},
+		"Sort by title descending":   {models.ListOptions{Sort: models.SortTitle}, "cba"},
+		"Sort by expiration":         {models.ListOptions{Sort: models.SortExpires, Asc: true}, "bac"},
+		"Sort by views":              {models.ListOptions{Sort: models.SortViews}, "cab"},
+		"Public list hides unlisted": {models.ListOptions{OwnerID: -1}, "b"},
+		"Unlisted":                   {models.ListOptions{Visibility: models.VisibilityUnlisted}, "a"},
+		"Private":                    {models.ListOptions{Visibility: models.VisibilityPrivate}, "c"},
+		"Expiring soon":              {models.ListOptions{ExpiresBefore: time.Now().AddDate(0, 0, 2)}, "b"},
+		"Created before":             {models.ListOptions{CreatedTo: time.Now().Add(time.Hour)}, "cab"},
+		"Created after":              {models.ListOptions{CreatedFrom: time.Now().Add(time.Hour)}, ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.Options.OwnerID == 0 {
+				test.Options.OwnerID = ownerID
+			}
+
+			page, err := ss.List(context.Background(), test.Options, models.PageRequest{Count: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			titles := ""
+			for _, snippet := range page.Snippets {
+				titles += snippet.Title
+			}
+
+			if titles != test.WantTitles {
+				t.Fatalf("Want: %s, Get: %s", test.WantTitles, titles)
+			}
+		})
+	}
+
+	// cursor of other sort field
+	req := models.PageRequest{Count: 1, After: models.CursorOf(&models.Snippet{ID: 1}, models.SortCreated)}
+	if _, err := ss.List(context.Background(), models.ListOptions{OwnerID: ownerID, Sort: models.SortTitle}, req); err != models.ErrBadCursor {
+		t.Fatalf("Want: %v, Get: %v", models.ErrBadCursor, err)
+	}
+}
+
+func TestSnippetFormat(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("snippets", "users")
+
+	snippet := (&SnippetData{"notes", "# Notes", 1, true}).toModel(ownerID)
+	snippet.Format = models.FormatMarkdown
+
+	id, err := ss.Insert(context.Background(), snippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ss.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.IsMarkdown() {
+		t.Fatalf("Want format: %s, Get: %s", models.FormatMarkdown, res.Format)
+	}
+}
+
+func TestSnippetFiles(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("snippet_files", "snippets", "users")
+
+	snippet := (&SnippetData{"deploy", "Image and entrypoint", 1, true}).toModel(ownerID)
+	snippet.Files = []*models.SnippetFile{
+		{Filename: "Dockerfile", Language: "docker", Content: "FROM alpine"},
+		{Filename: "run.sh", Language: "bash", Content: "echo ok"},
+	}
+
+	id, err := ss.Insert(context.Background(), snippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ss.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Files) != 2 || res.Files[0].Filename != "Dockerfile" || res.Files[1].Content != "echo ok" || res.File("run.sh").Language != "bash" {
+		t.Fatalf("Bad files: %v", res.Files)
+	}
+
+	snippet.ID = id
+	snippet.Files = snippet.Files[1:]
+
+	if err := ss.Update(context.Background(), snippet, ownerID); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = ss.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Files) != 1 || res.Files[0].Filename != "run.sh" || res.Files[0].SnippetID != id {
+		t.Fatalf("Files are not replaced: %v", res.Files)
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	snippets := []*SnippetData{
+		{"1", "2", 1, true},
+		{"exp1", "exp1", -1, false},
+		{"exp2", "exp2", -2, true},
+		{"exp3", "exp3", -5, true},
+	}
+
+	tests := map[string]struct {
+		Before      time.Time
+		Limit       int
+		WantCount   int64
+		WantRemoved int64
+	}{
+		"Remove all expired": {
+			Before:      time.Now().UTC(),
+			Limit:       10,
+			WantCount:   3,
+			WantRemoved: 3,
 		},
-		"Test third page": {
-			WantError:  nil,
-			WantResult: []*SnippetData{snippets[2]},
-			WantLatest: 1,
-			WantPage:   3,
-			GetOwnerID: func(id int64) int64 {
-				return id
-			},
+		"Remove with limit": {
+			Before:      time.Now().UTC(),
+			Limit:       2,
+			WantCount:   3,
+			WantRemoved: 2,
+		},
+		"Remove with grace period": {
+			Before:      time.Now().UTC().AddDate(0, 0, -3),
+			Limit:       10,
+			WantCount:   1,
+			WantRemoved: 1,
 		},
 	}
 
@@ -373,35 +668,296 @@ func TestLatestAll(t *testing.T) {
 			defer truncate("snippets", "users")
 
 			for _, snippet := range snippets {
-				_, err := ss.Insert(snippet.Title, snippet.Content, snippet.Expire, snippet.IsPublic, ownerID)
+				_, err := ss.Insert(context.Background(), snippet.toModel(ownerID))
 				if err != nil {
 					t.Fatal(err)
 				}
 			}
 
-			latestSnippets, err := ss.LatestAll(value.GetOwnerID(ownerID), value.WantLatest, value.WantPage)
-			if value.WantError != nil && value.WantError != err {
-				t.Fatalf("Want: %v, Get: %v\n", value.WantError, err)
+			count, err := ss.CountExpired(context.Background(), value.Before)
+
+			if err != nil {
+				t.Fatal(err)
 			}
 
-			if value.WantError == nil && err != nil {
+			if count != value.WantCount {
+				t.Fatalf("Want count: %d, Get: %d", value.WantCount, count)
+			}
+
+			removed, err := ss.PurgeExpired(context.Background(), value.Before, value.Limit)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if removed != value.WantRemoved {
+				t.Fatalf("Want removed: %d, Get: %d", value.WantRemoved, removed)
+			}
+		})
+	}
+}
+
+func TestNeverExpires(t *testing.T) {
+	tests := map[string]struct {
+		Expires    time.Time
+		SetExpires time.Time
+		WantFound  bool
+	}{
+		"Never expires": {
+			WantFound: true,
+		},
+		"Extend to never": {
+			Expires:   time.Now().Add(time.Hour),
+			WantFound: true,
+		},
+		"Shorten from never": {
+			SetExpires: time.Now().Add(-time.Hour),
+			WantFound:  false,
+		},
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, truncate := GetDB(t, dsnString)
+			ss, ownerID := getPreparedSnippetStore(t, db)
+			defer truncate("snippets", "users")
+
+			snippetID, err := ss.Insert(context.Background(), &models.Snippet{Title: "Title", Content: "Content", Expires: value.Expires, IsPublic: true, OwnerID: ownerID})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = ss.SetExpiration(context.Background(), snippetID, ownerID, value.SetExpires); err != nil {
 				t.Fatal(err)
 			}
 
-			if len(value.WantResult) > 0 {
-				if len(value.WantResult) != len(latestSnippets) {
-					t.Fatalf("Want len %d, Got len: %d", len(value.WantResult), len(latestSnippets))
+			snippet, err := ss.Get(context.Background(), snippetID)
+
+			if value.WantFound {
+				if err != nil {
+					t.Fatal(err)
 				}
 
-				for i := range latestSnippets {
-					if latestSnippets[i].Content != value.WantResult[i].Content || latestSnippets[i].IsPublic != value.WantResult[i].IsPublic || latestSnippets[i].Title != value.WantResult[i].Title {
-						t.Fatalf("Want: %v, Get: %v", latestSnippets[i], value.WantResult[i])
-					}
+				if !snippet.NeverExpires() {
+					t.Fatalf("Want never expires, Get: %v", snippet.Expires)
 				}
-			} else if len(value.WantResult) == 0 && len(latestSnippets) != 0 {
-				t.Fatalf("Want len: 0, got len: %d", len(latestSnippets))
+			} else if err != models.ErrNoRecord {
+				t.Fatalf("Want: %v, Get: %v", models.ErrNoRecord, err)
+			}
+
+			count, err := ss.CountExpired(context.Background(), time.Now())
+
+			if err != nil {
+				t.Fatal(err)
 			}
 
+			if (count == 0) != value.WantFound {
+				t.Fatalf("Want found: %v, Get expired count: %d", value.WantFound, count)
+			}
 		})
 	}
 }
+
+func TestSetExpirationNotFound(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("snippets", "users")
+
+	snippetID, err := ss.Insert(context.Background(), &models.Snippet{Title: "Title", Content: "Content", Expires: time.Now().Add(time.Hour), OwnerID: ownerID})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ss.SetExpiration(context.Background(), snippetID, ownerID+1, time.Time{}); err != models.ErrNoRecord {
+		t.Fatalf("Want: %v, Get: %v", models.ErrNoRecord, err)
+	}
+}
+
+func TestExpiryReminders(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("snippets", "users")
+
+	data := []*models.Snippet{
+		{Title: "soon", Expires: time.Now().Add(time.Hour), RemindExpiry: true},
+		{Title: "soon without reminder", Expires: time.Now().Add(time.Hour)},
+		{Title: "later", Expires: time.Now().Add(48 * time.Hour), RemindExpiry: true},
+		{Title: "never", RemindExpiry: true},
+		{Title: "expired", Expires: time.Now().Add(-time.Hour), RemindExpiry: true},
+	}
+
+	for _, val := range data {
+		val.Content = "Content"
+		val.OwnerID = ownerID
+		if _, err := ss.Insert(context.Background(), val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reminders, err := ss.ExpiryReminders(context.Background(), time.Now().Add(24*time.Hour), 10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reminders) != 1 || reminders[0].Snippet.Title != "soon" || reminders[0].Email != "test" {
+		t.Fatalf("Want one reminder for 'soon', Get: %v", reminders)
+	}
+
+	id := reminders[0].Snippet.ID
+
+	for _, retryAt := range []time.Time{time.Now().Add(time.Hour), time.Now().Add(-time.Minute)} {
+		if err = ss.MarkReminderFailed(context.Background(), id, retryAt); err != nil {
+			t.Fatal(err)
+		}
+
+		reminders, err = ss.ExpiryReminders(context.Background(), time.Now().Add(24*time.Hour), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if retryAt.After(time.Now()) && len(reminders) != 0 {
+			t.Fatalf("Want failed reminder delayed, Get: %v", reminders)
+		}
+	}
+
+	if len(reminders) != 1 || reminders[0].Attempts != 2 {
+		t.Fatalf("Want reminder after retry date with 2 attempts, Get: %v", reminders)
+	}
+
+	if err = ss.MarkReminderSent(context.Background(), reminders[0].Snippet.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	reminders, err = ss.ExpiryReminders(context.Background(), time.Now().Add(24*time.Hour), 10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reminders) != 0 {
+		t.Fatalf("Want no reminders after mark, Get: %v", reminders)
+	}
+}
+
+func TestUpdateKeepsReminder(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("snippet_files", "snippets", "users")
+
+	until := time.Now().Add(24 * time.Hour)
+	id, err := ss.Insert(context.Background(), &models.Snippet{Title: "soon", Content: "Content", OwnerID: ownerID, Expires: time.Now().Add(time.Hour), RemindExpiry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ss.MarkReminderSent(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := ss.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		Expires       time.Time
+		WantReminders int
+	}{
+		{snippet.Expires, 0},
+		{snippet.Expires.Add(time.Hour), 1},
+	}
+
+	for _, step := range steps {
+		snippet.Title = "changed"
+		snippet.Expires = step.Expires
+
+		if err = ss.Update(context.Background(), snippet, ownerID); err != nil {
+			t.Fatal(err)
+		}
+
+		reminders, err := ss.ExpiryReminders(context.Background(), until, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(reminders) != step.WantReminders {
+			t.Fatalf("Expires %s: want %d reminders, Get: %v", step.Expires, step.WantReminders, reminders)
+		}
+	}
+}
+
+func TestSnippetForks(t *testing.T) {
+	db, truncate := GetDB(t, dsnString)
+	ss, ownerID := getPreparedSnippetStore(t, db)
+	defer truncate("snippet_files", "snippets", "users")
+
+	parentID, err := ss.Insert(context.Background(), (&SnippetData{"parent", "original", 1, true}).toModel(ownerID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []*SnippetData{{"public fork", "changed", 1, true}, {"private fork", "changed", 1, false}} {
+		fork := data.toModel(ownerID)
+		fork.ParentID = parentID
+
+		if _, err := ss.Insert(context.Background(), fork); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]struct {
+		Options    models.ListOptions
+		WantTitles []string
+	}{
+		"Public forks": {models.ListOptions{OwnerID: -1, ParentID: parentID}, []string{"public fork"}},
+		"Own forks":    {models.ListOptions{OwnerID: ownerID, ParentID: parentID, Asc: true}, []string{"public fork", "private fork"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			page, err := ss.List(context.Background(), test.Options, models.PageRequest{Count: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(page.Snippets) != len(test.WantTitles) || page.Total != int64(len(test.WantTitles)) {
+				t.Fatalf("Want %v, Get: %v", test.WantTitles, page.Snippets)
+			}
+
+			for i, snippet := range page.Snippets {
+				if snippet.Title != test.WantTitles[i] || snippet.ParentID != parentID {
+					t.Fatalf("Want %s forked from %d, Get: %s %d", test.WantTitles[i], parentID, snippet.Title, snippet.ParentID)
+				}
+			}
+		})
+	}
+
+	orphan := (&SnippetData{"orphan", "text", 1, true}).toModel(ownerID)
+	orphan.ParentID = parentID + 1000
+
+	if _, err := ss.Insert(context.Background(), orphan); err != models.ErrUnknownParent {
+		t.Fatalf("Want ErrUnknownParent, Get: %v", err)
+	}
+
+	if ids, err := ss.ListForkIDs(context.Background(), parentID); err != nil || len(ids) != 2 {
+		t.Fatalf("Want IDs of public and private fork, Get: %v %v", ids, err)
+	}
+
+	if err := ss.Delete(context.Background(), parentID, ownerID); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := ss.List(context.Background(), models.ListOptions{OwnerID: ownerID}, models.PageRequest{Count: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, snippet := range page.Snippets {
+		if snippet.ParentID != 0 {
+			t.Fatalf("Want forks without parent after its delete, Get: %d", snippet.ParentID)
+		}
+	}
+}
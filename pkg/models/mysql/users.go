@@ -1,7 +1,9 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"githib.com/VladimirStepanov/snippetbox/pkg/models"
 	"github.com/go-sql-driver/mysql"
@@ -10,18 +12,24 @@ import (
 
 //UsersStore struct for working with snippets table
 type UsersStore struct {
-	DB *sql.DB
+	DB           *sql.DB
+	Observer     QueryObserver
+	QueryTimeout time.Duration
 }
 
 //Insert user to database
-func (us *UsersStore) Insert(firstname, lastname, mail, password string) (int64, error) {
+func (us *UsersStore) Insert(ctx context.Context, firstname, lastname, mail, password string) (_ int64, err error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 
 	if err != nil {
 		return 0, err
 	}
 
-	res, err := us.DB.Exec(
+	ctx, q := startQuery(ctx, us.Observer, us.QueryTimeout, "users.insert")
+	defer q.end(&err)
+
+	res, err := us.DB.ExecContext(
+		ctx,
 		"INSERT INTO users (firstname, lastname, mail, password) VALUES (?, ?, ?, ?)",
 		firstname,
 		lastname,
@@ -48,11 +56,33 @@ func (us *UsersStore) Insert(firstname, lastname, mail, password string) (int64,
 }
 
 //Get user from database
-func (us *UsersStore) Get(id int64) (*models.User, error) {
+func (us *UsersStore) Get(ctx context.Context, id int64) (_ *models.User, err error) {
+	ctx, q := startQuery(ctx, us.Observer, us.QueryTimeout, "users.get")
+	defer q.end(&err)
+
 	resUser := &models.User{}
-	row := us.DB.QueryRow("SELECT id, firstname, lastname, mail FROM users where id = ?", id)
+	row := us.DB.QueryRowContext(ctx, "SELECT id, firstname, lastname, mail, is_admin FROM users where id = ?", id)
 
-	err := row.Scan(&resUser.ID, &resUser.Firstname, &resUser.Lastname, &resUser.Email)
+	err = row.Scan(&resUser.ID, &resUser.Firstname, &resUser.Lastname, &resUser.Email, &resUser.IsAdmin)
+
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return resUser, nil
+}
+
+//GetByEmail return user with specified email
+func (us *UsersStore) GetByEmail(ctx context.Context, email string) (_ *models.User, err error) {
+	ctx, q := startQuery(ctx, us.Observer, us.QueryTimeout, "users.get_by_email")
+	defer q.end(&err)
+
+	resUser := &models.User{}
+	row := us.DB.QueryRowContext(ctx, "SELECT id, firstname, lastname, mail, is_admin FROM users where mail = ?", email)
+
+	err = row.Scan(&resUser.ID, &resUser.Firstname, &resUser.Lastname, &resUser.Email, &resUser.IsAdmin)
 
 	if err == sql.ErrNoRows {
 		return nil, models.ErrNoRecord
@@ -64,17 +94,23 @@ func (us *UsersStore) Get(id int64) (*models.User, error) {
 }
 
 //Authenticate ...
-func (us *UsersStore) Authenticate(email, password string) (int64, error) {
+func (us *UsersStore) Authenticate(ctx context.Context, email, password string) (int64, error) {
 	var returnID int64
 	var hashedPassword string
 
-	row := us.DB.QueryRow(`select id, password from users where mail=?`, email)
+	ctx, q := startQuery(ctx, us.Observer, us.QueryTimeout, "users.authenticate")
+	row := us.DB.QueryRowContext(ctx, `select id, password from users where mail=?`, email)
 
 	err := row.Scan(&returnID, &hashedPassword)
 
+	//unknown email is expected error, it is mapped before query is reported
 	if err == sql.ErrNoRows {
-		return 0, models.ErrAuth
-	} else if err != nil {
+		err = models.ErrAuth
+	}
+
+	q.end(&err)
+
+	if err != nil {
 		return 0, err
 	}
 
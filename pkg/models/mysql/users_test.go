@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -14,7 +15,7 @@ func TestInsertUser(t *testing.T) {
 
 	us := UsersStore{DB: db}
 
-	_, err := us.Insert("test", "test", "test", "test")
+	_, err := us.Insert(context.Background(), "test", "test", "test", "test")
 
 	if err != nil {
 		t.Fatal(err)
@@ -28,13 +29,13 @@ func TestDuplicateEmail(t *testing.T) {
 
 	us := UsersStore{DB: db}
 
-	_, err := us.Insert("test", "test", "test", "test")
+	_, err := us.Insert(context.Background(), "test", "test", "test", "test")
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = us.Insert("test", "test", "test", "test")
+	_, err = us.Insert(context.Background(), "test", "test", "test", "test")
 
 	if err != models.ErrDuplicateEmail {
 		t.Fatalf("get: %v, want: %v", err, models.ErrDuplicateEmail)
@@ -65,10 +66,10 @@ func TestGetUser(t *testing.T) {
 	for name, value := range tests {
 		t.Run(name, func(t *testing.T) {
 			db, truncate := GetDB(t, dsnString)
-			us := &UsersStore{db}
+			us := &UsersStore{DB: db}
 			defer truncate("users")
 			if value.WantUser != nil {
-				id, err := us.Insert(value.WantUser.Firstname, value.WantUser.Lastname, value.WantUser.Email, "1234")
+				id, err := us.Insert(context.Background(), value.WantUser.Firstname, value.WantUser.Lastname, value.WantUser.Email, "1234")
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -76,7 +77,7 @@ func TestGetUser(t *testing.T) {
 				value.UserID = id
 			}
 
-			resUser, err := us.Get(value.UserID)
+			resUser, err := us.Get(context.Background(), value.UserID)
 
 			if value.WantError != nil && value.WantError != err {
 				t.Fatalf("Error %v != %v", value.WantError, err)
@@ -121,18 +122,18 @@ func TestAuthentication(t *testing.T) {
 	for name, value := range tests {
 		t.Run(name, func(t *testing.T) {
 			db, truncate := GetDB(t, dsnString)
-			us := &UsersStore{db}
+			us := &UsersStore{DB: db}
 			defer truncate("users")
 
 			if value.Data.WantAdd {
 				var err error
-				value.WantID, err = us.Insert("1", "2", value.Data.Email, value.Data.Password)
+				value.WantID, err = us.Insert(context.Background(), "1", "2", value.Data.Email, value.Data.Password)
 				if err != nil {
 					t.Fatal(err)
 				}
 			}
 
-			authID, err := us.Authenticate(value.Data.Email, value.Data.WantPassword)
+			authID, err := us.Authenticate(context.Background(), value.Data.Email, value.Data.WantPassword)
 
 			if value.WantError != nil && value.WantError != err {
 				t.Fatalf("want: %v, get: %v", value.WantError, err)
@@ -1,17 +1,46 @@
 package models
 
+import (
+	"context"
+	"time"
+)
+
 //UserRepository interface for working with DB
 type UserRepository interface {
-	Insert(firstname, lastname, mail, password string) (int64, error)
-	Get(id int64) (*User, error)
-	Authenticate(email, password string) (int64, error)
+	Insert(ctx context.Context, firstname, lastname, mail, password string) (int64, error)
+	Get(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Authenticate(ctx context.Context, email, password string) (int64, error)
 }
 
 //SnippetRepository interface for working with DB
 type SnippetRepository interface {
-	Insert(title, content string, expire int, isPublic bool, ownerID int64) (int64, error)
-	Delete(snippetID, userID int64) error
-	Get(snippetID int64) (*Snippet, error)
-	Update(snippet *Snippet, ownerID int64) error
-	LatestAll(ownerID int64, count, page int) ([]*Snippet, error)
+	Insert(ctx context.Context, snippet *Snippet) (int64, error)
+	Delete(ctx context.Context, snippetID, userID int64) error
+	Get(ctx context.Context, snippetID int64) (*Snippet, error)
+	Update(ctx context.Context, snippet *Snippet, ownerID int64) error
+	List(ctx context.Context, opts ListOptions, req PageRequest) (*SnippetPage, error)
+	ListForkIDs(ctx context.Context, parentID int64) ([]int64, error)
+	IncrementViews(ctx context.Context, snippetID int64) error
+	CountExpired(ctx context.Context, before time.Time) (int64, error)
+	PurgeExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+	SetExpiration(ctx context.Context, snippetID, ownerID int64, expires time.Time) error
+	ExpiryReminders(ctx context.Context, until time.Time, limit int) ([]*ExpiryReminder, error)
+	MarkReminderSent(ctx context.Context, snippetID int64) error
+	MarkReminderFailed(ctx context.Context, snippetID int64, retryAt time.Time) error
+}
+
+//AttachmentRepository interface for metadata of snippet attachments
+type AttachmentRepository interface {
+	Insert(ctx context.Context, attachment *Attachment) (int64, error)
+	Get(ctx context.Context, id int64) (*Attachment, error)
+	List(ctx context.Context, snippetID int64) ([]*Attachment, error)
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]*Attachment, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+//AuditRepository interface for append-only audit log
+type AuditRepository interface {
+	Insert(ctx context.Context, event *AuditEvent) (int64, error)
+	List(ctx context.Context, filter *AuditFilter, count, page int) ([]*AuditEvent, error)
 }
@@ -5,4 +5,55 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
+}
+
+// live preview of markdown in create and edit form
+var previewForm = document.querySelector("form[data-preview]");
+if (previewForm) {
+	var content = previewForm.querySelector("textarea[name='content']");
+	var format = previewForm.querySelector("select[name='format']");
+	var preview = document.getElementById("preview");
+	var token = previewForm.querySelector("input[name='gorilla.csrf.Token']");
+	var timer;
+
+	var updatePreview = function () {
+		if (format.value != "markdown") {
+			preview.hidden = true;
+			return;
+		}
+
+		fetch(previewForm.dataset.preview, {
+			method: "POST",
+			credentials: "same-origin",
+			headers: {"X-CSRF-Token": token.value},
+			body: new URLSearchParams({content: content.value})
+		}).then(function (resp) {
+			return resp.ok ? resp.text() : Promise.reject(resp.status);
+		}).then(function (html) {
+			preview.innerHTML = html;
+			preview.hidden = false;
+		}).catch(function () {});
+	};
+
+	content.addEventListener("input", function () {
+		clearTimeout(timer);
+		timer = setTimeout(updatePreview, 300);
+	});
+	format.addEventListener("change", updatePreview);
+	updatePreview();
+}
+
+// add and remove files in create and edit form
+var fileAdd = document.querySelector("button.file-add");
+if (fileAdd) {
+	var fileRow = document.getElementById("file-row");
+
+	fileAdd.addEventListener("click", function () {
+		fileAdd.parentNode.insertBefore(fileRow.content.cloneNode(true), fileRow);
+	});
+	fileAdd.parentNode.addEventListener("click", function (e) {
+		if (e.target.classList.contains("file-remove")) {
+			e.target.parentNode.remove();
+		}
+	});
 }
\ No newline at end of file
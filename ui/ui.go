@@ -0,0 +1,9 @@
+//Package ui contains html templates and static assets compiled into binary
+package ui
+
+import "embed"
+
+//Files has html and static directories
+//
+//go:embed html static
+var Files embed.FS